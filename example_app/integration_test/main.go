@@ -49,9 +49,9 @@ func main() {
 		// Since ReplaceData provides ID, we don't need AUTO_INCREMENT/IDENTITY for this test.
 		// So we just need PRIMARY KEY constraint.
 		db.SetupTable("users", true, []v1.Field{
-			{"id", "NUMBER PRIMARY KEY"},
-			{"name", "VARCHAR2(100)"},
-			{"status", "VARCHAR2(50)"},
+			{Name: "id", Type: "NUMBER PRIMARY KEY"},
+			{Name: "name", Type: "VARCHAR2(100)"},
+			{Name: "status", Type: "VARCHAR2(50)"},
 		}, nil)
 
 		// 2. Run App
@@ -30,7 +30,11 @@ func runComplexExamples(client *dms.Client) {
 
 		// 4. Setup Response
 		dms.SetStatusCode("", 200),
-		dms.SetHeader("", "X-Ref-Code", "{{.REF_CODE}}"),
+		dms.SetHeaders("", map[string]string{
+			"X-Ref-Code":        "{{.REF_CODE}}",
+			"X-Transaction-Id":  "{{.TX_ID}}",
+			"X-Processing-Time": "{{.PROC_TIME_MS}}ms",
+		}),
 		dms.SetJsonBody("", `{
 			"status": "success",
 			"transaction_id": "{{.TX_ID}}",
@@ -4,7 +4,10 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"strconv"
 	"time"
 
 	"crypto/tls"
@@ -49,12 +52,24 @@ func (c *Client) RegisterRoute(port int, method, path string, responseFuncs []Re
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to register route: status %d", resp.StatusCode)
+		msg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to register route: status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
 	}
 
 	return nil
 }
 
+// RegisterRouteMethods registers the same steps for path under each of the given methods
+// (e.g. GET and HEAD). It stops at the first registration that fails.
+func (c *Client) RegisterRouteMethods(port int, methods []string, path string, responseFuncs []ResponseFuncConfig) error {
+	for _, method := range methods {
+		if err := c.RegisterRoute(port, method, path, responseFuncs); err != nil {
+			return fmt.Errorf("%s %s: %w", method, path, err)
+		}
+	}
+	return nil
+}
+
 // ResetPort resets all routes for a specific port.
 func (c *Client) ResetPort(port int) error {
 	reqBody := map[string]int{"port": port}
@@ -89,6 +104,109 @@ func (c *Client) ResetAll() error {
 	return nil
 }
 
+// DescribeRoute returns the steps currently registered for a route.
+func (c *Client) DescribeRoute(port int, method, path string) ([]ResponseFuncConfig, error) {
+	q := url.Values{}
+	q.Set("port", strconv.Itoa(port))
+	q.Set("method", method)
+	q.Set("path", path)
+
+	resp, err := c.Client.Get(c.BaseURL + "/describeRoute?" + q.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to describe route: status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	var steps []ResponseFuncConfig
+	if err := json.NewDecoder(resp.Body).Decode(&steps); err != nil {
+		return nil, err
+	}
+	return steps, nil
+}
+
+// SetNotFoundResponse sets the status code and body returned for unmatched requests on a port.
+func (c *Client) SetNotFoundResponse(port int, statusCode int, body string) error {
+	reqBody := NotFoundResponse{Port: port, StatusCode: statusCode, Body: body}
+	data, err := json.Marshal(reqBody)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.Client.Post(c.BaseURL+"/setNotFoundResponse", "application/json", bytes.NewBuffer(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to set not-found response: status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// SetPortMode switches every route on a port into PortModeMaintenance (all requests get 503)
+// or back to PortModeNormal, without re-registering routes.
+func (c *Client) SetPortMode(port int, mode string) error {
+	data, err := json.Marshal(SetPortModeRequest{Port: port, Mode: mode})
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.Client.Post(c.BaseURL+"/setPortMode", "application/json", bytes.NewBuffer(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to set port mode: status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// Interactions returns the requests received on all mock ports since the last reset,
+// oldest first, so the order of calls across services can be checked.
+func (c *Client) Interactions() ([]RecordedRequest, error) {
+	resp, err := c.Client.Get(c.BaseURL + "/interactions")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get interactions: status %d", resp.StatusCode)
+	}
+	var list []RecordedRequest
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// ResetInteractions clears the interaction log (ResetAll clears it as well).
+func (c *Client) ResetInteractions() error {
+	req, err := http.NewRequest(http.MethodDelete, c.BaseURL+"/interactions", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to reset interactions: status %d", resp.StatusCode)
+	}
+	return nil
+}
+
 // Helper functions to create ResponseFuncConfig
 
 func IfRequestHeader(headerName, condition, value, dynamicVar string, toBeValue interface{}) ResponseFuncConfig {
@@ -187,6 +305,73 @@ func IfDynamicVariableSetCase(varName, condition string, value interface{}, case
 	}
 }
 
+func IfRemoteAddrSetCase(condition, value, caseStr string) ResponseFuncConfig {
+	return ResponseFuncConfig{
+		Group: GroupPrepareData,
+		Func:  FuncIfRemoteAddrSetCase,
+		Args:  []interface{}{condition, value, caseStr},
+	}
+}
+
+// IfAcceptSetCase activates caseStr when the request's Accept header lists mediaType
+// (e.g. "application/xml") with a non-zero quality and no other listed type is preferred
+// over it. Wildcards such as "*/*" never activate a case, so they get the default response.
+func IfAcceptSetCase(mediaType, caseStr string) ResponseFuncConfig {
+	return ResponseFuncConfig{
+		Group: GroupPrepareData,
+		Func:  FuncIfAcceptSetCase,
+		Args:  []interface{}{mediaType, caseStr},
+	}
+}
+
+func IfRequestBodySizeSetCase(condition string, size int, caseStr string) ResponseFuncConfig {
+	return ResponseFuncConfig{
+		Group: GroupPrepareData,
+		Func:  FuncIfRequestBodySizeSetCase,
+		Args:  []interface{}{condition, size, caseStr},
+	}
+}
+
+// IfAllSetCase activates caseStr when every condition matches the request (AND).
+func IfAllSetCase(conditions []Condition, caseStr string) ResponseFuncConfig {
+	return ResponseFuncConfig{
+		Group: GroupPrepareData,
+		Func:  FuncIfAllSetCase,
+		Args:  []interface{}{conditions, caseStr},
+	}
+}
+
+// IfAnySetCase activates caseStr when at least one condition matches the request (OR).
+func IfAnySetCase(conditions []Condition, caseStr string) ResponseFuncConfig {
+	return ResponseFuncConfig{
+		Group: GroupPrepareData,
+		Func:  FuncIfAnySetCase,
+		Args:  []interface{}{conditions, caseStr},
+	}
+}
+
+// RequireJsonFields activates caseStr (e.g. "ValidationError") when any of the JSON body
+// fields (dot paths, as in IfRequestJsonBody) is missing, null, an empty string, or an
+// empty array or object.
+func RequireJsonFields(fields []string, caseStr string) ResponseFuncConfig {
+	return ResponseFuncConfig{
+		Group: GroupPrepareData,
+		Func:  FuncRequireJsonFields,
+		Args:  []interface{}{fields, caseStr},
+	}
+}
+
+// SetCaseByTimeWindow activates caseStr when the server's current time of day is within
+// [start, end), both "HH:MM". A window whose end is before its start wraps past midnight
+// (e.g. "22:00"-"02:00"). Times are in the controller's Location (local time by default).
+func SetCaseByTimeWindow(start, end, caseStr string) ResponseFuncConfig {
+	return ResponseFuncConfig{
+		Group: GroupPrepareData,
+		Func:  FuncSetCaseByTimeWindow,
+		Args:  []interface{}{start, end, caseStr},
+	}
+}
+
 func IfRequestJsonArrayLength(field, condition string, length int, dynamicVar string, toBeValue interface{}) ResponseFuncConfig {
 	return ResponseFuncConfig{
 		Group: GroupPrepareData,
@@ -275,6 +460,42 @@ func ExtractRequestQuery(field, dynamicVar string) ResponseFuncConfig {
 	}
 }
 
+func ExtractRequestRemoteAddr(dynamicVar string) ResponseFuncConfig {
+	return ResponseFuncConfig{
+		Group: GroupPrepareData,
+		Func:  FuncExtractRequestRemoteAddr,
+		Args:  []interface{}{dynamicVar},
+	}
+}
+
+func EchoRequestHeaders(prefix string) ResponseFuncConfig {
+	return ResponseFuncConfig{
+		Group: GroupPrepareData,
+		Func:  FuncEchoRequestHeaders,
+		Args:  []interface{}{prefix},
+	}
+}
+
+// RequireBasicAuth answers 401 with a WWW-Authenticate challenge, skipping the remaining
+// steps, unless the request carries HTTP Basic credentials matching user and pass.
+func RequireBasicAuth(user, pass string) ResponseFuncConfig {
+	return ResponseFuncConfig{
+		Group: GroupPrepareData,
+		Func:  FuncRequireBasicAuth,
+		Args:  []interface{}{user, pass},
+	}
+}
+
+// RequireBearer answers 401 with a WWW-Authenticate challenge, skipping the remaining
+// steps, unless the request carries "Authorization: Bearer <token>".
+func RequireBearer(token string) ResponseFuncConfig {
+	return ResponseFuncConfig{
+		Group: GroupPrepareData,
+		Func:  FuncRequireBearer,
+		Args:  []interface{}{token},
+	}
+}
+
 func GenerateRandomString(length int, toDynamicVariable string) ResponseFuncConfig {
 	return ResponseFuncConfig{
 		Group: GroupGenerator,
@@ -307,6 +528,37 @@ func GenerateRandomDecimal(min, max float64, maxDecimal int, toDynamicVariable s
 	}
 }
 
+// GenerateSequence stores an id that increments on each request to the route, starting at start.
+// The counter restarts when the route is re-registered or its port is reset.
+func GenerateSequence(start int, toDynamicVariable string) ResponseFuncConfig {
+	return ResponseFuncConfig{
+		Group: GroupGenerator,
+		Func:  FuncGenerateSequence,
+		Args:  []interface{}{start, toDynamicVariable},
+	}
+}
+
+// GenerateFake stores a realistic-looking random value of the given kind: FakeEmail, FakeName,
+// FakePhone, FakeUUID, FakeIPv4 or FakeWord. An unknown kind fails the request.
+func GenerateFake(kind string, toDynamicVariable string) ResponseFuncConfig {
+	return ResponseFuncConfig{
+		Group: GroupGenerator,
+		Func:  FuncGenerateFake,
+		Args:  []interface{}{kind, toDynamicVariable},
+	}
+}
+
+// SeedRandomFromRequest makes the generators after it deterministic per request: their
+// random source is seeded from a hash of the method, path and body, so replaying the same
+// request yields the same values while different requests still vary.
+func SeedRandomFromRequest() ResponseFuncConfig {
+	return ResponseFuncConfig{
+		Group: GroupGenerator,
+		Func:  FuncSeedRandomFromRequest,
+		Args:  []interface{}{},
+	}
+}
+
 func HashedString(fromDynamicVariable, hashAlgorithm, toDynamicVariable string) ResponseFuncConfig {
 	return ResponseFuncConfig{
 		Group: GroupGenerator,
@@ -367,6 +619,8 @@ func SetJsonBody(caseStr, jsonBody string) ResponseFuncConfig {
 	}
 }
 
+// SetXmlBody sets a templated XML response body and sends Content-Type: application/xml
+// unless a SetHeader step sets the Content-Type.
 func SetXmlBody(caseStr, xmlBody string) ResponseFuncConfig {
 	return ResponseFuncConfig{
 		Group: GroupSetupResponse,
@@ -375,6 +629,22 @@ func SetXmlBody(caseStr, xmlBody string) ResponseFuncConfig {
 	}
 }
 
+func SetStreamBody(caseStr string, chunks []string, delayMs int) ResponseFuncConfig {
+	return ResponseFuncConfig{
+		Group: GroupSetupResponse,
+		Func:  FuncSetStreamBody,
+		Args:  []interface{}{caseStr, chunks, delayMs},
+	}
+}
+
+func SetResponseByBodyHash(caseStr string, bodies map[string]string) ResponseFuncConfig {
+	return ResponseFuncConfig{
+		Group: GroupSetupResponse,
+		Func:  FuncSetResponseByBodyHash,
+		Args:  []interface{}{caseStr, bodies},
+	}
+}
+
 func SetStatusCode(caseStr string, statusCode int) ResponseFuncConfig {
 	return ResponseFuncConfig{
 		Group: GroupSetupResponse,
@@ -383,6 +653,35 @@ func SetStatusCode(caseStr string, statusCode int) ResponseFuncConfig {
 	}
 }
 
+func SetStatusCodeTemplate(caseStr, statusTemplate string) ResponseFuncConfig {
+	return ResponseFuncConfig{
+		Group: GroupSetupResponse,
+		Func:  FuncSetStatusCodeTemplate,
+		Args:  []interface{}{caseStr, statusTemplate},
+	}
+}
+
+// SetRedirect answers with a 3xx statusCode (e.g. 301, 302, 307) and a Location header;
+// location may use templates (e.g. "/v2/users/{{.id}}").
+func SetRedirect(caseStr string, statusCode int, location string) ResponseFuncConfig {
+	return ResponseFuncConfig{
+		Group: GroupSetupResponse,
+		Func:  FuncSetRedirect,
+		Args:  []interface{}{caseStr, statusCode, location},
+	}
+}
+
+// SetProxyPassthrough forwards the request (method, path, query, headers and body) to
+// upstreamBaseURL and relays the upstream response instead of a mocked one. Headers set
+// with SetHeader override the relayed ones; delays still apply. The base URL may use templates.
+func SetProxyPassthrough(caseStr string, upstreamBaseURL string) ResponseFuncConfig {
+	return ResponseFuncConfig{
+		Group: GroupSetupResponse,
+		Func:  FuncSetProxyPassthrough,
+		Args:  []interface{}{caseStr, upstreamBaseURL},
+	}
+}
+
 func SetWait(caseStr string, timeoutMs int) ResponseFuncConfig {
 	return ResponseFuncConfig{
 		Group: GroupSetupResponse,
@@ -399,6 +698,14 @@ func SetRandomWait(caseStr string, minMs, maxMs int) ResponseFuncConfig {
 	}
 }
 
+func SetLatencyProfile(caseStr string, p50Ms, p95Ms, p99Ms int) ResponseFuncConfig {
+	return ResponseFuncConfig{
+		Group: GroupSetupResponse,
+		Func:  FuncSetLatencyProfile,
+		Args:  []interface{}{caseStr, p50Ms, p95Ms, p99Ms},
+	}
+}
+
 func SetMethod(caseStr, method string) ResponseFuncConfig {
 	return ResponseFuncConfig{
 		Group: GroupSetupResponse,
@@ -407,6 +714,8 @@ func SetMethod(caseStr, method string) ResponseFuncConfig {
 	}
 }
 
+// SetHeader sets a response header; value may use templates. When both a default ("") and a
+// case-specific SetHeader target the same key, the case-specific value wins regardless of step order.
 func SetHeader(caseStr, key, value string) ResponseFuncConfig {
 	return ResponseFuncConfig{
 		Group: GroupSetupResponse,
@@ -415,6 +724,39 @@ func SetHeader(caseStr, key, value string) ResponseFuncConfig {
 	}
 }
 
+// SetHeaders sets several response headers in one step; like SetHeader, each value may use templates.
+func SetHeaders(caseStr string, headers map[string]string) ResponseFuncConfig {
+	return ResponseFuncConfig{
+		Group: GroupSetupResponse,
+		Func:  FuncSetHeaders,
+		Args:  []interface{}{caseStr, headers},
+	}
+}
+
+func SetGzip(caseStr string, enabled bool) ResponseFuncConfig {
+	return ResponseFuncConfig{
+		Group: GroupSetupResponse,
+		Func:  FuncSetGzip,
+		Args:  []interface{}{caseStr, enabled},
+	}
+}
+
+func SetNoContentLength(caseStr string) ResponseFuncConfig {
+	return ResponseFuncConfig{
+		Group: GroupSetupResponse,
+		Func:  FuncSetNoContentLength,
+		Args:  []interface{}{caseStr},
+	}
+}
+
+func SetConnectionClose(caseStr string) ResponseFuncConfig {
+	return ResponseFuncConfig{
+		Group: GroupSetupResponse,
+		Func:  FuncSetConnectionClose,
+		Args:  []interface{}{caseStr},
+	}
+}
+
 func CopyHeaderFromRequest(caseStr, key string) ResponseFuncConfig {
 	return ResponseFuncConfig{
 		Group: GroupSetupResponse,
@@ -422,3 +764,11 @@ func CopyHeaderFromRequest(caseStr, key string) ResponseFuncConfig {
 		Args:  []interface{}{caseStr, key},
 	}
 }
+
+func CopyHeadersMatching(caseStr, prefix string) ResponseFuncConfig {
+	return ResponseFuncConfig{
+		Group: GroupSetupResponse,
+		Func:  FuncCopyHeadersMatching,
+		Args:  []interface{}{caseStr, prefix},
+	}
+}
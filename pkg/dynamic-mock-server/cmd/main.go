@@ -5,13 +5,20 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
+	"time"
 
 	dms "github.com/XWinterVarit/integrate_tester/pkg/dynamic-mock-server"
 )
 
 func main() {
 	port := flag.Int("port", 9001, "Port for the mock controller")
+	host := flag.String("host", "", "Interface to bind the controller and mock servers to (default: all)")
 	logFile := flag.String("log", "", "Log file path (default: stdout)")
+	recordFile := flag.String("record", "", "Append every mock request as JSON lines to this file (default: off)")
+	envAllow := flag.String("env", "", "Comma-separated environment variables templates may read as {{.Env.NAME}} (default: none)")
+	tz := flag.String("tz", "", "Time zone for time-based steps such as SetCaseByTimeWindow, e.g. Asia/Bangkok (default: local)")
+	debugHeaders := flag.Bool("debug-headers", false, "Add X-Mock-Active-Case and X-Mock-Variables headers to every mock response (default: off)")
 	flag.Parse()
 
 	var logger *dms.Logger
@@ -29,6 +36,33 @@ func main() {
 	defer logger.Close()
 
 	controller := dms.NewMockController(*port, logger)
+	controller.Host = *host
+	controller.DebugHeaders = *debugHeaders
+	if *tz != "" {
+		loc, err := time.LoadLocation(*tz)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid time zone %q: %v\n", *tz, err)
+			os.Exit(1)
+		}
+		controller.Location = loc
+	}
+	if *envAllow != "" {
+		for _, name := range strings.Split(*envAllow, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				controller.EnvAllowlist = append(controller.EnvAllowlist, name)
+			}
+		}
+	}
+	if *recordFile != "" {
+		recorder, err := dms.NewRequestRecorder(*recordFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to create request recorder: %v\n", err)
+			os.Exit(1)
+		}
+		defer recorder.Close()
+		controller.Recorder = recorder
+		fmt.Printf("Recording requests to %s\n", *recordFile)
+	}
 
 	fmt.Printf("Starting Dynamic Mock Server Controller on port %d...\n", *port)
 	if *logFile == "" {
@@ -0,0 +1,42 @@
+package dynamic_mock_server
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+var (
+	fakeFirstNames = []string{"Alice", "Bob", "Carol", "David", "Emma", "Frank", "Grace", "Henry", "Isla", "Jack", "Kanya", "Liam", "Mia", "Noah", "Olivia", "Somchai"}
+	fakeLastNames  = []string{"Smith", "Johnson", "Brown", "Taylor", "Wilson", "Davies", "Evans", "Thomas", "Roberts", "Walker", "Wright", "Green"}
+	fakeWords      = []string{"alpha", "bravo", "cedar", "delta", "ember", "falcon", "granite", "harbor", "indigo", "juniper", "kestrel", "lumen", "meadow", "nectar", "orbit", "pepper"}
+	fakeDomains    = []string{"example.com", "example.org", "example.net", "mail.test"}
+)
+
+// fakeValue returns a plausible random value drawn from r of the given kind (one of the Fake* constants).
+func fakeValue(r *rand.Rand, kind string) (string, error) {
+	switch kind {
+	case FakeEmail:
+		first, last := fakePick(r, fakeFirstNames), fakePick(r, fakeLastNames)
+		return fmt.Sprintf("%s.%s%d@%s", strings.ToLower(first), strings.ToLower(last), r.Intn(100), fakePick(r, fakeDomains)), nil
+	case FakeName:
+		return fakePick(r, fakeFirstNames) + " " + fakePick(r, fakeLastNames), nil
+	case FakePhone:
+		return fmt.Sprintf("+1-%03d-%03d-%04d", 200+r.Intn(800), r.Intn(1000), r.Intn(10000)), nil
+	case FakeUUID:
+		b := make([]byte, 16)
+		r.Read(b)
+		b[6] = (b[6] & 0x0f) | 0x40 // version 4
+		b[8] = (b[8] & 0x3f) | 0x80 // RFC 4122 variant
+		return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+	case FakeIPv4:
+		return fmt.Sprintf("%d.%d.%d.%d", 1+r.Intn(223), r.Intn(256), r.Intn(256), 1+r.Intn(254)), nil
+	case FakeWord:
+		return fakePick(r, fakeWords), nil
+	}
+	return "", fmt.Errorf("unknown fake kind %q", kind)
+}
+
+func fakePick(r *rand.Rand, list []string) string {
+	return list[r.Intn(len(list))]
+}
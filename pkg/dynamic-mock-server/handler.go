@@ -2,15 +2,21 @@ package dynamic_mock_server
 
 import (
 	"bytes"
+	"compress/gzip"
 	"crypto/md5"
 	"crypto/sha256"
+	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
 	"io"
 	"math/rand"
+	"net"
 	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 	"text/template"
@@ -40,7 +46,51 @@ type HandlerExecutor struct {
 	Headers    map[string]string
 	FixedDelay time.Duration
 	RandomWait [2]int // min, max
-	ActiveCase string
+	// LatencyProfile holds p50, p95, p99 in ms; a delay is sampled from it when p99 > 0
+	LatencyProfile [3]int
+	ActiveCase     string
+	// Halted stops the remaining steps, e.g. after a failed RequireBasicAuth/RequireBearer
+	Halted bool
+
+	// Gzip compresses the body when the request accepts gzip (see SetGzip)
+	Gzip bool
+	// NoContentLength sends the body chunked instead of with a Content-Length (see SetNoContentLength)
+	NoContentLength bool
+	// ConnectionClose sends "Connection: close" and closes the connection after the response
+	ConnectionClose bool
+	// ProxyUpstream, when set, relays the request to this base URL (see SetProxyPassthrough)
+	ProxyUpstream string
+
+	// NextSequence returns the next GenerateSequence value for the route; the controller
+	// backs it with per-route counters. When nil, every request gets the start value.
+	NextSequence func(name string, start int) int
+
+	// rng is the generators' random source (see random and SeedRandomFromRequest)
+	rng *rand.Rand
+
+	// bodyContentType is the Content-Type implied by the body step that ran last (SetXmlBody);
+	// Finalize sends it when no step set a Content-Type header
+	bodyContentType string
+
+	// caseHeaders records the (canonical) header keys set by a case-specific SetHeader,
+	// so a default SetHeader for the same key cannot override them
+	caseHeaders map[string]bool
+
+	// Now returns the current time for time-based steps (SetCaseByTimeWindow); nil means time.Now.
+	// Location is the time zone those steps use; nil means local time.
+	Now      func() time.Time
+	Location *time.Location
+
+	// Env holds the environment variables templates may reference as {{.Env.NAME}};
+	// the controller fills it from its EnvAllowlist only.
+	Env map[string]string
+
+	// Streaming: when StreamChunks is set, the body is written chunk by chunk
+	StreamChunks []string
+	StreamDelay  time.Duration
+
+	// DebugHeaders adds X-Mock-Active-Case and X-Mock-Variables to the response
+	DebugHeaders bool
 }
 
 func NewHandlerExecutor(w http.ResponseWriter, r *http.Request) *HandlerExecutor {
@@ -69,40 +119,235 @@ func (h *HandlerExecutor) Execute(funcs []ResponseFuncConfig) error {
 		if err := h.runFunc(f); err != nil {
 			return err
 		}
+		if h.Halted {
+			break
+		}
 	}
 
 	return nil
 }
 
 func (h *HandlerExecutor) Finalize() {
-	// Apply delays
-	if h.FixedDelay > 0 {
-		time.Sleep(h.FixedDelay)
+	// Apply delays; a client that goes away ends them and gets no response
+	if h.FixedDelay > 0 && !h.wait(h.FixedDelay) {
+		return
 	}
 	if h.RandomWait[1] > 0 {
 		min := h.RandomWait[0]
 		max := h.RandomWait[1]
 		if max > min {
 			sleepTime := time.Duration(rand.Intn(max-min)+min) * time.Millisecond
-			time.Sleep(sleepTime)
+			if !h.wait(sleepTime) {
+				return
+			}
 		}
 	}
+	if h.LatencyProfile[2] > 0 && !h.wait(h.sampleLatency(rand.Float64())) {
+		return
+	}
+
+	if h.DebugHeaders {
+		h.setDebugHeaders()
+	}
+
+	if h.ProxyUpstream != "" {
+		h.writeProxied()
+		return
+	}
 
 	// Apply headers
 	for k, v := range h.Headers {
 		h.ResponseWriter.Header().Set(k, v)
 	}
+	if h.ConnectionClose {
+		h.ResponseWriter.Header().Set("Connection", "close")
+	}
+	if h.bodyContentType != "" && h.ResponseWriter.Header().Get("Content-Type") == "" {
+		h.ResponseWriter.Header().Set("Content-Type", h.bodyContentType)
+	}
 
-	// Write status
-	h.ResponseWriter.WriteHeader(h.StatusCode)
+	if h.StreamChunks != nil {
+		h.ResponseWriter.WriteHeader(h.StatusCode)
+		h.writeStream()
+		return
+	}
 
 	// Write body
 	// Apply template to body one last time if it contains variables?
 	// The requirement says SetJsonBody takes a template string.
 	// So h.Body likely already stores the template string.
 	// We should execute it now.
-	finalBody := h.resolveString(h.Body)
-	h.ResponseWriter.Write([]byte(finalBody))
+	finalBody := []byte(h.resolveString(h.Body))
+	if h.Gzip && acceptsGzip(h.Request) {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		gz.Write(finalBody)
+		gz.Close()
+		finalBody = buf.Bytes()
+		h.ResponseWriter.Header().Set("Content-Encoding", "gzip")
+		h.ResponseWriter.Header().Add("Vary", "Accept-Encoding")
+	}
+
+	if h.NoContentLength {
+		// Flushing the headers before the body stops net/http from computing a Content-Length,
+		// so the body goes out with chunked transfer encoding
+		h.ResponseWriter.Header().Del("Content-Length")
+		h.ResponseWriter.WriteHeader(h.StatusCode)
+		if f, ok := h.ResponseWriter.(http.Flusher); ok {
+			f.Flush()
+		}
+		h.ResponseWriter.Write(finalBody)
+		return
+	}
+
+	// Write status
+	h.ResponseWriter.WriteHeader(h.StatusCode)
+	h.ResponseWriter.Write(finalBody)
+}
+
+// acceptsGzip reports whether the request's Accept-Encoding lists gzip with q > 0
+// (q=0, q=0.0, ... refuse it).
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		fields := strings.Split(part, ";")
+		if !strings.EqualFold(strings.TrimSpace(fields[0]), "gzip") {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			if k, v, ok := strings.Cut(strings.TrimSpace(param), "="); ok && strings.EqualFold(strings.TrimSpace(k), "q") {
+				if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+					q = f
+				}
+			}
+		}
+		if q > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptsMediaType reports whether an Accept header explicitly lists mediaType with q > 0
+// and with the highest quality among the listed types. Parameters other than q are ignored.
+func acceptsMediaType(accept, mediaType string) bool {
+	want := strings.ToLower(strings.TrimSpace(mediaType))
+	found, wantQ, bestOther := false, 0.0, 0.0
+	for _, part := range strings.Split(accept, ",") {
+		fields := strings.Split(part, ";")
+		mt := strings.ToLower(strings.TrimSpace(fields[0]))
+		if mt == "" || strings.Contains(mt, "*") {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			if k, v, ok := strings.Cut(strings.TrimSpace(param), "="); ok && strings.EqualFold(k, "q") {
+				if f, err := strconv.ParseFloat(v, 64); err == nil {
+					q = f
+				}
+			}
+		}
+		if mt == want {
+			found, wantQ = true, q
+		} else if q > bestOther {
+			bestOther = q
+		}
+	}
+	return found && wantQ > 0 && wantQ >= bestOther
+}
+
+// sampleLatency maps u in [0,1) to a delay using a piecewise-linear inverse CDF through
+// the LatencyProfile percentiles: 0 -> p50 -> p95 -> p99, with the top 1% tailing off
+// past p99 by the p95..p99 spread.
+func (h *HandlerExecutor) sampleLatency(u float64) time.Duration {
+	p50, p95, p99 := float64(h.LatencyProfile[0]), float64(h.LatencyProfile[1]), float64(h.LatencyProfile[2])
+	points := []struct{ q, ms float64 }{
+		{0, 0},
+		{0.50, p50},
+		{0.95, p95},
+		{0.99, p99},
+		{1, p99 + (p99 - p95)},
+	}
+	ms := points[len(points)-1].ms
+	for i := 1; i < len(points); i++ {
+		if u < points[i].q {
+			lo, hi := points[i-1], points[i]
+			ms = lo.ms + (u-lo.q)/(hi.q-lo.q)*(hi.ms-lo.ms)
+			break
+		}
+	}
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+// wait sleeps for d unless the request is canceled first (the client disconnected);
+// it reports whether the full delay passed.
+func (h *HandlerExecutor) wait(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-h.Request.Context().Done():
+		return false
+	}
+}
+
+// writeStream writes each chunk and flushes it, pausing StreamDelay between chunks.
+func (h *HandlerExecutor) writeStream() {
+	flusher, _ := h.ResponseWriter.(http.Flusher)
+	for i, chunk := range h.StreamChunks {
+		if i > 0 && h.StreamDelay > 0 && !h.wait(h.StreamDelay) {
+			return
+		}
+		h.ResponseWriter.Write([]byte(h.resolveString(chunk)))
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
+
+// setDebugHeaders reports the matched case and the variables as response headers:
+// X-Mock-Active-Case is empty when no case matched, X-Mock-Variables is a JSON object.
+func (h *HandlerExecutor) setDebugHeaders() {
+	h.ResponseWriter.Header().Set("X-Mock-Active-Case", h.ActiveCase)
+	vars, err := json.Marshal(h.Variables)
+	if err != nil {
+		vars = []byte(fmt.Sprintf("%q", err.Error()))
+	}
+	h.ResponseWriter.Header().Set("X-Mock-Variables", string(vars))
+}
+
+// writeProxied relays the request to ProxyUpstream and writes back its response, with
+// the executor's headers layered over the upstream ones. Upstream failures answer 502.
+func (h *HandlerExecutor) writeProxied() {
+	target, err := url.Parse(h.ProxyUpstream)
+	if err != nil || target.Scheme == "" || target.Host == "" {
+		h.StatusCode = http.StatusBadGateway
+		http.Error(h.ResponseWriter, fmt.Sprintf("invalid upstream URL %q", h.ProxyUpstream), h.StatusCode)
+		return
+	}
+	h.Request.Body = io.NopCloser(bytes.NewReader(h.RawBody))
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(target)
+			pr.Out.Host = target.Host
+		},
+		ModifyResponse: func(resp *http.Response) error {
+			for k, v := range h.Headers {
+				resp.Header.Set(k, v)
+			}
+			if h.ConnectionClose {
+				resp.Header.Set("Connection", "close")
+			}
+			h.StatusCode = resp.StatusCode
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			h.StatusCode = http.StatusBadGateway
+			http.Error(w, fmt.Sprintf("upstream %s: %v", h.ProxyUpstream, err), h.StatusCode)
+		},
+	}
+	proxy.ServeHTTP(h.ResponseWriter, h.Request)
 }
 
 func (h *HandlerExecutor) runFunc(f ResponseFuncConfig) error {
@@ -128,8 +373,18 @@ func (h *HandlerExecutor) resolveString(s string) string {
 	if err != nil {
 		return s // Return raw if parse fails
 	}
+	var data interface{} = h.Variables
+	if h.Env != nil {
+		// Copy rather than store Env in Variables so it never shows up in request logs
+		withEnv := make(map[string]interface{}, len(h.Variables)+1)
+		for k, v := range h.Variables {
+			withEnv[k] = v
+		}
+		withEnv[EnvVar] = h.Env
+		data = withEnv
+	}
 	var buf bytes.Buffer
-	if err := t.Execute(&buf, h.Variables); err != nil {
+	if err := t.Execute(&buf, data); err != nil {
 		return s // Return raw if execute fails
 	}
 	return buf.String()
@@ -235,7 +490,8 @@ func (h *HandlerExecutor) handlePrepareData(f ResponseFuncConfig) error {
 		if arr, ok := val.([]interface{}); ok {
 			actualVal = len(arr)
 		} else {
-			actualVal = -1
+			// Not an array: no length, so ordering conditions never match
+			actualVal = nil
 		}
 
 	case FuncIfRequestJsonObjectLength:
@@ -252,7 +508,8 @@ func (h *HandlerExecutor) handlePrepareData(f ResponseFuncConfig) error {
 		if m, ok := val.(map[string]interface{}); ok {
 			actualVal = len(m)
 		} else {
-			actualVal = -1
+			// Not an object: no length, so ordering conditions never match
+			actualVal = nil
 		}
 
 	case FuncIfRequestJsonType:
@@ -262,7 +519,7 @@ func (h *HandlerExecutor) handlePrepareData(f ResponseFuncConfig) error {
 		// Field, TypeStr, TargetVar, ToBeValue
 		// Implicit condition "Equal" for type check
 		condition = ConditionEqual
-		expectedVal = fmt.Sprintf("%v", args[1])
+		expectedVal = jsonTypeName(fmt.Sprintf("%v", args[1]))
 		targetVar = fmt.Sprintf("%v", args[2])
 		toBeVal = h.resolveArg(args[3])
 
@@ -362,6 +619,99 @@ func (h *HandlerExecutor) handlePrepareData(f ResponseFuncConfig) error {
 		}
 		return nil
 
+	case FuncIfRemoteAddrSetCase:
+		if len(args) < 3 {
+			return nil
+		}
+		condition = fmt.Sprintf("%v", args[0])
+		expectedVal = h.resolveArg(args[1])
+		caseStr := fmt.Sprintf("%v", args[2])
+		actualVal = h.remoteAddr()
+		if h.checkCondition(actualVal, condition, expectedVal) {
+			h.ActiveCase = caseStr
+		}
+		return nil
+
+	case FuncIfAcceptSetCase:
+		if len(args) < 2 {
+			return nil
+		}
+		if acceptsMediaType(h.Request.Header.Get("Accept"), fmt.Sprintf("%v", args[0])) {
+			h.ActiveCase = fmt.Sprintf("%v", args[1])
+		}
+		return nil
+
+	case FuncIfRequestBodySizeSetCase:
+		if len(args) < 3 {
+			return nil
+		}
+		condition = fmt.Sprintf("%v", args[0])
+		expectedVal = h.resolveArg(args[1])
+		caseStr := fmt.Sprintf("%v", args[2])
+		actualVal = len(h.RawBody)
+		if h.checkCondition(actualVal, condition, expectedVal) {
+			h.ActiveCase = caseStr
+		}
+		return nil
+
+	case FuncIfAllSetCase, FuncIfAnySetCase:
+		if len(args) < 2 {
+			return nil
+		}
+		conds, err := toConditions(args[0])
+		if err != nil {
+			return fmt.Errorf("%s: %v", f.Func, err)
+		}
+		matched := 0
+		for _, c := range conds {
+			ok, err := h.matchCondition(c)
+			if err != nil {
+				return fmt.Errorf("%s: %v", f.Func, err)
+			}
+			if ok {
+				matched++
+			}
+		}
+		if len(conds) > 0 && ((f.Func == FuncIfAllSetCase && matched == len(conds)) || (f.Func == FuncIfAnySetCase && matched > 0)) {
+			h.ActiveCase = fmt.Sprintf("%v", args[1])
+		}
+		return nil
+
+	case FuncRequireJsonFields:
+		if len(args) < 2 {
+			return nil
+		}
+		for _, field := range toStringSlice(args[0]) {
+			if isEmptyJSONValue(h.getJSONPath(field)) {
+				h.ActiveCase = fmt.Sprintf("%v", args[1])
+				break
+			}
+		}
+		return nil
+
+	case FuncSetCaseByTimeWindow:
+		if len(args) < 3 {
+			return nil
+		}
+		start, err := parseClockMinutes(fmt.Sprintf("%v", args[0]))
+		if err != nil {
+			return fmt.Errorf("SetCaseByTimeWindow: %v", err)
+		}
+		end, err := parseClockMinutes(fmt.Sprintf("%v", args[1]))
+		if err != nil {
+			return fmt.Errorf("SetCaseByTimeWindow: %v", err)
+		}
+		now := h.now()
+		minute := now.Hour()*60 + now.Minute()
+		inWindow := start <= minute && minute < end
+		if end < start {
+			inWindow = minute >= start || minute < end
+		}
+		if inWindow {
+			h.ActiveCase = fmt.Sprintf("%v", args[2])
+		}
+		return nil
+
 	case FuncIfRequestJsonArrayLengthSetCase:
 		if len(args) < 4 {
 			return nil
@@ -375,7 +725,8 @@ func (h *HandlerExecutor) handlePrepareData(f ResponseFuncConfig) error {
 		if arr, ok := val.([]interface{}); ok {
 			actualVal = len(arr)
 		} else {
-			actualVal = -1
+			// Not an array: no length, so ordering conditions never match
+			actualVal = nil
 		}
 		if h.checkCondition(actualVal, condition, expectedVal) {
 			h.ActiveCase = caseStr
@@ -395,7 +746,8 @@ func (h *HandlerExecutor) handlePrepareData(f ResponseFuncConfig) error {
 		if m, ok := val.(map[string]interface{}); ok {
 			actualVal = len(m)
 		} else {
-			actualVal = -1
+			// Not an object: no length, so ordering conditions never match
+			actualVal = nil
 		}
 		if h.checkCondition(actualVal, condition, expectedVal) {
 			h.ActiveCase = caseStr
@@ -408,7 +760,7 @@ func (h *HandlerExecutor) handlePrepareData(f ResponseFuncConfig) error {
 		}
 		// Field, TypeStr, CaseStr
 		condition = ConditionEqual
-		expectedVal = fmt.Sprintf("%v", args[1])
+		expectedVal = jsonTypeName(fmt.Sprintf("%v", args[1]))
 		caseStr := fmt.Sprintf("%v", args[2])
 
 		fieldPath := fmt.Sprintf("%v", args[0])
@@ -469,6 +821,47 @@ func (h *HandlerExecutor) handlePrepareData(f ResponseFuncConfig) error {
 		targetVar := fmt.Sprintf("%v", args[1])
 		h.Variables[targetVar] = h.Request.URL.Query().Get(queryField)
 		return nil
+
+	case FuncExtractRequestRemoteAddr:
+		if len(args) < 1 {
+			return nil
+		}
+		targetVar := fmt.Sprintf("%v", args[0])
+		h.Variables[targetVar] = h.remoteAddr()
+		return nil
+
+	case FuncEchoRequestHeaders:
+		if len(args) < 1 {
+			return nil
+		}
+		prefix := strings.ToLower(fmt.Sprintf("%v", args[0]))
+		for name, values := range h.Request.Header {
+			if len(values) == 0 || !strings.HasPrefix(strings.ToLower(name), prefix) {
+				continue
+			}
+			h.Variables[headerVarName(name)] = values[0]
+		}
+		return nil
+
+	case FuncRequireBasicAuth:
+		if len(args) < 2 {
+			return nil
+		}
+		user, pass, ok := h.Request.BasicAuth()
+		if !ok || user != fmt.Sprintf("%v", args[0]) || pass != fmt.Sprintf("%v", args[1]) {
+			h.unauthorized(`Basic realm="mock"`)
+		}
+		return nil
+
+	case FuncRequireBearer:
+		if len(args) < 1 {
+			return nil
+		}
+		scheme, token, _ := strings.Cut(h.Request.Header.Get("Authorization"), " ")
+		if !strings.EqualFold(scheme, "Bearer") || token != fmt.Sprintf("%v", args[0]) {
+			h.unauthorized(`Bearer realm="mock"`)
+		}
+		return nil
 	}
 
 	if h.checkCondition(actualVal, condition, expectedVal) {
@@ -478,15 +871,128 @@ func (h *HandlerExecutor) handlePrepareData(f ResponseFuncConfig) error {
 	return nil
 }
 
-func (h *HandlerExecutor) checkCondition(actual interface{}, cond string, expected interface{}) bool {
-	actStr := fmt.Sprintf("%v", actual)
-	expStr := fmt.Sprintf("%v", expected)
+// matchCondition evaluates one Condition of IfAllSetCase / IfAnySetCase against the request.
+// An unknown Source is an error rather than a silent mismatch.
+func (h *HandlerExecutor) matchCondition(c Condition) (bool, error) {
+	var actual interface{}
+	switch c.Source {
+	case SourceHeader:
+		actual = h.Request.Header.Get(c.Field)
+	case SourceQuery:
+		actual = h.Request.URL.Query().Get(c.Field)
+	case SourceJsonBody:
+		actual = h.getJSONPath(c.Field)
+	case SourceXmlBody:
+		actual = h.getXMLPath(c.Field)
+	case SourcePath:
+		actual = h.Request.URL.Path
+	case SourceDynamicVariable:
+		actual = h.Variables[c.Field]
+	case SourceBodySize:
+		actual = len(h.RawBody)
+	default:
+		return false, fmt.Errorf("unknown condition source %q", c.Source)
+	}
+	return h.checkCondition(actual, c.Cond, h.resolveArg(c.Value)), nil
+}
+
+// toConditions decodes the conditions argument, which arrives as []Condition when called
+// directly and as generic JSON after a round trip through the controller.
+func toConditions(i interface{}) ([]Condition, error) {
+	if conds, ok := i.([]Condition); ok {
+		return conds, nil
+	}
+	data, err := json.Marshal(i)
+	if err != nil {
+		return nil, err
+	}
+	var conds []Condition
+	if err := json.Unmarshal(data, &conds); err != nil {
+		return nil, fmt.Errorf("invalid conditions: %v", err)
+	}
+	return conds, nil
+}
+
+// now returns the current time in the executor's Location.
+func (h *HandlerExecutor) now() time.Time {
+	t := time.Now()
+	if h.Now != nil {
+		t = h.Now()
+	}
+	if h.Location != nil {
+		t = t.In(h.Location)
+	}
+	return t
+}
+
+// parseClockMinutes parses "HH:MM" into minutes since midnight.
+func parseClockMinutes(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a HH:MM time", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
 
+// unauthorized replaces the response with a 401 carrying the given WWW-Authenticate
+// challenge and halts the remaining steps. Earlier proxy, delay and encoding steps are
+// undone, so the request is never relayed and the 401 is sent plainly and at once.
+func (h *HandlerExecutor) unauthorized(challenge string) {
+	h.StatusCode = http.StatusUnauthorized
+	h.Headers["WWW-Authenticate"] = challenge
+	h.Headers["Content-Type"] = "text/plain; charset=utf-8"
+	h.Body = "Unauthorized"
+	h.StreamChunks = nil
+	h.StreamDelay = 0
+	h.ProxyUpstream = ""
+	h.FixedDelay = 0
+	h.RandomWait = [2]int{}
+	h.LatencyProfile = [3]int{}
+	h.Gzip = false
+	h.NoContentLength = false
+	h.Halted = true
+}
+
+// headerVarName turns a header name into a template-friendly variable name,
+// e.g. "X-Request-Id" becomes "X_REQUEST_ID" (usable as {{.X_REQUEST_ID}}).
+func headerVarName(name string) string {
+	return strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// remoteAddr returns the client IP without the port.
+// The first X-Forwarded-For entry wins when present (e.g. behind a proxy).
+func (h *HandlerExecutor) remoteAddr() string {
+	if xff := h.Request.Header.Get("X-Forwarded-For"); xff != "" {
+		return strings.TrimSpace(strings.Split(xff, ",")[0])
+	}
+	host, _, err := net.SplitHostPort(h.Request.RemoteAddr)
+	if err != nil {
+		return h.Request.RemoteAddr
+	}
+	return host
+}
+
+// checkCondition compares actual and expected with the semantics of the v1 expectations
+// (pkg/v1/expect_condition.go), adapted to request values that arrive as text:
+//   - Equal/NotEqual compare by value when either side is a number (18 matches "18.0"),
+//     otherwise as text;
+//   - GreaterThan/LessThan(OrEqual) need both sides numeric (numeric strings count);
+//   - Contains/NotContains/StartsWith/EndsWith compare the text forms;
+//   - a missing value (nil) only equals nil and fails every other condition.
+func (h *HandlerExecutor) checkCondition(actual interface{}, cond string, expected interface{}) bool {
 	switch cond {
 	case ConditionEqual:
-		return actStr == expStr
+		return conditionEqual(actual, expected)
 	case ConditionNotEqual:
-		return actStr != expStr
+		return !conditionEqual(actual, expected)
+	}
+	if actual == nil || expected == nil {
+		return false
+	}
+
+	actStr := fmt.Sprintf("%v", actual)
+	expStr := fmt.Sprintf("%v", expected)
+	switch cond {
 	case ConditionContains:
 		return strings.Contains(actStr, expStr)
 	case ConditionNotContains:
@@ -515,6 +1021,23 @@ func (h *HandlerExecutor) checkCondition(actual interface{}, cond string, expect
 	return false
 }
 
+// conditionEqual is ConditionEqual for checkCondition.
+func conditionEqual(actual, expected interface{}) bool {
+	if actual == nil || expected == nil {
+		return actual == nil && expected == nil
+	}
+	_, actIsString := actual.(string)
+	_, expIsString := expected.(string)
+	if !actIsString || !expIsString {
+		actNum, ok1 := tryToFloat(actual)
+		expNum, ok2 := tryToFloat(expected)
+		if ok1 && ok2 {
+			return actNum == expNum
+		}
+	}
+	return fmt.Sprintf("%v", actual) == fmt.Sprintf("%v", expected)
+}
+
 func parseXML(data []byte) *XMLNode {
 	decoder := xml.NewDecoder(bytes.NewReader(data))
 	var root *XMLNode
@@ -676,26 +1199,46 @@ func (h *HandlerExecutor) handleGenerator(f ResponseFuncConfig) error {
 	case FuncGenerateRandomString:
 		length := int(toFloat(args[0]))
 		targetVar := fmt.Sprintf("%v", args[1])
-		h.Variables[targetVar] = randomString(length)
+		h.Variables[targetVar] = randomString(h.random(), length)
 	case FuncGenerateRandomInt:
 		min := int(toFloat(args[0]))
 		max := int(toFloat(args[1]))
 		targetVar := fmt.Sprintf("%v", args[2])
-		h.Variables[targetVar] = rand.Intn(max-min+1) + min
+		h.Variables[targetVar] = h.random().Intn(max-min+1) + min
 	case FuncGenerateRandomIntFixLength:
 		length := int(toFloat(args[0]))
 		targetVar := fmt.Sprintf("%v", args[1])
 		// Not perfect but works for simple case
 		min := int(1 * pow10(length-1))
 		max := int(1*pow10(length) - 1)
-		h.Variables[targetVar] = rand.Intn(max-min+1) + min
+		h.Variables[targetVar] = h.random().Intn(max-min+1) + min
 	case FuncGenerateRandomDecimal:
 		min := toFloat(args[0])
 		max := toFloat(args[1])
 		// maxDecimal := int(toFloat(args[2])) // unused in simple implementation
 		targetVar := fmt.Sprintf("%v", args[3])
-		val := min + rand.Float64()*(max-min)
+		val := min + h.random().Float64()*(max-min)
 		h.Variables[targetVar] = val
+	case FuncGenerateSequence:
+		start := int(toFloat(args[0]))
+		targetVar := fmt.Sprintf("%v", args[1])
+		if h.NextSequence != nil {
+			h.Variables[targetVar] = h.NextSequence(targetVar, start)
+		} else {
+			h.Variables[targetVar] = start
+		}
+	case FuncGenerateFake:
+		if len(args) < 2 {
+			return nil
+		}
+		val, err := fakeValue(h.random(), fmt.Sprintf("%v", args[0]))
+		if err != nil {
+			return fmt.Errorf("GenerateFake: %v", err)
+		}
+		h.Variables[fmt.Sprintf("%v", args[1])] = val
+	case FuncSeedRandomFromRequest:
+		sum := sha256.Sum256([]byte(h.Request.Method + "\n" + h.Request.URL.Path + "\n" + string(h.RawBody)))
+		h.rng = rand.New(rand.NewSource(int64(binary.BigEndian.Uint64(sum[:8]))))
 	case FuncHashedString:
 		fromVar := fmt.Sprintf("%v", args[0])
 		algo := fmt.Sprintf("%v", args[1])
@@ -774,6 +1317,7 @@ func (h *HandlerExecutor) handleSetupResponse(f ResponseFuncConfig) error {
 	caseStr := fmt.Sprintf("%v", args[0])
 	// If ActiveCase is "", it matches "" (default)
 	// If ActiveCase is "CaseA", it matches "CaseA"
+	// This includes delays (SetWait, SetRandomWait, SetLatencyProfile), so each case can carry its own latency.
 	if caseStr != h.ActiveCase {
 		return nil
 	}
@@ -781,29 +1325,107 @@ func (h *HandlerExecutor) handleSetupResponse(f ResponseFuncConfig) error {
 	switch f.Func {
 	case FuncSetJsonBody:
 		h.Body = fmt.Sprintf("%v", args[1])
+		h.bodyContentType = ""
 	case FuncSetXmlBody:
 		h.Body = fmt.Sprintf("%v", args[1])
+		h.bodyContentType = "application/xml"
+	case FuncSetStreamBody:
+		if len(args) < 3 {
+			return nil
+		}
+		h.StreamChunks = toStringSlice(args[1])
+		h.StreamDelay = time.Duration(toFloat(args[2])) * time.Millisecond
+	case FuncSetResponseByBodyHash:
+		if len(args) < 2 {
+			return nil
+		}
+		// Keep the current (default) body when no hash matches
+		if body, ok := toStringMap(args[1])[HashRequestBody(h.RawBody)]; ok {
+			h.Body = body
+		}
 	case FuncSetStatusCode:
 		h.StatusCode = int(toFloat(args[1]))
+	case FuncSetStatusCodeTemplate:
+		resolved := strings.TrimSpace(h.resolveString(fmt.Sprintf("%v", args[1])))
+		code, err := strconv.Atoi(resolved)
+		if err != nil || code < 100 || code > 999 {
+			return fmt.Errorf("SetStatusCodeTemplate: %q is not a valid status code", resolved)
+		}
+		h.StatusCode = code
+	case FuncSetRedirect:
+		if len(args) < 3 {
+			return nil
+		}
+		code := int(toFloat(args[1]))
+		if code < 300 || code > 399 {
+			return fmt.Errorf("SetRedirect: %d is not a redirect status code", code)
+		}
+		h.StatusCode = code
+		h.Headers["Location"] = h.resolveString(fmt.Sprintf("%v", args[2]))
+	case FuncSetProxyPassthrough:
+		if len(args) < 2 {
+			return nil
+		}
+		h.ProxyUpstream = h.resolveString(fmt.Sprintf("%v", args[1]))
 	case FuncSetWait:
 		h.FixedDelay = time.Duration(toFloat(args[1])) * time.Millisecond
 	case FuncSetRandomWait:
 		h.RandomWait[0] = int(toFloat(args[1]))
 		h.RandomWait[1] = int(toFloat(args[2]))
+	case FuncSetLatencyProfile:
+		if len(args) < 4 {
+			return nil
+		}
+		h.LatencyProfile[0] = int(toFloat(args[1]))
+		h.LatencyProfile[1] = int(toFloat(args[2]))
+		h.LatencyProfile[2] = int(toFloat(args[3]))
 	case FuncSetMethod:
 		// Usually response doesn't set method, maybe this is for asserting?
 		// Or maybe it's mimicking? The req says "SetMethod".
 		// Unclear usage for response, ignoring for now or logging.
 	case FuncSetHeader:
-		key := fmt.Sprintf("%v", args[1])
-		val := h.resolveString(fmt.Sprintf("%v", args[2]))
-		h.Headers[key] = val
+		h.setHeader(caseStr, fmt.Sprintf("%v", args[1]), h.resolveString(fmt.Sprintf("%v", args[2])))
+	case FuncSetHeaders:
+		if len(args) < 2 {
+			return nil
+		}
+		headers := toStringMap(args[1])
+		keys := make([]string, 0, len(headers))
+		for k := range headers {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			h.setHeader(caseStr, k, h.resolveString(headers[k]))
+		}
+	case FuncSetGzip:
+		if len(args) < 2 {
+			return nil
+		}
+		enabled, _ := args[1].(bool)
+		h.Gzip = enabled
+	case FuncSetNoContentLength:
+		h.NoContentLength = true
+	case FuncSetConnectionClose:
+		h.ConnectionClose = true
 	case FuncCopyHeaderFromRequest:
 		key := fmt.Sprintf("%v", args[1])
 		val := h.Request.Header.Get(key)
 		if val != "" {
 			h.Headers[key] = val
 		}
+	case FuncCopyHeadersMatching:
+		if len(args) < 2 {
+			return nil
+		}
+		// Prefix match is case-insensitive, like EchoRequestHeaders
+		prefix := strings.ToLower(fmt.Sprintf("%v", args[1]))
+		for name, values := range h.Request.Header {
+			if len(values) == 0 || !strings.HasPrefix(strings.ToLower(name), prefix) {
+				continue
+			}
+			h.Headers[name] = values[0]
+		}
 	}
 	return nil
 }
@@ -844,6 +1466,87 @@ func toFloat(i interface{}) float64 {
 	return 0
 }
 
+// isEmptyJSONValue reports whether a decoded JSON value is absent or empty.
+func isEmptyJSONValue(v interface{}) bool {
+	switch x := v.(type) {
+	case nil:
+		return true
+	case string:
+		return x == ""
+	case []interface{}:
+		return len(x) == 0
+	case map[string]interface{}:
+		return len(x) == 0
+	}
+	return false
+}
+
+// toStringSlice accepts []string (direct calls) or []interface{} (decoded JSON).
+func toStringSlice(i interface{}) []string {
+	switch v := i.(type) {
+	case []string:
+		return v
+	case []interface{}:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			out = append(out, fmt.Sprintf("%v", item))
+		}
+		return out
+	}
+	return nil
+}
+
+// setHeader sets a response header for a SetHeader/SetHeaders step of caseStr.
+func (h *HandlerExecutor) setHeader(caseStr, key, val string) {
+	// A case-specific header wins over the default one for the same key, whichever step runs last
+	canonical := http.CanonicalHeaderKey(key)
+	if caseStr == "" && h.caseHeaders[canonical] {
+		return
+	}
+	if caseStr != "" {
+		if h.caseHeaders == nil {
+			h.caseHeaders = make(map[string]bool)
+		}
+		h.caseHeaders[canonical] = true
+		for k := range h.Headers {
+			if k != key && http.CanonicalHeaderKey(k) == canonical {
+				delete(h.Headers, k)
+			}
+		}
+	}
+	h.Headers[key] = val
+}
+
+// toStringMap accepts map[string]string (direct calls) or map[string]interface{} (decoded JSON).
+func toStringMap(i interface{}) map[string]string {
+	switch v := i.(type) {
+	case map[string]string:
+		return v
+	case map[string]interface{}:
+		out := make(map[string]string, len(v))
+		for k, item := range v {
+			out[k] = fmt.Sprintf("%v", item)
+		}
+		return out
+	}
+	return nil
+}
+
+// HashRequestBody returns the hex sha256 of a normalized request body, as used by SetResponseByBodyHash.
+// JSON bodies are re-encoded compactly with sorted keys so formatting and key order don't matter;
+// other bodies are hashed with surrounding whitespace trimmed.
+func HashRequestBody(body []byte) string {
+	normalized := bytes.TrimSpace(body)
+	var parsed interface{}
+	if len(normalized) > 0 && json.Unmarshal(normalized, &parsed) == nil {
+		if b, err := json.Marshal(parsed); err == nil {
+			normalized = b
+		}
+	}
+	sum := sha256.Sum256(normalized)
+	return hex.EncodeToString(sum[:])
+}
+
 func pow10(n int) float64 {
 	r := 1.0
 	for i := 0; i < n; i++ {
@@ -852,15 +1555,32 @@ func pow10(n int) float64 {
 	return r
 }
 
-func randomString(n int) string {
+// random returns the generators' random source, creating an independently seeded one
+// unless SeedRandomFromRequest already set it.
+func (h *HandlerExecutor) random() *rand.Rand {
+	if h.rng == nil {
+		h.rng = rand.New(rand.NewSource(rand.Int63()))
+	}
+	return h.rng
+}
+
+func randomString(r *rand.Rand, n int) string {
 	const letters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
 	b := make([]byte, n)
 	for i := range b {
-		b[i] = letters[rand.Intn(len(letters))]
+		b[i] = letters[r.Intn(len(letters))]
 	}
 	return string(b)
 }
 
+// jsonTypeName maps the type names accepted by IfRequestJsonType to those of getTypeOf.
+func jsonTypeName(name string) string {
+	if name = strings.ToLower(name); name == "bool" {
+		return "boolean"
+	}
+	return name
+}
+
 func getTypeOf(v interface{}) string {
 	if v == nil {
 		return "null"
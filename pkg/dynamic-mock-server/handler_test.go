@@ -2,11 +2,18 @@ package dynamic_mock_server
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"math/rand"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"sort"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestHandlerExecutor_ExtendedConditions(t *testing.T) {
@@ -553,3 +560,823 @@ func TestHandlerExecutor_XmlBody(t *testing.T) {
 		}
 	})
 }
+
+func TestHandlerExecutor_RemoteAddr(t *testing.T) {
+	t.Run("ExtractRequestRemoteAddr", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "192.168.1.20:54321"
+		h := NewHandlerExecutor(httptest.NewRecorder(), req)
+
+		if err := h.Execute([]ResponseFuncConfig{ExtractRequestRemoteAddr("CLIENT_IP")}); err != nil {
+			t.Fatalf("Execute failed: %v", err)
+		}
+		if h.Variables["CLIENT_IP"] != "192.168.1.20" {
+			t.Errorf("Expected CLIENT_IP=192.168.1.20, got %v", h.Variables["CLIENT_IP"])
+		}
+	})
+
+	t.Run("IfRemoteAddrSetCase_XForwardedFor", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "127.0.0.1:40000"
+		req.Header.Set("X-Forwarded-For", "10.0.0.5, 172.16.0.1")
+		w := httptest.NewRecorder()
+		h := NewHandlerExecutor(w, req)
+
+		steps := []ResponseFuncConfig{
+			SetJsonBody("", "default"),
+			IfRemoteAddrSetCase(ConditionStartsWith, "10.0.", "Internal"),
+			SetJsonBody("Internal", "internal"),
+			ExtractRequestRemoteAddr("CLIENT_IP"),
+		}
+		if err := h.Execute(steps); err != nil {
+			t.Fatalf("Execute failed: %v", err)
+		}
+		if h.ActiveCase != "Internal" {
+			t.Errorf("Expected ActiveCase Internal, got %q", h.ActiveCase)
+		}
+		if h.Body != "internal" {
+			t.Errorf("Expected body 'internal', got %q", h.Body)
+		}
+		if h.Variables["CLIENT_IP"] != "10.0.0.5" {
+			t.Errorf("Expected CLIENT_IP from X-Forwarded-For, got %v", h.Variables["CLIENT_IP"])
+		}
+	})
+
+	t.Run("IfRemoteAddrSetCase_NoMatch", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "127.0.0.1:40000"
+		h := NewHandlerExecutor(httptest.NewRecorder(), req)
+
+		h.Execute([]ResponseFuncConfig{IfRemoteAddrSetCase(ConditionEqual, "10.0.0.5", "Internal")})
+		if h.ActiveCase != "" {
+			t.Errorf("Expected no active case, got %q", h.ActiveCase)
+		}
+	})
+}
+
+func TestHandlerExecutor_RequestHeadersInBody(t *testing.T) {
+	t.Run("ExtractRequestHeader", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Request-ID", "req-123")
+		w := httptest.NewRecorder()
+		h := NewHandlerExecutor(w, req)
+
+		steps := []ResponseFuncConfig{
+			ExtractRequestHeader("X-Request-ID", "REQ_ID"),
+			SetJsonBody("", `{"requestId": "{{.REQ_ID}}"}`),
+		}
+		if err := h.Execute(steps); err != nil {
+			t.Fatalf("Execute failed: %v", err)
+		}
+		h.Finalize()
+
+		if got := w.Body.String(); got != `{"requestId": "req-123"}` {
+			t.Errorf("Unexpected body: %s", got)
+		}
+	})
+
+	t.Run("EchoRequestHeaders", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Request-ID", "req-456")
+		req.Header.Set("X-Tenant", "acme")
+		req.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		h := NewHandlerExecutor(w, req)
+
+		steps := []ResponseFuncConfig{
+			EchoRequestHeaders("x-"),
+			SetJsonBody("", `{"requestId": "{{.X_REQUEST_ID}}", "tenant": "{{.X_TENANT}}"}`),
+		}
+		if err := h.Execute(steps); err != nil {
+			t.Fatalf("Execute failed: %v", err)
+		}
+		h.Finalize()
+
+		if got := w.Body.String(); got != `{"requestId": "req-456", "tenant": "acme"}` {
+			t.Errorf("Unexpected body: %s", got)
+		}
+		if _, ok := h.Variables["AUTHORIZATION"]; ok {
+			t.Error("Expected headers outside the prefix to be skipped")
+		}
+	})
+}
+
+func TestHandlerExecutor_SetResponseByBodyHash(t *testing.T) {
+	bodies := map[string]string{
+		HashRequestBody([]byte(`{"id": 1, "name": "Alice"}`)): `{"result": "alice"}`,
+		HashRequestBody([]byte(`{"id": 2}`)):                  `{"result": "two"}`,
+	}
+
+	run := func(reqBody string) string {
+		req, _ := http.NewRequest("POST", "/", bytes.NewBufferString(reqBody))
+		w := httptest.NewRecorder()
+		h := NewHandlerExecutor(w, req)
+		steps := []ResponseFuncConfig{
+			SetJsonBody("", `{"result": "default"}`),
+			SetResponseByBodyHash("", bodies),
+		}
+		if err := h.Execute(steps); err != nil {
+			t.Fatalf("Execute failed: %v", err)
+		}
+		h.Finalize()
+		return w.Body.String()
+	}
+
+	if got := run(`{"id": 1, "name": "Alice"}`); got != `{"result": "alice"}` {
+		t.Errorf("Unexpected body for first request: %s", got)
+	}
+	if got := run(`{"id": 2}`); got != `{"result": "two"}` {
+		t.Errorf("Unexpected body for second request: %s", got)
+	}
+	// Formatting and key order are normalized away
+	if got := run("{\n  \"name\": \"Alice\",\n  \"id\": 1\n}"); got != `{"result": "alice"}` {
+		t.Errorf("Expected normalized JSON to match, got: %s", got)
+	}
+	if got := run(`{"id": 3}`); got != `{"result": "default"}` {
+		t.Errorf("Expected fallback to default body, got: %s", got)
+	}
+}
+
+func TestHandlerExecutor_SetStatusCodeTemplate(t *testing.T) {
+	t.Run("EchoRequestedStatus", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/?status=418", nil)
+		w := httptest.NewRecorder()
+		h := NewHandlerExecutor(w, req)
+
+		steps := []ResponseFuncConfig{
+			ExtractRequestQuery("status", "WANT_STATUS"),
+			SetStatusCodeTemplate("", "{{.WANT_STATUS}}"),
+		}
+		if err := h.Execute(steps); err != nil {
+			t.Fatalf("Execute failed: %v", err)
+		}
+		h.Finalize()
+
+		if w.Code != 418 {
+			t.Errorf("Expected status 418, got %d", w.Code)
+		}
+	})
+
+	t.Run("InvalidStatus", func(t *testing.T) {
+		for _, status := range []string{"abc", "0", "42", "1000"} {
+			req, _ := http.NewRequest("GET", "/?status="+status, nil)
+			h := NewHandlerExecutor(httptest.NewRecorder(), req)
+
+			steps := []ResponseFuncConfig{
+				ExtractRequestQuery("status", "WANT_STATUS"),
+				SetStatusCodeTemplate("", "{{.WANT_STATUS}}"),
+			}
+			if err := h.Execute(steps); err == nil {
+				t.Errorf("Expected error for status %q", status)
+			}
+		}
+	})
+}
+
+func TestHandlerExecutor_SetLatencyProfile(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/", nil)
+	h := NewHandlerExecutor(httptest.NewRecorder(), req)
+	if err := h.Execute([]ResponseFuncConfig{SetLatencyProfile("", 20, 100, 300)}); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if h.LatencyProfile != [3]int{20, 100, 300} {
+		t.Fatalf("Unexpected LatencyProfile: %v", h.LatencyProfile)
+	}
+
+	// A fixed seed keeps the sampled percentiles, and so the test, deterministic
+	const n = 20000
+	rng := rand.New(rand.NewSource(1))
+	samples := make([]time.Duration, n)
+	for i := range samples {
+		samples[i] = h.sampleLatency(rng.Float64())
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+
+	checks := []struct {
+		q    float64
+		want time.Duration
+	}{
+		{0.50, 20 * time.Millisecond},
+		{0.95, 100 * time.Millisecond},
+		{0.99, 300 * time.Millisecond},
+	}
+	for _, c := range checks {
+		got := samples[int(c.q*n)]
+		lo, hi := c.want*85/100, c.want*115/100
+		if got < lo || got > hi {
+			t.Errorf("p%.0f: expected ~%v, got %v", c.q*100, c.want, got)
+		}
+	}
+}
+
+func TestHandlerExecutor_DelaysRespectActiveCase(t *testing.T) {
+	steps := []ResponseFuncConfig{
+		IfRequestHeaderSetCase("X-Mode", ConditionEqual, "error", "Error"),
+		SetWait("Error", 300),
+		SetRandomWait("Error", 200, 400),
+		SetLatencyProfile("Error", 300, 400, 500),
+		SetStatusCode("Error", 500),
+		SetJsonBody("", `{"ok": true}`),
+	}
+
+	run := func(mode string) (*HandlerExecutor, time.Duration) {
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Mode", mode)
+		h := NewHandlerExecutor(httptest.NewRecorder(), req)
+		if err := h.Execute(steps); err != nil {
+			t.Fatalf("Execute failed: %v", err)
+		}
+		start := time.Now()
+		h.Finalize()
+		return h, time.Since(start)
+	}
+
+	// "ok" path: the Error case's delays must not be applied
+	h, elapsed := run("ok")
+	if h.FixedDelay != 0 || h.RandomWait != [2]int{} || h.LatencyProfile != [3]int{} {
+		t.Errorf("Expected no delays for the inactive case, got fixed=%v random=%v profile=%v", h.FixedDelay, h.RandomWait, h.LatencyProfile)
+	}
+	if elapsed > 100*time.Millisecond {
+		t.Errorf("Expected a fast response for the inactive case, took %v", elapsed)
+	}
+
+	// "error" path: the Error case's delays apply
+	h, elapsed = run("error")
+	if h.FixedDelay != 300*time.Millisecond || h.RandomWait != [2]int{200, 400} {
+		t.Errorf("Expected Error case delays, got fixed=%v random=%v", h.FixedDelay, h.RandomWait)
+	}
+	if elapsed < 500*time.Millisecond {
+		t.Errorf("Expected a slow response for the active case, took %v", elapsed)
+	}
+}
+
+func TestHandlerExecutor_CopyHeadersMatching(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("X-B3-TraceId", "trace-1")
+	req.Header.Set("X-B3-SpanId", "span-1")
+	req.Header.Set("x-b3-sampled", "1")
+	req.Header.Set("X-Request-ID", "req-1")
+	req.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	h := NewHandlerExecutor(w, req)
+
+	steps := []ResponseFuncConfig{
+		CopyHeadersMatching("", "X-B3-"),
+		CopyHeadersMatching("other-case", "Authorization"),
+		SetJsonBody("", "ok"),
+	}
+	if err := h.Execute(steps); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	h.Finalize()
+
+	for name, want := range map[string]string{"X-B3-TraceId": "trace-1", "X-B3-SpanId": "span-1", "X-B3-Sampled": "1"} {
+		if got := w.Header().Get(name); got != want {
+			t.Errorf("Expected %s=%s, got %q", name, want, got)
+		}
+	}
+	for _, name := range []string{"X-Request-ID", "Authorization"} {
+		if got := w.Header().Get(name); got != "" {
+			t.Errorf("Expected %s not to be copied, got %q", name, got)
+		}
+	}
+}
+
+func TestHandlerExecutor_IfRequestBodySizeSetCase(t *testing.T) {
+	steps := []ResponseFuncConfig{
+		IfRequestBodySizeSetCase(ConditionGreaterThan, 1024, "too-large"),
+		SetStatusCode("", 201),
+		SetJsonBody("", `{"status":"created"}`),
+		SetStatusCode("too-large", http.StatusRequestEntityTooLarge),
+		SetJsonBody("too-large", `{"error":"payload too large"}`),
+	}
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"small body", strings.Repeat("a", 100), 201, `{"status":"created"}`},
+		{"at threshold", strings.Repeat("a", 1024), 201, `{"status":"created"}`},
+		{"large body", strings.Repeat("a", 1025), http.StatusRequestEntityTooLarge, `{"error":"payload too large"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("POST", "/upload", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			h := NewHandlerExecutor(w, req)
+			if err := h.Execute(steps); err != nil {
+				t.Fatalf("Execute failed: %v", err)
+			}
+			h.Finalize()
+			if w.Code != tt.wantStatus || w.Body.String() != tt.wantBody {
+				t.Errorf("Expected %d %s, got %d %s", tt.wantStatus, tt.wantBody, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandlerExecutor_EnvTemplate(t *testing.T) {
+	t.Setenv("MOCK_BASE_URL", "http://api.local")
+	t.Setenv("MOCK_SECRET", "hunter2")
+	mc := &MockController{EnvAllowlist: []string{"MOCK_BASE_URL"}}
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+	h := NewHandlerExecutor(w, req)
+	h.Env = mc.allowedEnv()
+
+	steps := []ResponseFuncConfig{
+		SetJsonBody("", `{"next":"{{.Env.MOCK_BASE_URL}}/page/2","secret":"{{.Env.MOCK_SECRET}}"}`),
+	}
+	if err := h.Execute(steps); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	h.Finalize()
+
+	body := w.Body.String()
+	if !strings.Contains(body, `"next":"http://api.local/page/2"`) {
+		t.Errorf("Expected allowlisted env var to render, got %s", body)
+	}
+	if strings.Contains(body, "hunter2") {
+		t.Errorf("Expected non-allowlisted env var not to be exposed, got %s", body)
+	}
+	if _, ok := h.Variables[EnvVar]; ok {
+		t.Errorf("Expected Env not to be stored in Variables")
+	}
+
+	if env := (&MockController{}).allowedEnv(); env != nil {
+		t.Errorf("Expected no env without an allowlist, got %v", env)
+	}
+}
+
+func TestHandlerExecutor_SetHeaderCaseOverridesDefault(t *testing.T) {
+	run := func(mode string, steps []ResponseFuncConfig) http.Header {
+		req, _ := http.NewRequest("GET", "/", nil)
+		if mode != "" {
+			req.Header.Set("X-Mode", mode)
+		}
+		w := httptest.NewRecorder()
+		h := NewHandlerExecutor(w, req)
+		if err := h.Execute(steps); err != nil {
+			t.Fatalf("Execute failed: %v", err)
+		}
+		h.Finalize()
+		return w.Header()
+	}
+
+	defaultFirst := []ResponseFuncConfig{
+		SetHeader("", "X-Plan", "basic"),
+		IfRequestHeaderSetCase("X-Mode", ConditionEqual, "pro", "CaseA"),
+		SetHeader("CaseA", "X-Plan", "pro"),
+		SetJsonBody("", "ok"),
+		SetJsonBody("CaseA", "ok"),
+	}
+	if got := run("pro", defaultFirst).Get("X-Plan"); got != "pro" {
+		t.Errorf("Expected the case header to override the default, got %q", got)
+	}
+	if got := run("", defaultFirst).Get("X-Plan"); got != "basic" {
+		t.Errorf("Expected the default header when the case is inactive, got %q", got)
+	}
+
+	// The case is switched back to the default before the default SetHeader runs
+	caseFirst := []ResponseFuncConfig{
+		IfRequestHeaderSetCase("X-Mode", ConditionEqual, "pro", "CaseA"),
+		SetHeader("CaseA", "x-plan", "pro"),
+		IfRequestHeaderSetCase("X-Mode", ConditionEqual, "pro", ""),
+		SetHeader("", "X-Plan", "basic"),
+		SetJsonBody("", "ok"),
+	}
+	h := run("pro", caseFirst)
+	if got := h.Values("X-Plan"); len(got) != 1 || got[0] != "pro" {
+		t.Errorf("Expected the case header to win regardless of order, got %v", got)
+	}
+	if got := run("", caseFirst).Get("X-Plan"); got != "basic" {
+		t.Errorf("Expected the default header when the case never matched, got %q", got)
+	}
+}
+
+func TestHandlerExecutor_SetCaseByTimeWindow(t *testing.T) {
+	bangkok := time.FixedZone("ICT", 7*60*60)
+	run := func(now time.Time, start, end string) string {
+		req, _ := http.NewRequest("GET", "/", nil)
+		h := NewHandlerExecutor(httptest.NewRecorder(), req)
+		h.Now = func() time.Time { return now }
+		h.Location = bangkok
+		if err := h.Execute([]ResponseFuncConfig{SetCaseByTimeWindow(start, end, "Maintenance")}); err != nil {
+			t.Fatalf("Execute failed: %v", err)
+		}
+		return h.ActiveCase
+	}
+	at := func(hour, min int) time.Time {
+		return time.Date(2024, 5, 1, hour, min, 0, 0, bangkok).UTC()
+	}
+
+	cases := []struct {
+		now        time.Time
+		start, end string
+		want       string
+	}{
+		{at(1, 30), "01:00", "02:00", "Maintenance"},
+		{at(1, 0), "01:00", "02:00", "Maintenance"},
+		{at(2, 0), "01:00", "02:00", ""},
+		{at(0, 59), "01:00", "02:00", ""},
+		{at(23, 15), "22:00", "02:00", "Maintenance"},
+		{at(1, 45), "22:00", "02:00", "Maintenance"},
+		{at(12, 0), "22:00", "02:00", ""},
+	}
+	for _, c := range cases {
+		if got := run(c.now, c.start, c.end); got != c.want {
+			t.Errorf("%s in %s-%s: expected case %q, got %q", c.now.In(bangkok).Format("15:04"), c.start, c.end, c.want, got)
+		}
+	}
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	h := NewHandlerExecutor(httptest.NewRecorder(), req)
+	if err := h.Execute([]ResponseFuncConfig{SetCaseByTimeWindow("9am", "17:00", "X")}); err == nil {
+		t.Errorf("Expected an error for an invalid time")
+	}
+}
+
+func TestHandlerExecutor_GenerateFake(t *testing.T) {
+	uuidRe := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	for i := 0; i < 20; i++ {
+		req, _ := http.NewRequest("GET", "/", nil)
+		h := NewHandlerExecutor(httptest.NewRecorder(), req)
+		steps := []ResponseFuncConfig{
+			GenerateFake(FakeEmail, "email"),
+			GenerateFake(FakeName, "name"),
+			GenerateFake(FakePhone, "phone"),
+			GenerateFake(FakeUUID, "uuid"),
+			GenerateFake(FakeIPv4, "ip"),
+			GenerateFake(FakeWord, "word"),
+		}
+		if err := h.Execute(steps); err != nil {
+			t.Fatalf("Execute failed: %v", err)
+		}
+
+		email := fmt.Sprint(h.Variables["email"])
+		if at := strings.Index(email, "@"); at <= 0 || !strings.Contains(email[at:], ".") {
+			t.Errorf("Expected a plausible email, got %q", email)
+		}
+		if name := fmt.Sprint(h.Variables["name"]); len(strings.Fields(name)) != 2 {
+			t.Errorf("Expected a first and last name, got %q", name)
+		}
+		if phone := fmt.Sprint(h.Variables["phone"]); !strings.HasPrefix(phone, "+") {
+			t.Errorf("Expected a phone number, got %q", phone)
+		}
+		if id := fmt.Sprint(h.Variables["uuid"]); !uuidRe.MatchString(id) {
+			t.Errorf("Expected a v4 UUID, got %q", id)
+		}
+		if ip := net.ParseIP(fmt.Sprint(h.Variables["ip"])); ip == nil || ip.To4() == nil {
+			t.Errorf("Expected an IPv4 address, got %v", h.Variables["ip"])
+		}
+		if word := fmt.Sprint(h.Variables["word"]); word == "" {
+			t.Errorf("Expected a non-empty word")
+		}
+	}
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	h := NewHandlerExecutor(httptest.NewRecorder(), req)
+	if err := h.Execute([]ResponseFuncConfig{GenerateFake("credit-card", "x")}); err == nil {
+		t.Errorf("Expected an error for an unknown kind")
+	}
+}
+
+func TestHandlerExecutor_IfAllAnySetCase(t *testing.T) {
+	premium := []Condition{
+		{Source: SourceHeader, Field: "X-Tier", Cond: ConditionEqual, Value: "gold"},
+		{Source: SourceJsonBody, Field: "order.total", Cond: ConditionGreaterThan, Value: 100},
+	}
+	blocked := []Condition{
+		{Source: SourceQuery, Field: "country", Cond: ConditionEqual, Value: "XX"},
+		{Source: SourcePath, Cond: ConditionStartsWith, Value: "/admin"},
+	}
+	steps := []ResponseFuncConfig{
+		IfAllSetCase(premium, "Premium"),
+		IfAnySetCase(blocked, "Blocked"),
+	}
+
+	// Steps reach the handler as JSON when registered through the controller
+	var roundTripped []ResponseFuncConfig
+	data, _ := json.Marshal(steps)
+	if err := json.Unmarshal(data, &roundTripped); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	run := func(steps []ResponseFuncConfig, target, tier, body string) string {
+		req, _ := http.NewRequest("POST", target, strings.NewReader(body))
+		if tier != "" {
+			req.Header.Set("X-Tier", tier)
+		}
+		h := NewHandlerExecutor(httptest.NewRecorder(), req)
+		if err := h.Execute(steps); err != nil {
+			t.Fatalf("Execute failed: %v", err)
+		}
+		return h.ActiveCase
+	}
+
+	cases := []struct {
+		name, target, tier, body, want string
+	}{
+		{"all match", "/orders", "gold", `{"order":{"total":150}}`, "Premium"},
+		{"header only", "/orders", "gold", `{"order":{"total":50}}`, ""},
+		{"body only", "/orders", "silver", `{"order":{"total":150}}`, ""},
+		{"any: query", "/orders?country=XX", "", `{}`, "Blocked"},
+		{"any: path", "/admin/users", "", `{}`, "Blocked"},
+		{"any: none", "/orders?country=TH", "", `{}`, ""},
+	}
+	for _, c := range cases {
+		for name, s := range map[string][]ResponseFuncConfig{"direct": steps, "json": roundTripped} {
+			if got := run(s, c.target, c.tier, c.body); got != c.want {
+				t.Errorf("%s (%s): expected case %q, got %q", c.name, name, c.want, got)
+			}
+		}
+	}
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	h := NewHandlerExecutor(httptest.NewRecorder(), req)
+	if err := h.Execute([]ResponseFuncConfig{IfAllSetCase(nil, "Empty")}); err != nil || h.ActiveCase != "" {
+		t.Errorf("Expected an empty condition list not to match, got case %q (err=%v)", h.ActiveCase, err)
+	}
+
+	h = NewHandlerExecutor(httptest.NewRecorder(), req)
+	err := h.Execute([]ResponseFuncConfig{IfAnySetCase([]Condition{{Source: "Cookie", Field: "tier", Cond: ConditionEqual, Value: "gold"}}, "Gold")})
+	if err == nil || !strings.Contains(err.Error(), `unknown condition source "Cookie"`) {
+		t.Errorf("Expected an error for an unknown source, got %v", err)
+	}
+}
+
+func TestHandlerExecutor_RequireJsonFields(t *testing.T) {
+	steps := []ResponseFuncConfig{
+		RequireJsonFields([]string{"name", "user.email", "items"}, "ValidationError"),
+		SetStatusCode("", 201),
+		SetStatusCode("ValidationError", 400),
+	}
+
+	cases := []struct {
+		body string
+		want string
+	}{
+		{`{"name":"a","user":{"email":"a@b.c"},"items":[1]}`, ""},
+		{`{"user":{"email":"a@b.c"},"items":[1]}`, "ValidationError"},
+		{`{"name":"a","user":{"email":""},"items":[1]}`, "ValidationError"},
+		{`{"name":"a","user":{"email":"a@b.c"},"items":[]}`, "ValidationError"},
+		{`{"name":null,"user":{"email":"a@b.c"},"items":[1]}`, "ValidationError"},
+		{``, "ValidationError"},
+	}
+	for _, c := range cases {
+		req, _ := http.NewRequest("POST", "/users", strings.NewReader(c.body))
+		h := NewHandlerExecutor(httptest.NewRecorder(), req)
+		if err := h.Execute(steps); err != nil {
+			t.Fatalf("Execute failed: %v", err)
+		}
+		if h.ActiveCase != c.want {
+			t.Errorf("Body %s: expected case %q, got %q", c.body, c.want, h.ActiveCase)
+		}
+		wantStatus := 201
+		if c.want != "" {
+			wantStatus = 400
+		}
+		if h.StatusCode != wantStatus {
+			t.Errorf("Body %s: expected status %d, got %d", c.body, wantStatus, h.StatusCode)
+		}
+	}
+}
+
+func TestHandlerExecutor_SeedRandomFromRequest(t *testing.T) {
+	steps := []ResponseFuncConfig{
+		SeedRandomFromRequest(),
+		GenerateRandomString(16, "token"),
+		GenerateRandomInt(1, 1000000, "n"),
+		GenerateFake(FakeUUID, "id"),
+	}
+	generate := func(path, body string) string {
+		req, _ := http.NewRequest("POST", path, strings.NewReader(body))
+		h := NewHandlerExecutor(httptest.NewRecorder(), req)
+		if err := h.Execute(steps); err != nil {
+			t.Fatalf("Execute failed: %v", err)
+		}
+		return fmt.Sprintf("%v|%v|%v", h.Variables["token"], h.Variables["n"], h.Variables["id"])
+	}
+
+	first := generate("/orders", `{"item":"a"}`)
+	if again := generate("/orders", `{"item":"a"}`); again != first {
+		t.Errorf("Expected the same request to generate the same values, got %q and %q", first, again)
+	}
+	if other := generate("/orders", `{"item":"b"}`); other == first {
+		t.Errorf("Expected a different body to generate different values, got %q twice", first)
+	}
+	if other := generate("/invoices", `{"item":"a"}`); other == first {
+		t.Errorf("Expected a different path to generate different values, got %q twice", first)
+	}
+}
+
+func TestHandlerExecutor_SetHeaders(t *testing.T) {
+	// Round-trip through JSON as the control API does, so the map arrives as map[string]interface{}
+	data, err := json.Marshal([]ResponseFuncConfig{
+		ExtractRequestHeader("X-Request-ID", "REQ_ID"),
+		GenerateRandomString(12, "TXN"),
+		IfRequestHeaderSetCase("X-Mode", ConditionEqual, "retry", "RETRY"),
+		SetHeaders("", map[string]string{
+			"X-Transaction-ID": "txn-{{.TXN}}",
+			"X-Request-ID":     "{{.REQ_ID}}",
+			"Cache-Control":    "no-store",
+			"Retry-After":      "0",
+		}),
+		SetHeaders("RETRY", map[string]string{"Retry-After": "30"}),
+		SetJsonBody("", `{"txn":"{{.TXN}}"}`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var steps []ResponseFuncConfig
+	if err := json.Unmarshal(data, &steps); err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(mode string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/pay", nil)
+		req.Header.Set("X-Request-ID", "req-789")
+		req.Header.Set("X-Mode", mode)
+		w := httptest.NewRecorder()
+		h := NewHandlerExecutor(w, req)
+		if err := h.Execute(steps); err != nil {
+			t.Fatalf("Execute failed: %v", err)
+		}
+		h.Finalize()
+		return w
+	}
+
+	w := run("normal")
+	var body struct{ Txn string }
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || len(body.Txn) != 12 {
+		t.Fatalf("Unexpected body %s (%v)", w.Body.String(), err)
+	}
+	want := map[string]string{
+		"X-Transaction-ID": "txn-" + body.Txn,
+		"X-Request-ID":     "req-789",
+		"Cache-Control":    "no-store",
+		"Retry-After":      "0",
+	}
+	for k, v := range want {
+		if got := w.Header().Get(k); got != v {
+			t.Errorf("Header %s: expected %q, got %q", k, v, got)
+		}
+	}
+
+	if got := run("retry").Header().Get("Retry-After"); got != "30" {
+		t.Errorf("Expected the case header to win, got Retry-After %q", got)
+	}
+}
+
+func TestHandlerExecutor_ConditionsBySource(t *testing.T) {
+	cases := []struct {
+		cond     string
+		actual   string // header and query value
+		json     string // JSON body literal for the same value
+		expected interface{}
+		want     bool
+	}{
+		{ConditionEqual, "18", `18`, 18, true},
+		{ConditionEqual, "18", `18`, "18", true},
+		{ConditionEqual, "gold", `"gold"`, "gold", true},
+		{ConditionEqual, "gold", `"gold"`, "silver", false},
+		{ConditionNotEqual, "gold", `"gold"`, "silver", true},
+		{ConditionNotEqual, "18", `18`, 18, false},
+		{ConditionGreaterThan, "21", `21`, 18, true},
+		{ConditionGreaterThan, "18", `18`, 18, false},
+		{ConditionGreaterThanOrEqual, "18", `18`, 18, true},
+		{ConditionGreaterThanOrEqual, "17", `17`, 18, false},
+		{ConditionLessThan, "9.5", `9.5`, 10, true},
+		{ConditionLessThan, "10", `10`, 10, false},
+		{ConditionLessThanOrEqual, "10", `10`, 10, true},
+		{ConditionLessThanOrEqual, "abc", `"abc"`, 10, false},
+		{ConditionContains, "alice@example.com", `"alice@example.com"`, "@example", true},
+		{ConditionContains, "alice@test.org", `"alice@test.org"`, "@example", false},
+		{ConditionNotContains, "alice@test.org", `"alice@test.org"`, "@example", true},
+		{ConditionStartsWith, "ORD-123", `"ORD-123"`, "ORD-", true},
+		{ConditionStartsWith, "INV-123", `"INV-123"`, "ORD-", false},
+		{ConditionEndsWith, "report.pdf", `"report.pdf"`, ".pdf", true},
+		{ConditionEndsWith, "report.csv", `"report.csv"`, ".pdf", false},
+	}
+
+	type source struct {
+		name  string
+		step  func(cond string, expected interface{}) ResponseFuncConfig
+		build func(actual, json string) *http.Request
+	}
+	sources := []source{
+		{
+			name: "Header",
+			step: func(cond string, expected interface{}) ResponseFuncConfig {
+				return IfRequestHeaderSetCase("X-Val", cond, fmt.Sprintf("%v", expected), "Match")
+			},
+			build: func(actual, _ string) *http.Request {
+				req, _ := http.NewRequest("GET", "/", nil)
+				req.Header.Set("X-Val", actual)
+				return req
+			},
+		},
+		{
+			name: "Query",
+			step: func(cond string, expected interface{}) ResponseFuncConfig {
+				return IfRequestQuerySetCase("v", cond, fmt.Sprintf("%v", expected), "Match")
+			},
+			build: func(actual, _ string) *http.Request {
+				req, _ := http.NewRequest("GET", "/?v="+url.QueryEscape(actual), nil)
+				return req
+			},
+		},
+		{
+			name: "JsonBody",
+			step: func(cond string, expected interface{}) ResponseFuncConfig {
+				return IfRequestJsonBodySetCase("v", cond, expected, "Match")
+			},
+			build: func(_, json string) *http.Request {
+				req, _ := http.NewRequest("POST", "/", strings.NewReader(`{"v":`+json+`}`))
+				return req
+			},
+		},
+	}
+
+	for _, src := range sources {
+		for _, c := range cases {
+			// Round-trip through JSON as the control API does
+			data, _ := json.Marshal([]ResponseFuncConfig{src.step(c.cond, c.expected)})
+			var steps []ResponseFuncConfig
+			if err := json.Unmarshal(data, &steps); err != nil {
+				t.Fatal(err)
+			}
+			h := NewHandlerExecutor(httptest.NewRecorder(), src.build(c.actual, c.json))
+			if err := h.Execute(steps); err != nil {
+				t.Fatalf("Execute failed: %v", err)
+			}
+			if got := h.ActiveCase == "Match"; got != c.want {
+				t.Errorf("%s: %q %s %v = %v, want %v", src.name, c.actual, c.cond, c.expected, got, c.want)
+			}
+		}
+	}
+
+	// A JSON number equals the same number in another spelling; header and query values are
+	// strings and compare as text
+	req, _ := http.NewRequest("POST", "/", strings.NewReader(`{"v":18.0}`))
+	h := NewHandlerExecutor(httptest.NewRecorder(), req)
+	if err := h.Execute([]ResponseFuncConfig{IfRequestJsonBodySetCase("v", ConditionEqual, 18, "Match")}); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if h.ActiveCase != "Match" {
+		t.Errorf("Expected 18.0 to equal 18, got case %q", h.ActiveCase)
+	}
+
+	// A missing value only equals nothing
+	req, _ = http.NewRequest("POST", "/", strings.NewReader(`{"other":1}`))
+	for _, c := range []struct {
+		cond string
+		want bool
+	}{{ConditionNotEqual, true}, {ConditionEqual, false}, {ConditionContains, false}, {ConditionLessThan, false}} {
+		h := NewHandlerExecutor(httptest.NewRecorder(), req)
+		if err := h.Execute([]ResponseFuncConfig{IfRequestJsonBodySetCase("missing", c.cond, "nil", "Match")}); err != nil {
+			t.Fatalf("Execute failed: %v", err)
+		}
+		if got := h.ActiveCase == "Match"; got != c.want {
+			t.Errorf("missing field %s: got %v, want %v", c.cond, got, c.want)
+		}
+	}
+}
+
+func TestHandlerExecutor_SetXmlBodyContentType(t *testing.T) {
+	run := func(steps ...ResponseFuncConfig) *httptest.ResponseRecorder {
+		t.Helper()
+		req, _ := http.NewRequest("GET", "/users/42", nil)
+		w := httptest.NewRecorder()
+		h := NewHandlerExecutor(w, req)
+		h.Variables["ID"] = "42"
+		if err := h.Execute(steps); err != nil {
+			t.Fatalf("Execute failed: %v", err)
+		}
+		h.Finalize()
+		return w
+	}
+
+	w := run(SetXmlBody("", `<user id="{{.ID}}"/>`))
+	if got := w.Body.String(); got != `<user id="42"/>` {
+		t.Errorf("Expected templated XML body, got %s", got)
+	}
+	if got := w.Header().Get("Content-Type"); got != "application/xml" {
+		t.Errorf("Expected Content-Type application/xml, got %q", got)
+	}
+
+	// An explicit header wins, whichever step runs first
+	w = run(SetXmlBody("", `<user/>`), SetHeader("", "Content-Type", "text/xml; charset=utf-8"))
+	if got := w.Header().Get("Content-Type"); got != "text/xml; charset=utf-8" {
+		t.Errorf("Expected the explicit Content-Type, got %q", got)
+	}
+
+	// A later JSON body drops the implied XML type
+	w = run(SetXmlBody("", `<user/>`), SetJsonBody("", `{"id":"{{.ID}}"}`))
+	if got := w.Header().Get("Content-Type"); got == "application/xml" {
+		t.Errorf("Expected no XML Content-Type for a JSON body, got %q", got)
+	}
+}
@@ -61,3 +61,37 @@ func (l *Logger) Close() {
 		l.file.Close()
 	}
 }
+
+// RequestRecorder appends every request received on a mock port to a JSON-lines file,
+// one RecordedRequest per line, so what the app sent can be inspected after a run.
+type RequestRecorder struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+	file    *os.File
+}
+
+func NewRequestRecorder(filename string) (*RequestRecorder, error) {
+	f, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &RequestRecorder{
+		file:    f,
+		encoder: json.NewEncoder(f),
+	}, nil
+}
+
+func (r *RequestRecorder) Record(req RecordedRequest) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.encoder.Encode(req); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to record request: %v\n", err)
+	}
+}
+
+func (r *RequestRecorder) Close() {
+	if r.file != nil {
+		r.file.Close()
+	}
+}
@@ -1,5 +1,7 @@
 package dynamic_mock_server
 
+import "time"
+
 // ResponseFuncConfig represents the JSON structure for a response function configuration
 type ResponseFuncConfig struct {
 	Group string        `json:"group"`
@@ -15,6 +17,60 @@ type RegisterRouteRequest struct {
 	ResponseFunc []ResponseFuncConfig `json:"responseFunc"`
 }
 
+// NotFoundResponse customizes the response for unmatched requests on a port.
+type NotFoundResponse struct {
+	Port       int    `json:"port"`
+	StatusCode int    `json:"statusCode"`
+	Body       string `json:"body"`
+}
+
+// Port modes for SetPortModeRequest.Mode
+const (
+	PortModeNormal      = "normal"
+	PortModeMaintenance = "maintenance"
+)
+
+// SetPortModeRequest represents the body for /setPortMode.
+type SetPortModeRequest struct {
+	Port int    `json:"port"`
+	Mode string `json:"mode"`
+}
+
+// Condition is one request check combined by IfAllSetCase / IfAnySetCase.
+// Source picks what is checked (one of the Source* constants); Field names the header,
+// query parameter, JSON/XML path or dynamic variable (unused for SourcePath and SourceBodySize).
+// Cond is one of the Condition* constants and Value may use templates.
+type Condition struct {
+	Source string      `json:"source"`
+	Field  string      `json:"field,omitempty"`
+	Cond   string      `json:"cond"`
+	Value  interface{} `json:"value"`
+}
+
+// Sources for Condition.Source
+const (
+	SourceHeader          = "Header"
+	SourceQuery           = "Query"
+	SourceJsonBody        = "JsonBody"
+	SourceXmlBody         = "XmlBody"
+	SourcePath            = "Path"
+	SourceDynamicVariable = "DynamicVariable"
+	SourceBodySize        = "BodySize"
+)
+
+// RecordedRequest is one line of a RequestRecorder file.
+type RecordedRequest struct {
+	Timestamp time.Time           `json:"timestamp"`
+	Port      int                 `json:"port"`
+	Method    string              `json:"method"`
+	Path      string              `json:"path"`
+	Query     string              `json:"query,omitempty"`
+	Headers   map[string][]string `json:"headers"`
+	Body      string              `json:"body"`
+	// BodyTruncated is set on interaction log entries whose body was cut to 64 KiB.
+	BodyTruncated bool `json:"bodyTruncated,omitempty"`
+}
+
 // Constants for Response Func Groups
 const (
 	GroupPrepareData     = "PrepareData"
@@ -38,6 +94,13 @@ const (
 	FuncIfRequestQuerySetCase    = "IfRequestQuerySetCase"
 	FuncIfDynamicVariable        = "IfDynamicVariable"
 	FuncIfDynamicVariableSetCase = "IfDynamicVariableSetCase"
+	FuncIfRemoteAddrSetCase      = "IfRemoteAddrSetCase"
+	FuncIfRequestBodySizeSetCase = "IfRequestBodySizeSetCase"
+	FuncIfAcceptSetCase          = "IfAcceptSetCase"
+	FuncSetCaseByTimeWindow      = "SetCaseByTimeWindow"
+	FuncIfAllSetCase             = "IfAllSetCase"
+	FuncIfAnySetCase             = "IfAnySetCase"
+	FuncRequireJsonFields        = "RequireJsonFields"
 
 	// JSON checks
 	FuncIfRequestJsonArrayLength         = "IfRequestJsonArrayLength"
@@ -47,17 +110,24 @@ const (
 	FuncIfRequestJsonType                = "IfRequestJsonType"
 	FuncIfRequestJsonTypeSetCase         = "IfRequestJsonTypeSetCase"
 
-	FuncExtractRequestHeader   = "ExtractRequestHeader"
-	FuncExtractRequestJsonBody = "ExtractRequestJsonBody"
-	FuncExtractRequestXmlBody  = "ExtractRequestXmlBody"
-	FuncExtractRequestPath     = "ExtractRequestPath"
-	FuncExtractRequestQuery    = "ExtractRequestQuery"
+	FuncExtractRequestHeader     = "ExtractRequestHeader"
+	FuncExtractRequestJsonBody   = "ExtractRequestJsonBody"
+	FuncExtractRequestXmlBody    = "ExtractRequestXmlBody"
+	FuncExtractRequestPath       = "ExtractRequestPath"
+	FuncExtractRequestQuery      = "ExtractRequestQuery"
+	FuncExtractRequestRemoteAddr = "ExtractRequestRemoteAddr"
+	FuncEchoRequestHeaders       = "EchoRequestHeaders"
+	FuncRequireBasicAuth         = "RequireBasicAuth"
+	FuncRequireBearer            = "RequireBearer"
 
 	// Generator
 	FuncGenerateRandomString       = "GenerateRandomString"
 	FuncGenerateRandomInt          = "GenerateRandomInt"
 	FuncGenerateRandomIntFixLength = "GenerateRandomIntFixLength"
 	FuncGenerateRandomDecimal      = "GenerateRandomDecimal"
+	FuncGenerateSequence           = "GenerateSequence"
+	FuncGenerateFake               = "GenerateFake"
+	FuncSeedRandomFromRequest      = "SeedRandomFromRequest"
 	FuncHashedString               = "HashedString"
 
 	// DynamicVariable
@@ -70,12 +140,23 @@ const (
 	// SetupResponse
 	FuncSetJsonBody           = "SetJsonBody"
 	FuncSetXmlBody            = "SetXmlBody"
+	FuncSetStreamBody         = "SetStreamBody"
+	FuncSetResponseByBodyHash = "SetResponseByBodyHash"
 	FuncSetStatusCode         = "SetStatusCode"
+	FuncSetStatusCodeTemplate = "SetStatusCodeTemplate"
+	FuncSetRedirect           = "SetRedirect"
+	FuncSetProxyPassthrough   = "SetProxyPassthrough"
 	FuncSetWait               = "SetWait"
 	FuncSetRandomWait         = "SetRandomWait"
+	FuncSetLatencyProfile     = "SetLatencyProfile"
 	FuncSetMethod             = "SetMethod"
 	FuncSetHeader             = "SetHeader"
+	FuncSetHeaders            = "SetHeaders"
+	FuncSetGzip               = "SetGzip"
+	FuncSetNoContentLength    = "SetNoContentLength"
+	FuncSetConnectionClose    = "SetConnectionClose"
 	FuncCopyHeaderFromRequest = "CopyHeaderFromRequest"
+	FuncCopyHeadersMatching   = "CopyHeadersMatching"
 )
 
 // Conditions
@@ -91,3 +172,20 @@ const (
 	ConditionGreaterThanOrEqual = "GreaterThanOrEqual"
 	ConditionLessThanOrEqual    = "LessThanOrEqual"
 )
+
+// WildcardVar holds the path tail matched by a "/prefix/*" route (e.g. "b/c.png" for /static/b/c.png).
+const WildcardVar = "WILDCARD"
+
+// EnvVar is the template key under which allowlisted environment variables are exposed
+// (e.g. {{.Env.BASE_URL}}). It takes precedence over a dynamic variable of the same name.
+const EnvVar = "Env"
+
+// Kinds of value produced by GenerateFake.
+const (
+	FakeEmail = "email"
+	FakeName  = "name"
+	FakePhone = "phone"
+	FakeUUID  = "uuid"
+	FakeIPv4  = "ipv4"
+	FakeWord  = "word"
+)
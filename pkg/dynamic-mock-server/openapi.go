@@ -0,0 +1,181 @@
+package dynamic_mock_server
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// openAPISpec is the subset of an OpenAPI 3 document needed to stub its operations.
+type openAPISpec struct {
+	Paths map[string]openAPIPathItem `yaml:"paths"`
+}
+
+type openAPIPathItem struct {
+	Get     *openAPIOperation `yaml:"get"`
+	Put     *openAPIOperation `yaml:"put"`
+	Post    *openAPIOperation `yaml:"post"`
+	Delete  *openAPIOperation `yaml:"delete"`
+	Options *openAPIOperation `yaml:"options"`
+	Head    *openAPIOperation `yaml:"head"`
+	Patch   *openAPIOperation `yaml:"patch"`
+}
+
+type openAPIOperation struct {
+	Responses map[string]openAPIResponse `yaml:"responses"`
+}
+
+type openAPIResponse struct {
+	Content map[string]openAPIMediaType `yaml:"content"`
+}
+
+type openAPIMediaType struct {
+	Example  interface{}               `yaml:"example"`
+	Examples map[string]openAPIExample `yaml:"examples"`
+}
+
+type openAPIExample struct {
+	Value interface{} `yaml:"value"`
+}
+
+// operations returns the item's operations keyed by HTTP method.
+func (p openAPIPathItem) operations() map[string]*openAPIOperation {
+	ops := map[string]*openAPIOperation{
+		"GET": p.Get, "PUT": p.Put, "POST": p.Post, "DELETE": p.Delete,
+		"OPTIONS": p.Options, "HEAD": p.Head, "PATCH": p.Patch,
+	}
+	for method, op := range ops {
+		if op == nil {
+			delete(ops, method)
+		}
+	}
+	return ops
+}
+
+// RegisterFromOpenAPI reads an OpenAPI 3 document (YAML or JSON) and registers a route
+// for every operation, answering with its happy-path response: the lowest declared 2xx
+// status code and that response's JSON example ("example", or else the first of
+// "examples" by name). Path templates such as /users/{id} map onto {name} segments.
+// Operations without a 2xx response are not registered.
+func (c *Client) RegisterFromOpenAPI(port int, specPath string) error {
+	data, err := os.ReadFile(specPath)
+	if err != nil {
+		return err
+	}
+	routes, err := openAPIRoutes(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", specPath, err)
+	}
+	for _, r := range routes {
+		if err := c.RegisterRoute(port, r.Method, r.Path, r.Steps); err != nil {
+			return fmt.Errorf("%s %s: %w", r.Method, r.Path, err)
+		}
+	}
+	return nil
+}
+
+type openAPIRoute struct {
+	Method string
+	Path   string
+	Steps  []ResponseFuncConfig
+}
+
+// openAPIRoutes builds the routes for a spec, sorted by path then method.
+func openAPIRoutes(data []byte) ([]openAPIRoute, error) {
+	var spec openAPISpec
+	if err := yaml.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI document: %w", err)
+	}
+
+	paths := make([]string, 0, len(spec.Paths))
+	for p := range spec.Paths {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	var routes []openAPIRoute
+	for _, path := range paths {
+		ops := spec.Paths[path].operations()
+		methods := make([]string, 0, len(ops))
+		for m := range ops {
+			methods = append(methods, m)
+		}
+		sort.Strings(methods)
+		for _, method := range methods {
+			steps, ok, err := openAPISteps(ops[method])
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %w", method, path, err)
+			}
+			if ok {
+				routes = append(routes, openAPIRoute{Method: method, Path: path, Steps: steps})
+			}
+		}
+	}
+	return routes, nil
+}
+
+// openAPISteps returns the steps serving op's happy-path response, or false when op
+// declares no 2xx response.
+func openAPISteps(op *openAPIOperation) ([]ResponseFuncConfig, bool, error) {
+	status := 0
+	for code := range op.Responses {
+		n, err := strconv.Atoi(code)
+		if err != nil || n < 200 || n > 299 {
+			continue
+		}
+		if status == 0 || n < status {
+			status = n
+		}
+	}
+	if status == 0 {
+		return nil, false, nil
+	}
+
+	steps := []ResponseFuncConfig{SetStatusCode("", status)}
+	mediaType, example, ok := openAPIJSONExample(op.Responses[strconv.Itoa(status)])
+	if !ok {
+		return steps, true, nil
+	}
+	body, err := json.Marshal(example)
+	if err != nil {
+		return nil, false, fmt.Errorf("example for %d is not JSON-encodable: %w", status, err)
+	}
+	steps = append(steps,
+		SetHeader("", "Content-Type", mediaType),
+		SetJsonBody("", string(body)),
+	)
+	return steps, true, nil
+}
+
+// openAPIJSONExample returns the first JSON media type of resp that carries an example.
+func openAPIJSONExample(resp openAPIResponse) (string, interface{}, bool) {
+	mediaTypes := make([]string, 0, len(resp.Content))
+	for mt := range resp.Content {
+		if strings.Contains(strings.ToLower(mt), "json") {
+			mediaTypes = append(mediaTypes, mt)
+		}
+	}
+	sort.Strings(mediaTypes)
+	for _, mt := range mediaTypes {
+		content := resp.Content[mt]
+		if content.Example != nil {
+			return mt, content.Example, true
+		}
+		names := make([]string, 0, len(content.Examples))
+		for name := range content.Examples {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if v := content.Examples[name].Value; v != nil {
+				return mt, v, true
+			}
+		}
+	}
+	return "", nil, false
+}
@@ -1,10 +1,16 @@
 package dynamic_mock_server
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -16,11 +22,78 @@ type MockServerInstance struct {
 
 type MockController struct {
 	ControlPort int
-	Servers     map[int]*MockServerInstance
+	// Host is the interface the control and mock servers bind to (e.g. "127.0.0.1").
+	// Empty binds all interfaces.
+	Host    string
+	Servers map[int]*MockServerInstance
 	// Routes: Port -> Method -> Path -> Steps
 	Routes map[int]map[string]map[string][]ResponseFuncConfig
+	// NotFound: Port -> custom response for unmatched requests (default is http.NotFound)
+	NotFound map[int]NotFoundResponse
+	// Modes: Port -> PortModeMaintenance for ports answering every request with 503; absent means normal
+	Modes  map[int]string
 	mu     sync.RWMutex
 	Logger *Logger
+	// Recorder, when set, receives every request made to a mock port (matched or not)
+	Recorder *RequestRecorder
+	// EnvAllowlist names the environment variables response templates may read as {{.Env.NAME}}.
+	// Variables not listed are never exposed; when empty, .Env is not available at all.
+	EnvAllowlist []string
+	// Clock, when set, replaces time.Now for time-based steps such as SetCaseByTimeWindow.
+	Clock func() time.Time
+	// Location is the time zone of time-based steps; nil means local time.
+	Location *time.Location
+	// DebugHeaders adds X-Mock-Active-Case and X-Mock-Variables to every step-based response,
+	// showing which case matched and the variables at the end of the steps.
+	DebugHeaders bool
+	// sequences holds the GenerateSequence counters of each route, cleared when the route is re-registered or reset
+	sequences map[sequenceKey]int
+	// funcRoutes holds the Go handlers of routes registered with RegisterFuncRoute; their
+	// paths are also entered in Routes (with no steps) so they take part in route matching
+	funcRoutes map[routeKey]FuncRouteHandler
+	// interactions lists the requests received on every mock port, oldest first (see /interactions)
+	interactions   []RecordedRequest
+	interactionsMu sync.Mutex
+}
+
+// maxInteractions caps the in-memory interaction log; the oldest entries are dropped first.
+const maxInteractions = 10000
+
+// maxInteractionBodyBytes caps each body kept in the in-memory interaction log, so large
+// uploads don't pile up; the Recorder file still gets the full body.
+const maxInteractionBodyBytes = 64 << 10
+
+// sequenceKey identifies one GenerateSequence counter: a registered route and the target variable.
+type sequenceKey struct {
+	Port   int
+	Method string
+	Path   string
+	Var    string
+}
+
+// routeKey identifies a registered route.
+type routeKey struct {
+	Port   int
+	Method string
+	Path   string
+}
+
+// FuncRouteHandler builds the response of a route registered with RegisterFuncRoute.
+// A zero status means 200; path variables captured by {name} segments are available
+// through r.PathValue.
+type FuncRouteHandler func(r *http.Request) (status int, headers map[string]string, body []byte)
+
+// validMethods is the set of HTTP methods a route may be registered for.
+var validMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodConnect: true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
 }
 
 func NewMockController(controlPort int, logger *Logger) *MockController {
@@ -28,23 +101,49 @@ func NewMockController(controlPort int, logger *Logger) *MockController {
 		ControlPort: controlPort,
 		Servers:     make(map[int]*MockServerInstance),
 		Routes:      make(map[int]map[string]map[string][]ResponseFuncConfig),
+		NotFound:    make(map[int]NotFoundResponse),
+		Modes:       make(map[int]string),
 		Logger:      logger,
+		sequences:   make(map[sequenceKey]int),
+		funcRoutes:  make(map[routeKey]FuncRouteHandler),
 	}
 }
 
+// allowedEnv returns the current values of the allowlisted environment variables, or nil
+// when no allowlist is configured. Unset variables are included as empty strings.
+func (mc *MockController) allowedEnv() map[string]string {
+	if len(mc.EnvAllowlist) == 0 {
+		return nil
+	}
+	env := make(map[string]string, len(mc.EnvAllowlist))
+	for _, name := range mc.EnvAllowlist {
+		env[name] = os.Getenv(name)
+	}
+	return env
+}
+
+// addr returns the listen address for a port on the configured Host.
+func (mc *MockController) addr(port int) string {
+	return net.JoinHostPort(mc.Host, strconv.Itoa(port))
+}
+
 func (mc *MockController) Start() error {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/registerRoute", mc.handleRegisterRoute)
 	mux.HandleFunc("/resetPort", mc.handleResetPort)
 	mux.HandleFunc("/resetAll", mc.handleResetAll)
+	mux.HandleFunc("/setNotFoundResponse", mc.handleSetNotFoundResponse)
+	mux.HandleFunc("/setPortMode", mc.handleSetPortMode)
+	mux.HandleFunc("/describeRoute", mc.handleDescribeRoute)
+	mux.HandleFunc("/interactions", mc.handleInteractions)
 	mux.HandleFunc("/", mc.handleNotFound)
 
 	server := &http.Server{
-		Addr:    fmt.Sprintf(":%d", mc.ControlPort),
+		Addr:    mc.addr(mc.ControlPort),
 		Handler: mux,
 	}
 
-	mc.Logger.Log("ControlServerStart", 0, fmt.Sprintf("Starting control server on port %d", mc.ControlPort))
+	mc.Logger.Log("ControlServerStart", 0, fmt.Sprintf("Starting control server on %s", mc.addr(mc.ControlPort)))
 	return server.ListenAndServe()
 }
 
@@ -61,8 +160,15 @@ func (mc *MockController) handleRegisterRoute(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	// Catch typos like "GET " or "POSTT" that would register an unreachable route
+	if !validMethods[req.Method] {
+		msg := fmt.Sprintf("Invalid method %q: must be one of GET, HEAD, POST, PUT, PATCH, DELETE, CONNECT, OPTIONS, TRACE", req.Method)
+		mc.Logger.Log("RegisterRouteError", time.Since(start), msg)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
 	mc.mu.Lock()
-	defer mc.mu.Unlock()
 
 	// Ensure route structure exists
 	if _, ok := mc.Routes[req.Port]; !ok {
@@ -72,16 +178,27 @@ func (mc *MockController) handleRegisterRoute(w http.ResponseWriter, r *http.Req
 		mc.Routes[req.Port][req.Method] = make(map[string][]ResponseFuncConfig)
 	}
 
-	// Register/Replace route
+	// Register/Replace route. The slice is never mutated after this point;
+	// a re-registration swaps in a new slice, so in-flight requests keep their snapshot.
 	mc.Routes[req.Port][req.Method][req.Path] = req.ResponseFunc
+	delete(mc.funcRoutes, routeKey{Port: req.Port, Method: req.Method, Path: req.Path})
+	for k := range mc.sequences {
+		if k.Port == req.Port && k.Method == req.Method && k.Path == req.Path {
+			delete(mc.sequences, k)
+		}
+	}
 
-	// Check if server exists, if not start it
+	// Check if server exists, if not start it (startMockServerLocked only spawns the listener goroutine)
+	var startErr error
 	if _, ok := mc.Servers[req.Port]; !ok {
-		if err := mc.startMockServerLocked(req.Port); err != nil {
-			mc.Logger.Log("RegisterRouteError", time.Since(start), fmt.Sprintf("Failed to start server on port %d: %v", req.Port, err))
-			http.Error(w, fmt.Sprintf("Failed to start server: %v", err), http.StatusInternalServerError)
-			return
-		}
+		startErr = mc.startMockServerLocked(req.Port)
+	}
+	mc.mu.Unlock()
+
+	if startErr != nil {
+		mc.Logger.Log("RegisterRouteError", time.Since(start), fmt.Sprintf("Failed to start server on port %d: %v", req.Port, startErr))
+		http.Error(w, fmt.Sprintf("Failed to start server: %v", startErr), http.StatusInternalServerError)
+		return
 	}
 
 	details := map[string]interface{}{
@@ -96,10 +213,56 @@ func (mc *MockController) handleRegisterRoute(w http.ResponseWriter, r *http.Req
 	json.NewEncoder(w).Encode(map[string]string{"status": "ok", "message": "Route registered"})
 }
 
+// RegisterFuncRoute registers a route answered by a Go function instead of response steps,
+// for logic the step DSL cannot express. It is only available when the controller is
+// embedded in-process; the JSON control API cannot carry functions. Registering the same
+// route with /registerRoute replaces it, and resets remove it like any other route.
+func (mc *MockController) RegisterFuncRoute(port int, method, path string, handler FuncRouteHandler) error {
+	start := time.Now()
+	if !validMethods[method] {
+		return fmt.Errorf("invalid method %q: must be one of GET, HEAD, POST, PUT, PATCH, DELETE, CONNECT, OPTIONS, TRACE", method)
+	}
+	if handler == nil {
+		return fmt.Errorf("handler for %s %s must not be nil", method, path)
+	}
+
+	mc.mu.Lock()
+	if _, ok := mc.Routes[port]; !ok {
+		mc.Routes[port] = make(map[string]map[string][]ResponseFuncConfig)
+	}
+	if _, ok := mc.Routes[port][method]; !ok {
+		mc.Routes[port][method] = make(map[string][]ResponseFuncConfig)
+	}
+	mc.Routes[port][method][path] = []ResponseFuncConfig{}
+	if mc.funcRoutes == nil {
+		mc.funcRoutes = make(map[routeKey]FuncRouteHandler)
+	}
+	mc.funcRoutes[routeKey{Port: port, Method: method, Path: path}] = handler
+	for k := range mc.sequences {
+		if k.Port == port && k.Method == method && k.Path == path {
+			delete(mc.sequences, k)
+		}
+	}
+
+	var startErr error
+	if _, ok := mc.Servers[port]; !ok {
+		startErr = mc.startMockServerLocked(port)
+	}
+	mc.mu.Unlock()
+
+	if startErr != nil {
+		return fmt.Errorf("failed to start server on port %d: %w", port, startErr)
+	}
+	mc.Logger.Log("RegisterFuncRoute", time.Since(start), map[string]interface{}{
+		"port": port, "method": method, "path": path,
+	})
+	return nil
+}
+
 func (mc *MockController) startMockServerLocked(port int) error {
 	// Assumes mc.mu is locked
 	server := &http.Server{
-		Addr: fmt.Sprintf(":%d", port),
+		Addr: mc.addr(port),
 		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			mc.handleMockRequest(port, w, r)
 		}),
@@ -134,6 +297,18 @@ func (mc *MockController) handleResetPort(w http.ResponseWriter, r *http.Request
 
 	// Remove routes
 	delete(mc.Routes, port)
+	delete(mc.NotFound, port)
+	delete(mc.Modes, port)
+	for k := range mc.sequences {
+		if k.Port == port {
+			delete(mc.sequences, k)
+		}
+	}
+	for k := range mc.funcRoutes {
+		if k.Port == port {
+			delete(mc.funcRoutes, k)
+		}
+	}
 
 	// Stop server
 	if instance, ok := mc.Servers[port]; ok {
@@ -168,7 +343,12 @@ func (mc *MockController) handleResetAll(w http.ResponseWriter, r *http.Request)
 	// Clear all state
 	mc.Servers = make(map[int]*MockServerInstance)
 	mc.Routes = make(map[int]map[string]map[string][]ResponseFuncConfig)
+	mc.NotFound = make(map[int]NotFoundResponse)
+	mc.Modes = make(map[int]string)
+	mc.sequences = make(map[sequenceKey]int)
+	mc.funcRoutes = make(map[routeKey]FuncRouteHandler)
 	mc.mu.Unlock()
+	mc.clearInteractions()
 
 	var wg sync.WaitGroup
 	for _, inst := range instances {
@@ -186,30 +366,172 @@ func (mc *MockController) handleResetAll(w http.ResponseWriter, r *http.Request)
 	w.WriteHeader(http.StatusOK)
 }
 
+func (mc *MockController) handleSetNotFoundResponse(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req NotFoundResponse
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.StatusCode == 0 {
+		req.StatusCode = http.StatusNotFound
+	}
+
+	mc.mu.Lock()
+	mc.NotFound[req.Port] = req
+	mc.mu.Unlock()
+
+	mc.Logger.Log("SetNotFoundResponse", time.Since(start), map[string]interface{}{
+		"port": req.Port, "status": req.StatusCode,
+	})
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleSetPortMode switches a port between normal and maintenance mode. In maintenance mode
+// every request on the port is answered with 503, leaving its registered routes untouched.
+func (mc *MockController) handleSetPortMode(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req SetPortModeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Mode != PortModeNormal && req.Mode != PortModeMaintenance {
+		msg := fmt.Sprintf("Invalid mode %q: must be %s or %s", req.Mode, PortModeNormal, PortModeMaintenance)
+		mc.Logger.Log("SetPortModeError", time.Since(start), msg)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	mc.mu.Lock()
+	if mc.Modes == nil {
+		mc.Modes = make(map[int]string)
+	}
+	if req.Mode == PortModeNormal {
+		delete(mc.Modes, req.Port)
+	} else {
+		mc.Modes[req.Port] = req.Mode
+	}
+	mc.mu.Unlock()
+
+	mc.Logger.Log("SetPortMode", time.Since(start), map[string]interface{}{
+		"port": req.Port, "mode": req.Mode,
+	})
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleDescribeRoute returns the steps registered for ?port=&method=&path= as JSON.
+func (mc *MockController) handleDescribeRoute(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	port, err := strconv.Atoi(q.Get("port"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid port %q", q.Get("port")), http.StatusBadRequest)
+		return
+	}
+	method, path := q.Get("method"), q.Get("path")
+
+	mc.mu.RLock()
+	steps, ok := mc.Routes[port][method][path]
+	mc.mu.RUnlock()
+
+	if !ok {
+		http.Error(w, fmt.Sprintf("Route %s %s not registered on port %d", method, path, port), http.StatusNotFound)
+		return
+	}
+
+	mc.Logger.Log("DescribeRoute", time.Since(start), map[string]interface{}{
+		"port": port, "method": method, "path": path,
+	})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(steps)
+}
+
 func (mc *MockController) handleMockRequest(port int, w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
+	mc.recordRequest(port, r, start)
 
-	// Lookup route
+	// Lookup route and take a snapshot of its steps while holding the read lock
 	mc.mu.RLock()
 	var steps []ResponseFuncConfig
+	var pattern string
+	var pathVars map[string]string
 	if portRoutes, ok := mc.Routes[port]; ok {
 		if methodRoutes, ok := portRoutes[r.Method]; ok {
-			if s, ok := methodRoutes[r.URL.Path]; ok {
-				steps = s
+			if p, s, vars := matchRoute(methodRoutes, r.URL.Path); s != nil {
+				steps = make([]ResponseFuncConfig, len(s))
+				copy(steps, s)
+				pattern, pathVars = p, vars
 			}
 		}
 	}
+	funcHandler := mc.funcRoutes[routeKey{Port: port, Method: r.Method, Path: pattern}]
+	notFound, hasNotFound := mc.NotFound[port]
+	mode := mc.Modes[port]
 	mc.mu.RUnlock()
 
+	if mode == PortModeMaintenance {
+		http.Error(w, "Service Unavailable (maintenance)", http.StatusServiceUnavailable)
+		mc.Logger.Log("MockRequest", time.Since(start), map[string]interface{}{
+			"port": port, "method": r.Method, "path": r.URL.Path, "status": http.StatusServiceUnavailable, "mode": mode,
+		})
+		return
+	}
+
 	if steps == nil {
-		http.NotFound(w, r)
+		status := http.StatusNotFound
+		if hasNotFound {
+			status = notFound.StatusCode
+			if json.Valid([]byte(notFound.Body)) {
+				w.Header().Set("Content-Type", "application/json")
+			} else {
+				w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			}
+			w.WriteHeader(status)
+			w.Write([]byte(notFound.Body))
+		} else {
+			http.NotFound(w, r)
+		}
 		mc.Logger.Log("MockRequest", time.Since(start), map[string]interface{}{
-			"port": port, "method": r.Method, "path": r.URL.Path, "status": 404,
+			"port": port, "method": r.Method, "path": r.URL.Path, "status": status,
+		})
+		return
+	}
+
+	if funcHandler != nil {
+		status := mc.serveFuncRoute(funcHandler, pathVars, w, r)
+		mc.Logger.Log("MockRequest", time.Since(start), map[string]interface{}{
+			"port": port, "method": r.Method, "path": r.URL.Path, "status": status, "func": true,
 		})
 		return
 	}
 
 	executor := NewHandlerExecutor(w, r)
+	for k, v := range pathVars {
+		executor.Variables[k] = v
+	}
+	executor.NextSequence = func(name string, start int) int {
+		return mc.nextSequence(sequenceKey{Port: port, Method: r.Method, Path: pattern, Var: name}, start)
+	}
+	executor.Env = mc.allowedEnv()
+	executor.Now = mc.Clock
+	executor.Location = mc.Location
+	executor.DebugHeaders = mc.DebugHeaders
 	err := executor.Execute(steps)
 	if err != nil {
 		mc.Logger.Log("MockRequestError", time.Since(start), fmt.Sprintf("Error executing steps: %v", err))
@@ -225,6 +547,203 @@ func (mc *MockController) handleMockRequest(port int, w http.ResponseWriter, r *
 	})
 }
 
+// serveFuncRoute writes the response built by a RegisterFuncRoute handler and returns its status.
+func (mc *MockController) serveFuncRoute(handler FuncRouteHandler, pathVars map[string]string, w http.ResponseWriter, r *http.Request) int {
+	for k, v := range pathVars {
+		r.SetPathValue(k, v)
+	}
+	status, headers, body := handler(r)
+	if status == 0 {
+		status = http.StatusOK
+	}
+	for k, v := range headers {
+		w.Header().Set(k, v)
+	}
+	w.WriteHeader(status)
+	w.Write(body)
+	return status
+}
+
+// nextSequence returns start on the first call for key and one more than the previous value after that.
+func (mc *MockController) nextSequence(key sequenceKey, start int) int {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	if mc.sequences == nil {
+		mc.sequences = make(map[sequenceKey]int)
+	}
+	next, ok := mc.sequences[key]
+	if !ok {
+		next = start
+	}
+	mc.sequences[key] = next + 1
+	return next
+}
+
+// recordRequest appends r to the interaction log and writes it to the Recorder, if any,
+// restoring the body for the route steps.
+func (mc *MockController) recordRequest(port int, r *http.Request, at time.Time) {
+	var body []byte
+	if r.Body != nil {
+		body, _ = io.ReadAll(r.Body)
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	rec := RecordedRequest{
+		Timestamp: at,
+		Port:      port,
+		Method:    r.Method,
+		Path:      r.URL.Path,
+		Query:     r.URL.RawQuery,
+		Headers:   r.Header,
+		Body:      string(body),
+	}
+
+	logged := rec
+	if len(body) > maxInteractionBodyBytes {
+		logged.Body = string(body[:maxInteractionBodyBytes])
+		logged.BodyTruncated = true
+	}
+	mc.interactionsMu.Lock()
+	mc.interactions = append(mc.interactions, logged)
+	if over := len(mc.interactions) - maxInteractions; over > 0 {
+		mc.interactions = append([]RecordedRequest(nil), mc.interactions[over:]...)
+	}
+	mc.interactionsMu.Unlock()
+
+	if mc.Recorder != nil {
+		mc.Recorder.Record(rec)
+	}
+}
+
+func (mc *MockController) clearInteractions() {
+	mc.interactionsMu.Lock()
+	mc.interactions = nil
+	mc.interactionsMu.Unlock()
+}
+
+// handleInteractions returns the requests received on all mock ports, oldest first (GET),
+// or clears them (DELETE).
+func (mc *MockController) handleInteractions(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	switch r.Method {
+	case http.MethodGet:
+		mc.interactionsMu.Lock()
+		list := append([]RecordedRequest{}, mc.interactions...)
+		mc.interactionsMu.Unlock()
+		mc.Logger.Log("Interactions", time.Since(start), map[string]int{"count": len(list)})
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(list)
+	case http.MethodDelete:
+		mc.clearInteractions()
+		mc.Logger.Log("ResetInteractions", time.Since(start), nil)
+		w.WriteHeader(http.StatusOK)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// matchRoute finds the pattern and steps registered for path and the variables captured from it.
+// Route patterns may contain "{name}" segments, each matching one path segment (captured
+// as variable name), and end in "*", matching the rest of the path (captured as WildcardVar).
+//
+// When several patterns match, the first by this precedence wins:
+//  1. an exact (all-literal) pattern equal to the path;
+//  2. the pattern with the most leading literal segments (longest static prefix);
+//  3. a pattern without a wildcard over one with a wildcard;
+//  4. the pattern with the most literal segments overall, then the lexically smallest pattern.
+func matchRoute(routes map[string][]ResponseFuncConfig, path string) (string, []ResponseFuncConfig, map[string]string) {
+	if s, ok := routes[path]; ok && s != nil {
+		return path, s, nil
+	}
+	var best *routeMatch
+	for pattern, s := range routes {
+		if s == nil {
+			continue
+		}
+		m, ok := matchPattern(pattern, path)
+		if !ok {
+			continue
+		}
+		m.steps = s
+		if best == nil || m.beats(best) {
+			best = m
+		}
+	}
+	if best == nil {
+		return "", nil, nil
+	}
+	return best.pattern, best.steps, best.vars
+}
+
+// routeMatch describes how a pattern matched a path, for ranking in matchRoute.
+type routeMatch struct {
+	pattern      string
+	steps        []ResponseFuncConfig
+	vars         map[string]string
+	staticPrefix int
+	staticTotal  int
+	wildcard     bool
+}
+
+func (m *routeMatch) beats(o *routeMatch) bool {
+	if m.staticPrefix != o.staticPrefix {
+		return m.staticPrefix > o.staticPrefix
+	}
+	if m.wildcard != o.wildcard {
+		return !m.wildcard
+	}
+	if m.staticTotal != o.staticTotal {
+		return m.staticTotal > o.staticTotal
+	}
+	return m.pattern < o.pattern
+}
+
+// matchPattern matches path against a route pattern segment by segment.
+func matchPattern(pattern, path string) (*routeMatch, bool) {
+	patSegs := strings.Split(pattern, "/")
+	pathSegs := strings.Split(path, "/")
+	m := &routeMatch{pattern: pattern}
+	inPrefix := true
+	for i, seg := range patSegs {
+		if seg == "*" && i == len(patSegs)-1 {
+			if len(pathSegs) < len(patSegs) {
+				return nil, false
+			}
+			m.wildcard = true
+			m.setVar(WildcardVar, strings.Join(pathSegs[i:], "/"))
+			return m, true
+		}
+		if i >= len(pathSegs) {
+			return nil, false
+		}
+		if len(seg) > 2 && strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+			if pathSegs[i] == "" {
+				return nil, false
+			}
+			m.setVar(seg[1:len(seg)-1], pathSegs[i])
+			inPrefix = false
+			continue
+		}
+		if seg != pathSegs[i] {
+			return nil, false
+		}
+		m.staticTotal++
+		if inPrefix {
+			m.staticPrefix++
+		}
+	}
+	if len(pathSegs) != len(patSegs) {
+		return nil, false
+	}
+	return m, true
+}
+
+func (m *routeMatch) setVar(name, value string) {
+	if m.vars == nil {
+		m.vars = make(map[string]string)
+	}
+	m.vars[name] = value
+}
+
 func (mc *MockController) handleNotFound(w http.ResponseWriter, r *http.Request) {
 	mc.Logger.Log("ControlRequest", 0, map[string]interface{}{
 		"path":   r.URL.Path,
@@ -2,11 +2,20 @@ package dynamic_mock_server
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -195,3 +204,1426 @@ func TestDynamicMockServer(t *testing.T) {
 		}
 	})
 }
+
+// freePort asks the OS for an unused TCP port.
+func freePort(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to find free port: %v", err)
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+// startTestController starts a controller on a free port and returns a client for it.
+func startTestController(t *testing.T) (*MockController, *Client) {
+	t.Helper()
+	controlPort := freePort(t)
+	logger, err := NewLogger(filepath.Join(t.TempDir(), "mock-server.log"))
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	t.Cleanup(logger.Close)
+	controller := NewMockController(controlPort, logger)
+	go func() {
+		if err := controller.Start(); err != nil && err != http.ErrServerClosed {
+			t.Logf("Control server error: %v", err)
+		}
+	}()
+
+	client := NewClient(fmt.Sprintf("http://localhost:%d", controlPort))
+	if err := waitForServer(client.BaseURL + "/"); err != nil {
+		t.Fatalf("Control server not up: %v", err)
+	}
+	return controller, client
+}
+
+func TestDynamicMockServer_StreamBody(t *testing.T) {
+	_, client := startTestController(t)
+	mockPort := freePort(t)
+	defer client.ResetPort(mockPort)
+
+	err := client.RegisterRoute(mockPort, "GET", "/stream", []ResponseFuncConfig{
+		SetStreamBody("", []string{"chunk-1;", "chunk-2;", "chunk-3;"}, 150),
+	})
+	if err != nil {
+		t.Fatalf("RegisterRoute failed: %v", err)
+	}
+	url := fmt.Sprintf("http://localhost:%d/stream", mockPort)
+	if err := waitForServer(url); err != nil {
+		t.Fatalf("Mock server not up: %v", err)
+	}
+
+	start := time.Now()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// The first chunk is flushed before the first delay elapses.
+	buf := make([]byte, 64)
+	n, err := resp.Body.Read(buf)
+	if err != nil && err != io.EOF {
+		t.Fatalf("Failed to read first chunk: %v", err)
+	}
+	firstChunkAt := time.Since(start)
+	if string(buf[:n]) != "chunk-1;" {
+		t.Errorf("Expected first read to be 'chunk-1;', got %q", string(buf[:n]))
+	}
+	if firstChunkAt >= 150*time.Millisecond {
+		t.Errorf("First chunk arrived too late: %v", firstChunkAt)
+	}
+
+	rest, _ := io.ReadAll(resp.Body)
+	total := time.Since(start)
+	if string(buf[:n])+string(rest) != "chunk-1;chunk-2;chunk-3;" {
+		t.Errorf("Unexpected streamed body: %q", string(buf[:n])+string(rest))
+	}
+	if total < 300*time.Millisecond {
+		t.Errorf("Expected at least 300ms for two inter-chunk delays, got %v", total)
+	}
+	if len(resp.TransferEncoding) == 0 || resp.TransferEncoding[0] != "chunked" {
+		t.Errorf("Expected chunked transfer encoding, got %v", resp.TransferEncoding)
+	}
+}
+
+func TestDynamicMockServer_ConcurrentRegisterAndRequest(t *testing.T) {
+	_, client := startTestController(t)
+	mockPort := freePort(t)
+	defer client.ResetPort(mockPort)
+
+	if err := client.RegisterRoute(mockPort, "GET", "/hot", []ResponseFuncConfig{
+		SetJsonBody("", `{"version": 0}`),
+	}); err != nil {
+		t.Fatalf("RegisterRoute failed: %v", err)
+	}
+	url := fmt.Sprintf("http://localhost:%d/hot", mockPort)
+	if err := waitForServer(url); err != nil {
+		t.Fatalf("Mock server not up: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 200)
+
+	// Writers keep replacing the route while readers hit it
+	for i := 1; i <= 10; i++ {
+		wg.Add(1)
+		go func(version int) {
+			defer wg.Done()
+			for j := 0; j < 5; j++ {
+				err := client.RegisterRoute(mockPort, "GET", "/hot", []ResponseFuncConfig{
+					ExtractRequestQuery("q", "Q"),
+					SetJsonBody("", fmt.Sprintf(`{"version": %d}`, version)),
+				})
+				if err != nil {
+					errs <- fmt.Errorf("register: %v", err)
+				}
+			}
+		}(i)
+	}
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 5; j++ {
+				resp, err := http.Get(url + "?q=x")
+				if err != nil {
+					errs <- fmt.Errorf("request: %v", err)
+					continue
+				}
+				body, _ := io.ReadAll(resp.Body)
+				resp.Body.Close()
+				if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), `"version"`) {
+					errs <- fmt.Errorf("unexpected response %d: %s", resp.StatusCode, body)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestDynamicMockServer_RegisterRouteInvalidMethod(t *testing.T) {
+	_, client := startTestController(t)
+	mockPort := freePort(t)
+	defer client.ResetPort(mockPort)
+
+	steps := []ResponseFuncConfig{SetJsonBody("", `{"ok": true}`)}
+
+	for _, method := range []string{"GET ", "POSTT", "get", ""} {
+		err := client.RegisterRoute(mockPort, method, "/bad", steps)
+		if err == nil {
+			t.Errorf("Expected method %q to be rejected", method)
+			continue
+		}
+		if !strings.Contains(err.Error(), "status 400") || !strings.Contains(err.Error(), "Invalid method") {
+			t.Errorf("Expected 400 with a clear message for %q, got: %v", method, err)
+		}
+	}
+
+	if err := client.RegisterRoute(mockPort, http.MethodGet, "/good", steps); err != nil {
+		t.Fatalf("Expected GET to be accepted: %v", err)
+	}
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%d/good", mockPort))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected 200 from valid route, got %d", resp.StatusCode)
+	}
+}
+
+func TestDynamicMockServer_CustomNotFound(t *testing.T) {
+	_, client := startTestController(t)
+	mockPort := freePort(t)
+	defer client.ResetPort(mockPort)
+
+	if err := client.RegisterRoute(mockPort, http.MethodGet, "/known", []ResponseFuncConfig{SetJsonBody("", `{"ok": true}`)}); err != nil {
+		t.Fatalf("RegisterRoute failed: %v", err)
+	}
+	if err := client.SetNotFoundResponse(mockPort, http.StatusNotFound, `{"error": "no such route"}`); err != nil {
+		t.Fatalf("SetNotFoundResponse failed: %v", err)
+	}
+
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%d/unknown", mockPort))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404, got %d", resp.StatusCode)
+	}
+	if string(body) != `{"error": "no such route"}` {
+		t.Errorf("Unexpected body: %s", body)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Expected application/json, got %q", ct)
+	}
+
+	// Registered routes are unaffected
+	resp, err = http.Get(fmt.Sprintf("http://localhost:%d/known", mockPort))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected 200 for registered route, got %d", resp.StatusCode)
+	}
+}
+
+func TestDynamicMockServer_DescribeRoute(t *testing.T) {
+	_, client := startTestController(t)
+	mockPort := freePort(t)
+	defer client.ResetPort(mockPort)
+
+	steps := []ResponseFuncConfig{
+		IfRequestHeaderSetCase("X-Mode", ConditionEqual, "fail", "Fail"),
+		ExtractRequestQuery("id", "ID"),
+		SetJsonBody("", `{"id": "{{.ID}}"}`),
+		SetStatusCode("Fail", 500),
+		SetStreamBody("Stream", []string{"a", "b"}, 10),
+	}
+	if err := client.RegisterRoute(mockPort, http.MethodGet, "/items", steps); err != nil {
+		t.Fatalf("RegisterRoute failed: %v", err)
+	}
+
+	described, err := client.DescribeRoute(mockPort, http.MethodGet, "/items")
+	if err != nil {
+		t.Fatalf("DescribeRoute failed: %v", err)
+	}
+
+	// Compare via JSON since args come back as decoded JSON values (e.g. float64, []interface{})
+	want, _ := json.Marshal(steps)
+	got, _ := json.Marshal(described)
+	if string(want) != string(got) {
+		t.Errorf("Described steps don't round-trip.\nWant: %s\nGot:  %s", want, got)
+	}
+
+	if _, err := client.DescribeRoute(mockPort, http.MethodPost, "/items"); err == nil || !strings.Contains(err.Error(), "status 404") {
+		t.Errorf("Expected 404 for unregistered route, got: %v", err)
+	}
+}
+
+func TestDynamicMockServer_BindHost(t *testing.T) {
+	controlPort := freePort(t)
+	logger, err := NewLogger(filepath.Join(t.TempDir(), "mock-server.log"))
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer logger.Close()
+
+	controller := NewMockController(controlPort, logger)
+	controller.Host = "127.0.0.1"
+	go func() {
+		if err := controller.Start(); err != nil && err != http.ErrServerClosed {
+			t.Logf("Control server error: %v", err)
+		}
+	}()
+
+	client := NewClient(fmt.Sprintf("http://127.0.0.1:%d", controlPort))
+	if err := waitForServer(client.BaseURL + "/"); err != nil {
+		t.Fatalf("Control server not up: %v", err)
+	}
+
+	mockPort := freePort(t)
+	defer client.ResetPort(mockPort)
+	if err := client.RegisterRoute(mockPort, http.MethodGet, "/ping", []ResponseFuncConfig{SetJsonBody("", "pong")}); err != nil {
+		t.Fatalf("RegisterRoute failed: %v", err)
+	}
+
+	controller.mu.RLock()
+	addr := controller.Servers[mockPort].Server.Addr
+	controller.mu.RUnlock()
+	if want := fmt.Sprintf("127.0.0.1:%d", mockPort); addr != want {
+		t.Errorf("Expected mock server to bind %s, got %s", want, addr)
+	}
+
+	if err := waitForServer(fmt.Sprintf("http://127.0.0.1:%d/ping", mockPort)); err != nil {
+		t.Fatalf("Mock server not reachable on loopback: %v", err)
+	}
+	// A non-loopback address must not reach the mock server
+	addrs, _ := net.InterfaceAddrs()
+	for _, a := range addrs {
+		ipNet, ok := a.(*net.IPNet)
+		if !ok || ipNet.IP.IsLoopback() || ipNet.IP.To4() == nil {
+			continue
+		}
+		conn, err := net.DialTimeout("tcp", net.JoinHostPort(ipNet.IP.String(), strconv.Itoa(mockPort)), time.Second)
+		if err == nil {
+			conn.Close()
+			t.Errorf("Expected no listener on %s:%d", ipNet.IP, mockPort)
+		}
+		break
+	}
+}
+
+func TestDynamicMockServer_Gzip(t *testing.T) {
+	_, client := startTestController(t)
+	mockPort := freePort(t)
+	defer client.ResetPort(mockPort)
+
+	err := client.RegisterRoute(mockPort, http.MethodGet, "/data", []ResponseFuncConfig{
+		SetJsonBody("", `{"hello":"world"}`),
+		SetGzip("", true),
+	})
+	if err != nil {
+		t.Fatalf("RegisterRoute failed: %v", err)
+	}
+	url := fmt.Sprintf("http://localhost:%d/data", mockPort)
+	if err := waitForServer(url); err != nil {
+		t.Fatalf("Mock server not up: %v", err)
+	}
+
+	// Setting Accept-Encoding explicitly disables the transport's transparent decompression
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if got := resp.Header.Get("Content-Encoding"); got != "gzip" {
+		t.Fatalf("Expected Content-Encoding gzip, got %q", got)
+	}
+	gz, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatalf("Body is not gzipped: %v", err)
+	}
+	body, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatalf("Failed to decompress body: %v", err)
+	}
+	if string(body) != `{"hello":"world"}` {
+		t.Errorf("Unexpected decompressed body: %q", string(body))
+	}
+
+	// Without Accept-Encoding the body is sent as-is
+	req, _ = http.NewRequest(http.MethodGet, url, nil)
+	req.Header.Set("Accept-Encoding", "identity")
+	resp2, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp2.Body.Close()
+	plain, _ := io.ReadAll(resp2.Body)
+	if resp2.Header.Get("Content-Encoding") != "" || string(plain) != `{"hello":"world"}` {
+		t.Errorf("Expected uncompressed body, got encoding %q body %q", resp2.Header.Get("Content-Encoding"), string(plain))
+	}
+
+	// Any q-value of zero refuses gzip, however it is spelled
+	for _, c := range []struct {
+		acceptEncoding string
+		wantGzip       bool
+	}{
+		{"gzip;q=0", false},
+		{"gzip;q=0.0", false},
+		{"deflate, gzip; q=0.000", false},
+		{"gzip;q=0.5", true},
+		{"deflate, GZIP", true},
+	} {
+		req, _ := http.NewRequest(http.MethodGet, url, nil)
+		req.Header.Set("Accept-Encoding", c.acceptEncoding)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+		if got := resp.Header.Get("Content-Encoding") == "gzip"; got != c.wantGzip {
+			t.Errorf("Accept-Encoding %q: expected gzip=%v, got Content-Encoding %q", c.acceptEncoding, c.wantGzip, resp.Header.Get("Content-Encoding"))
+		}
+	}
+}
+
+func TestDynamicMockServer_WildcardPrefix(t *testing.T) {
+	_, client := startTestController(t)
+	mockPort := freePort(t)
+	defer client.ResetPort(mockPort)
+
+	err := client.RegisterRoute(mockPort, http.MethodGet, "/static/*", []ResponseFuncConfig{
+		SetJsonBody("", "asset:{{.WILDCARD}}"),
+	})
+	if err != nil {
+		t.Fatalf("RegisterRoute failed: %v", err)
+	}
+	base := fmt.Sprintf("http://localhost:%d", mockPort)
+	if err := waitForServer(base + "/static/a.js"); err != nil {
+		t.Fatalf("Mock server not up: %v", err)
+	}
+
+	for path, want := range map[string]string{
+		"/static/a.js":       "asset:a.js",
+		"/static/b/c.png":    "asset:b/c.png",
+		"/static/":           "asset:",
+		"/other/static/a.js": "",
+	} {
+		resp, err := http.Get(base + path)
+		if err != nil {
+			t.Fatalf("Request %s failed: %v", path, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if want == "" {
+			if resp.StatusCode != http.StatusNotFound {
+				t.Errorf("%s: expected 404, got %d", path, resp.StatusCode)
+			}
+			continue
+		}
+		if resp.StatusCode != http.StatusOK || string(body) != want {
+			t.Errorf("%s: expected 200 %q, got %d %q", path, want, resp.StatusCode, string(body))
+		}
+	}
+}
+
+func TestDynamicMockServer_RoutePrecedence(t *testing.T) {
+	_, client := startTestController(t)
+	mockPort := freePort(t)
+	defer client.ResetPort(mockPort)
+
+	routes := map[string]string{
+		"/users/{id}":        "param:{{.id}}",
+		"/users/me":          "exact",
+		"/users/*":           "wildcard:{{.WILDCARD}}",
+		"/users/{id}/orders": "orders:{{.id}}",
+		"/users/me/*":        "me-wildcard:{{.WILDCARD}}",
+	}
+	for path, body := range routes {
+		if err := client.RegisterRoute(mockPort, http.MethodGet, path, []ResponseFuncConfig{SetJsonBody("", body)}); err != nil {
+			t.Fatalf("RegisterRoute %s failed: %v", path, err)
+		}
+	}
+	base := fmt.Sprintf("http://localhost:%d", mockPort)
+	if err := waitForServer(base + "/users/me"); err != nil {
+		t.Fatalf("Mock server not up: %v", err)
+	}
+
+	for path, want := range map[string]string{
+		"/users/me":          "exact",
+		"/users/42":          "param:42",
+		"/users/42/orders":   "orders:42",
+		"/users/me/orders":   "me-wildcard:orders",
+		"/users/42/orders/7": "wildcard:42/orders/7",
+	} {
+		resp, err := http.Get(base + path)
+		if err != nil {
+			t.Fatalf("Request %s failed: %v", path, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != want {
+			t.Errorf("%s: expected %q, got %q", path, want, string(body))
+		}
+	}
+}
+
+func TestDynamicMockServer_ResponseFraming(t *testing.T) {
+	_, client := startTestController(t)
+	mockPort := freePort(t)
+	defer client.ResetPort(mockPort)
+
+	routes := map[string][]ResponseFuncConfig{
+		"/plain":   {SetJsonBody("", "hello")},
+		"/chunked": {SetJsonBody("", "hello"), SetNoContentLength("")},
+		"/close":   {SetJsonBody("", "hello"), SetConnectionClose("")},
+	}
+	for path, steps := range routes {
+		if err := client.RegisterRoute(mockPort, http.MethodGet, path, steps); err != nil {
+			t.Fatalf("RegisterRoute %s failed: %v", path, err)
+		}
+	}
+	base := fmt.Sprintf("http://localhost:%d", mockPort)
+	if err := waitForServer(base + "/plain"); err != nil {
+		t.Fatalf("Mock server not up: %v", err)
+	}
+
+	get := func(path string) (*http.Response, string) {
+		resp, err := http.Get(base + path)
+		if err != nil {
+			t.Fatalf("Request %s failed: %v", path, err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp, string(body)
+	}
+
+	resp, body := get("/plain")
+	if resp.ContentLength != 5 || len(resp.TransferEncoding) != 0 || resp.Close {
+		t.Errorf("/plain: expected Content-Length 5 on a keep-alive connection, got length %d, encoding %v, close %v", resp.ContentLength, resp.TransferEncoding, resp.Close)
+	}
+
+	resp, body = get("/chunked")
+	if resp.ContentLength != -1 || len(resp.TransferEncoding) == 0 || resp.TransferEncoding[0] != "chunked" {
+		t.Errorf("/chunked: expected chunked encoding without Content-Length, got length %d, encoding %v", resp.ContentLength, resp.TransferEncoding)
+	}
+	if body != "hello" {
+		t.Errorf("/chunked: unexpected body %q", body)
+	}
+
+	resp, body = get("/close")
+	// net/http consumes the Connection header and reports it as resp.Close
+	if !resp.Close {
+		t.Errorf("/close: expected Connection: close")
+	}
+	if body != "hello" {
+		t.Errorf("/close: unexpected body %q", body)
+	}
+}
+
+func TestDynamicMockServer_RecordRequests(t *testing.T) {
+	controller, client := startTestController(t)
+	recordPath := filepath.Join(t.TempDir(), "requests.jsonl")
+	recorder, err := NewRequestRecorder(recordPath)
+	if err != nil {
+		t.Fatalf("Failed to create recorder: %v", err)
+	}
+	controller.Recorder = recorder
+
+	mockPort := freePort(t)
+	defer client.ResetPort(mockPort)
+	if err := client.RegisterRoute(mockPort, http.MethodPost, "/orders", []ResponseFuncConfig{
+		ExtractRequestJsonBody("id", "ID"),
+		SetJsonBody("", `{"id":"{{.ID}}"}`),
+	}); err != nil {
+		t.Fatalf("RegisterRoute failed: %v", err)
+	}
+	base := fmt.Sprintf("http://localhost:%d", mockPort)
+	if err := waitForServer(base + "/orders"); err != nil {
+		t.Fatalf("Mock server not up: %v", err)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, base+"/orders?src=test", strings.NewReader(`{"id":"o-1"}`))
+	req.Header.Set("X-Trace", "trace-1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	// Recording must not consume the body the route steps read
+	if string(body) != `{"id":"o-1"}` {
+		t.Errorf("Unexpected response body: %s", body)
+	}
+	resp, err = http.Get(base + "/missing")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	recorder.Close()
+
+	data, err := os.ReadFile(recordPath)
+	if err != nil {
+		t.Fatalf("Failed to read record file: %v", err)
+	}
+	var recorded []RecordedRequest
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var r RecordedRequest
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("Invalid record line %q: %v", line, err)
+		}
+		// waitForServer's probes are recorded too; keep only this test's requests
+		if r.Method == http.MethodPost || r.Path == "/missing" {
+			recorded = append(recorded, r)
+		}
+	}
+	if len(recorded) != 2 {
+		t.Fatalf("Expected 2 recorded requests, got %d: %s", len(recorded), data)
+	}
+	post := recorded[0]
+	if post.Port != mockPort || post.Path != "/orders" || post.Query != "src=test" || post.Body != `{"id":"o-1"}` {
+		t.Errorf("Unexpected recorded POST: %+v", post)
+	}
+	if got := post.Headers["X-Trace"]; len(got) != 1 || got[0] != "trace-1" {
+		t.Errorf("Expected X-Trace header to be recorded, got %v", post.Headers)
+	}
+	if post.Timestamp.IsZero() {
+		t.Error("Expected a timestamp")
+	}
+	if recorded[1].Method != http.MethodGet {
+		t.Errorf("Expected the unmatched GET to be recorded, got %+v", recorded[1])
+	}
+}
+
+func TestDynamicMockServer_GenerateSequence(t *testing.T) {
+	controller, client := startTestController(t)
+	mockPort := freePort(t)
+	defer client.ResetPort(mockPort)
+
+	steps := []ResponseFuncConfig{
+		GenerateSequence(101, "ID"),
+		SetJsonBody("", `{"id":{{.ID}}}`),
+	}
+	for _, path := range []string{"/orders", "/users"} {
+		if err := client.RegisterRoute(mockPort, http.MethodPost, path, steps); err != nil {
+			t.Fatalf("RegisterRoute %s failed: %v", path, err)
+		}
+	}
+	base := fmt.Sprintf("http://localhost:%d", mockPort)
+	if err := waitForServer(base + "/"); err != nil {
+		t.Fatalf("Mock server not up: %v", err)
+	}
+
+	post := func(path string) string {
+		resp, err := http.Post(base+path, "application/json", strings.NewReader("{}"))
+		if err != nil {
+			t.Fatalf("Request %s failed: %v", path, err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	for _, want := range []string{`{"id":101}`, `{"id":102}`, `{"id":103}`} {
+		if got := post("/orders"); got != want {
+			t.Errorf("/orders: expected %s, got %s", want, got)
+		}
+	}
+	// Each route has its own counter
+	if got := post("/users"); got != `{"id":101}` {
+		t.Errorf("/users: expected its own sequence to start at 101, got %s", got)
+	}
+
+	// Re-registering the route restarts its sequence
+	if err := client.RegisterRoute(mockPort, http.MethodPost, "/orders", steps); err != nil {
+		t.Fatalf("RegisterRoute failed: %v", err)
+	}
+	if got := post("/orders"); got != `{"id":101}` {
+		t.Errorf("Expected sequence to restart after re-registration, got %s", got)
+	}
+
+	// Replacing the route with a Go handler drops its counters too
+	err := controller.RegisterFuncRoute(mockPort, http.MethodPost, "/orders", func(*http.Request) (int, map[string]string, []byte) {
+		return http.StatusOK, nil, nil
+	})
+	if err != nil {
+		t.Fatalf("RegisterFuncRoute failed: %v", err)
+	}
+	controller.mu.Lock()
+	for k := range controller.sequences {
+		if k.Path == "/orders" {
+			t.Errorf("Expected the /orders sequence to be cleared, found %+v", k)
+		}
+	}
+	controller.mu.Unlock()
+}
+
+func TestDynamicMockServer_RegisterRouteMethods(t *testing.T) {
+	_, client := startTestController(t)
+	mockPort := freePort(t)
+	defer client.ResetPort(mockPort)
+
+	err := client.RegisterRouteMethods(mockPort, []string{http.MethodGet, http.MethodPost}, "/multi", []ResponseFuncConfig{
+		SetStatusCode("", 202),
+		SetJsonBody("", `{"ok":true}`),
+	})
+	if err != nil {
+		t.Fatalf("RegisterRouteMethods failed: %v", err)
+	}
+	url := fmt.Sprintf("http://localhost:%d/multi", mockPort)
+	if err := waitForServer(url); err != nil {
+		t.Fatalf("Mock server not up: %v", err)
+	}
+
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		req, _ := http.NewRequest(method, url, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s failed: %v", method, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != 202 || string(body) != `{"ok":true}` {
+			t.Errorf("%s: expected 202 {\"ok\":true}, got %d %s", method, resp.StatusCode, body)
+		}
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, url, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("DELETE failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode == 202 {
+		t.Errorf("DELETE should not match a route registered for GET/POST")
+	}
+}
+
+func TestDynamicMockServer_RequireAuth(t *testing.T) {
+	_, client := startTestController(t)
+	mockPort := freePort(t)
+	defer client.ResetPort(mockPort)
+
+	ok := []ResponseFuncConfig{SetStatusCode("", 200), SetJsonBody("", `{"secret":true}`)}
+	if err := client.RegisterRoute(mockPort, http.MethodGet, "/basic", append([]ResponseFuncConfig{RequireBasicAuth("alice", "s3cret")}, ok...)); err != nil {
+		t.Fatalf("RegisterRoute failed: %v", err)
+	}
+	if err := client.RegisterRoute(mockPort, http.MethodGet, "/bearer", append([]ResponseFuncConfig{RequireBearer("tok-123")}, ok...)); err != nil {
+		t.Fatalf("RegisterRoute failed: %v", err)
+	}
+	base := fmt.Sprintf("http://localhost:%d", mockPort)
+	if err := waitForServer(base + "/basic"); err != nil {
+		t.Fatalf("Mock server not up: %v", err)
+	}
+
+	do := func(path string, auth func(*http.Request)) (*http.Response, string) {
+		req, _ := http.NewRequest(http.MethodGet, base+path, nil)
+		if auth != nil {
+			auth(req)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET %s failed: %v", path, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return resp, string(body)
+	}
+
+	cases := []struct {
+		name      string
+		path      string
+		auth      func(*http.Request)
+		wantOK    bool
+		challenge string
+	}{
+		{"basic valid", "/basic", func(r *http.Request) { r.SetBasicAuth("alice", "s3cret") }, true, ""},
+		{"basic wrong password", "/basic", func(r *http.Request) { r.SetBasicAuth("alice", "nope") }, false, "Basic"},
+		{"basic missing", "/basic", nil, false, "Basic"},
+		{"bearer valid", "/bearer", func(r *http.Request) { r.Header.Set("Authorization", "Bearer tok-123") }, true, ""},
+		{"bearer wrong token", "/bearer", func(r *http.Request) { r.Header.Set("Authorization", "Bearer other") }, false, "Bearer"},
+		{"bearer with basic scheme", "/bearer", func(r *http.Request) { r.SetBasicAuth("tok-123", "") }, false, "Bearer"},
+	}
+	for _, c := range cases {
+		resp, body := do(c.path, c.auth)
+		if c.wantOK {
+			if resp.StatusCode != 200 || body != `{"secret":true}` {
+				t.Errorf("%s: expected 200 with the body, got %d %s", c.name, resp.StatusCode, body)
+			}
+			continue
+		}
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("%s: expected 401, got %d", c.name, resp.StatusCode)
+		}
+		if got := resp.Header.Get("WWW-Authenticate"); !strings.HasPrefix(got, c.challenge) {
+			t.Errorf("%s: expected a %s challenge, got %q", c.name, c.challenge, got)
+		}
+		if strings.Contains(body, "secret") {
+			t.Errorf("%s: later steps should not run, got body %s", c.name, body)
+		}
+	}
+}
+
+func TestDynamicMockServer_RequireAuthBeforeProxy(t *testing.T) {
+	var upstreamHits int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&upstreamHits, 1)
+		fmt.Fprint(w, "from upstream")
+	}))
+	defer upstream.Close()
+
+	_, client := startTestController(t)
+	mockPort := freePort(t)
+	defer client.ResetPort(mockPort)
+
+	// Proxy, delay and gzip steps run before the auth check; a rejected request undoes them
+	err := client.RegisterRoute(mockPort, http.MethodGet, "/api", []ResponseFuncConfig{
+		SetProxyPassthrough("", upstream.URL),
+		SetWait("", 2000),
+		SetGzip("", true),
+		RequireBearer("tok-123"),
+	})
+	if err != nil {
+		t.Fatalf("RegisterRoute failed: %v", err)
+	}
+	url := fmt.Sprintf("http://localhost:%d/api", mockPort)
+	if err := waitForServer(url); err != nil {
+		t.Fatalf("Mock server not up: %v", err)
+	}
+
+	start := time.Now()
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized || string(body) != "Unauthorized" {
+		t.Errorf("Expected a plain 401, got %d %q", resp.StatusCode, body)
+	}
+	if enc := resp.Header.Get("Content-Encoding"); enc != "" {
+		t.Errorf("Expected no Content-Encoding on the 401, got %q", enc)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the 401 without the configured delay, took %v", elapsed)
+	}
+
+	req.Header.Set("Authorization", "Bearer tok-123")
+	req.Header.Del("Accept-Encoding")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if hits := atomic.LoadInt32(&upstreamHits); hits != 1 {
+		t.Errorf("Expected only the authorized request to reach the upstream, got %d hits", hits)
+	}
+}
+
+func TestDynamicMockServer_SetRedirect(t *testing.T) {
+	_, client := startTestController(t)
+	mockPort := freePort(t)
+	defer client.ResetPort(mockPort)
+
+	err := client.RegisterRoute(mockPort, http.MethodGet, "/old/{id}", []ResponseFuncConfig{
+		SetRedirect("", http.StatusMovedPermanently, "/new/{{.id}}"),
+	})
+	if err != nil {
+		t.Fatalf("RegisterRoute failed: %v", err)
+	}
+	err = client.RegisterRoute(mockPort, http.MethodGet, "/new/{id}", []ResponseFuncConfig{
+		SetJsonBody("", `{"id":"{{.id}}"}`),
+	})
+	if err != nil {
+		t.Fatalf("RegisterRoute failed: %v", err)
+	}
+	base := fmt.Sprintf("http://localhost:%d", mockPort)
+	if err := waitForServer(base + "/new/1"); err != nil {
+		t.Fatalf("Mock server not up: %v", err)
+	}
+
+	noFollow := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := noFollow.Get(base + "/old/42")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMovedPermanently {
+		t.Errorf("Expected 301, got %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Location"); got != "/new/42" {
+		t.Errorf("Expected Location /new/42, got %q", got)
+	}
+
+	resp, err = http.Get(base + "/old/42")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != `{"id":"42"}` {
+		t.Errorf("Expected the followed redirect to reach /new/42, got %s", body)
+	}
+
+	err = client.RegisterRoute(mockPort, http.MethodGet, "/bad", []ResponseFuncConfig{
+		SetRedirect("", http.StatusOK, "/x"),
+	})
+	if err != nil {
+		t.Fatalf("RegisterRoute failed: %v", err)
+	}
+	resp, err = http.Get(base + "/bad")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("Expected a non-3xx SetRedirect to fail the request, got %d", resp.StatusCode)
+	}
+}
+
+func TestDynamicMockServer_RegisterFromOpenAPI(t *testing.T) {
+	_, client := startTestController(t)
+	mockPort := freePort(t)
+	defer client.ResetPort(mockPort)
+
+	spec := `openapi: 3.0.0
+info:
+  title: Users
+  version: "1"
+paths:
+  /users/{id}:
+    parameters:
+      - name: id
+        in: path
+        required: true
+    get:
+      responses:
+        "404":
+          description: not found
+        "200":
+          description: ok
+          content:
+            application/json:
+              example:
+                id: 7
+                name: Alice
+    post:
+      responses:
+        default:
+          description: error
+`
+	specPath := filepath.Join(t.TempDir(), "openapi.yaml")
+	if err := os.WriteFile(specPath, []byte(spec), 0o644); err != nil {
+		t.Fatalf("write spec: %v", err)
+	}
+	if err := client.RegisterFromOpenAPI(mockPort, specPath); err != nil {
+		t.Fatalf("RegisterFromOpenAPI failed: %v", err)
+	}
+
+	url := fmt.Sprintf("http://localhost:%d/users/7", mockPort)
+	if err := waitForServer(url); err != nil {
+		t.Fatalf("Mock server not up: %v", err)
+	}
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Expected Content-Type application/json, got %q", got)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(body, &got); err != nil || got["name"] != "Alice" || got["id"] != float64(7) {
+		t.Errorf("Expected the spec example, got %s", body)
+	}
+
+	// An operation without a 2xx response is not registered
+	resp2, err := http.Post(url, "application/json", nil)
+	if err != nil {
+		t.Fatalf("POST failed: %v", err)
+	}
+	resp2.Body.Close()
+	if resp2.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected POST to be unregistered (404), got %d", resp2.StatusCode)
+	}
+
+	if err := client.RegisterFromOpenAPI(mockPort, filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Errorf("Expected an error for a missing spec file")
+	}
+}
+
+func TestDynamicMockServer_SetProxyPassthrough(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Upstream", "real")
+		w.Header().Set("X-Version", "1")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, "%s %s?%s %s", r.Method, r.URL.Path, r.URL.RawQuery, body)
+	}))
+	defer upstream.Close()
+
+	_, client := startTestController(t)
+	mockPort := freePort(t)
+	defer client.ResetPort(mockPort)
+
+	err := client.RegisterRoute(mockPort, http.MethodPost, "/api/*", []ResponseFuncConfig{
+		IfRequestHeaderSetCase("X-Mock", ConditionEqual, "yes", "Mocked"),
+		SetProxyPassthrough("", upstream.URL+"/base"),
+		SetHeader("", "X-Version", "mock-override"),
+		SetJsonBody("Mocked", `{"mocked":true}`),
+	})
+	if err != nil {
+		t.Fatalf("RegisterRoute failed: %v", err)
+	}
+	url := fmt.Sprintf("http://localhost:%d/api/orders?id=7", mockPort)
+	if err := waitForServer(url); err != nil {
+		t.Fatalf("Mock server not up: %v", err)
+	}
+
+	resp, err := http.Post(url, "text/plain", strings.NewReader("payload"))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("Expected the upstream status 201, got %d", resp.StatusCode)
+	}
+	if want := "POST /base/api/orders?id=7 payload"; string(body) != want {
+		t.Errorf("Expected relayed body %q, got %q", want, body)
+	}
+	if resp.Header.Get("X-Upstream") != "real" || resp.Header.Get("X-Version") != "mock-override" {
+		t.Errorf("Expected upstream headers with X-Version overridden, got %v", resp.Header)
+	}
+
+	// A case without the passthrough step is answered by the mock
+	req, _ := http.NewRequest(http.MethodPost, url, nil)
+	req.Header.Set("X-Mock", "yes")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != `{"mocked":true}` {
+		t.Errorf("Expected the mocked body, got %q", body)
+	}
+
+	// An unreachable upstream answers 502
+	upstream.Close()
+	resp, err = http.Post(url, "text/plain", nil)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("Expected 502 for an unreachable upstream, got %d", resp.StatusCode)
+	}
+}
+
+func TestDynamicMockServer_Interactions(t *testing.T) {
+	_, client := startTestController(t)
+	portA, portB := freePort(t), freePort(t)
+	defer client.ResetPort(portA)
+	defer client.ResetPort(portB)
+
+	for _, port := range []int{portA, portB} {
+		if err := client.RegisterRoute(port, http.MethodPost, "/call", []ResponseFuncConfig{SetStatusCode("", 204)}); err != nil {
+			t.Fatalf("RegisterRoute failed: %v", err)
+		}
+	}
+	urlA := fmt.Sprintf("http://localhost:%d/call", portA)
+	urlB := fmt.Sprintf("http://localhost:%d/call", portB)
+	if err := waitForServer(urlA); err != nil {
+		t.Fatalf("Mock server not up: %v", err)
+	}
+	if err := waitForServer(urlB); err != nil {
+		t.Fatalf("Mock server not up: %v", err)
+	}
+	if err := client.ResetInteractions(); err != nil {
+		t.Fatalf("ResetInteractions failed: %v", err)
+	}
+
+	for _, u := range []string{urlA, urlB, urlA} {
+		resp, err := http.Post(u, "text/plain", strings.NewReader("x"))
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	list, err := client.Interactions()
+	if err != nil {
+		t.Fatalf("Interactions failed: %v", err)
+	}
+	var ports []int
+	for _, rec := range list {
+		ports = append(ports, rec.Port)
+		if rec.Method != http.MethodPost || rec.Path != "/call" || rec.Body != "x" || rec.Timestamp.IsZero() {
+			t.Errorf("Unexpected interaction: %+v", rec)
+		}
+	}
+	if fmt.Sprint(ports) != fmt.Sprint([]int{portA, portB, portA}) {
+		t.Errorf("Expected interactions on ports %v, got %v", []int{portA, portB, portA}, ports)
+	}
+
+	// Large bodies are cut in the in-memory log
+	resp, err := http.Post(urlA, "text/plain", strings.NewReader(strings.Repeat("y", maxInteractionBodyBytes+10)))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	list, _ = client.Interactions()
+	if last := list[len(list)-1]; len(last.Body) != maxInteractionBodyBytes || !last.BodyTruncated {
+		t.Errorf("Expected a truncated %d byte body, got %d bytes (truncated=%v)", maxInteractionBodyBytes, len(last.Body), last.BodyTruncated)
+	}
+	if list[0].BodyTruncated {
+		t.Error("Small bodies must not be marked truncated")
+	}
+
+	if err := client.ResetInteractions(); err != nil {
+		t.Fatalf("ResetInteractions failed: %v", err)
+	}
+	if list, _ := client.Interactions(); len(list) != 0 {
+		t.Errorf("Expected no interactions after reset, got %d", len(list))
+	}
+}
+
+func TestDynamicMockServer_IfAcceptSetCase(t *testing.T) {
+	_, client := startTestController(t)
+	mockPort := freePort(t)
+	defer client.ResetPort(mockPort)
+
+	err := client.RegisterRoute(mockPort, http.MethodGet, "/user", []ResponseFuncConfig{
+		IfAcceptSetCase("application/xml", "XML"),
+		SetHeader("", "Content-Type", "application/json"),
+		SetJsonBody("", `{"name":"Alice"}`),
+		SetHeader("XML", "Content-Type", "application/xml"),
+		SetXmlBody("XML", `<user><name>Alice</name></user>`),
+	})
+	if err != nil {
+		t.Fatalf("RegisterRoute failed: %v", err)
+	}
+	url := fmt.Sprintf("http://localhost:%d/user", mockPort)
+	if err := waitForServer(url); err != nil {
+		t.Fatalf("Mock server not up: %v", err)
+	}
+
+	cases := []struct {
+		accept   string
+		wantType string
+	}{
+		{"application/xml", "application/xml"},
+		{"application/json, application/xml;q=0.9", "application/json"},
+		{"application/json;q=0.5, application/xml", "application/xml"},
+		{"application/xml;q=0", "application/json"},
+		{"*/*", "application/json"},
+		{"", "application/json"},
+	}
+	for _, c := range cases {
+		req, _ := http.NewRequest(http.MethodGet, url, nil)
+		if c.accept != "" {
+			req.Header.Set("Accept", c.accept)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if got := resp.Header.Get("Content-Type"); got != c.wantType {
+			t.Errorf("Accept %q: expected %s, got %s (%s)", c.accept, c.wantType, got, body)
+		}
+		if c.wantType == "application/xml" && string(body) != `<user><name>Alice</name></user>` {
+			t.Errorf("Accept %q: expected the XML case body, got %s", c.accept, body)
+		}
+	}
+}
+
+func TestDynamicMockServer_SetPortMode(t *testing.T) {
+	_, client := startTestController(t)
+	mockPort := freePort(t)
+	defer client.ResetPort(mockPort)
+
+	for _, path := range []string{"/a", "/b"} {
+		if err := client.RegisterRoute(mockPort, http.MethodGet, path, []ResponseFuncConfig{SetJsonBody("", `{"path":"`+path+`"}`)}); err != nil {
+			t.Fatalf("RegisterRoute failed: %v", err)
+		}
+	}
+	base := fmt.Sprintf("http://localhost:%d", mockPort)
+	if err := waitForServer(base + "/a"); err != nil {
+		t.Fatalf("Mock server not up: %v", err)
+	}
+
+	get := func(path string) (int, string) {
+		t.Helper()
+		resp, err := http.Get(base + path)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	if err := client.SetPortMode(mockPort, PortModeMaintenance); err != nil {
+		t.Fatalf("SetPortMode failed: %v", err)
+	}
+	for _, path := range []string{"/a", "/b", "/unregistered"} {
+		if status, _ := get(path); status != http.StatusServiceUnavailable {
+			t.Errorf("GET %s in maintenance: expected 503, got %d", path, status)
+		}
+	}
+
+	if err := client.SetPortMode(mockPort, PortModeNormal); err != nil {
+		t.Fatalf("SetPortMode failed: %v", err)
+	}
+	for _, path := range []string{"/a", "/b"} {
+		want := `{"path":"` + path + `"}`
+		if status, body := get(path); status != http.StatusOK || body != want {
+			t.Errorf("GET %s after maintenance: expected 200 %s, got %d %s", path, want, status, body)
+		}
+	}
+
+	if err := client.SetPortMode(mockPort, "offline"); err == nil {
+		t.Error("Expected an error for an unknown mode")
+	}
+}
+
+func TestDynamicMockServer_RegisterFuncRoute(t *testing.T) {
+	controller, client := startTestController(t)
+	mockPort := freePort(t)
+	defer client.ResetPort(mockPort)
+
+	err := controller.RegisterFuncRoute(mockPort, http.MethodPost, "/users/{id}/score", func(r *http.Request) (int, map[string]string, []byte) {
+		body, _ := io.ReadAll(r.Body)
+		score := len(body) * 10
+		return http.StatusCreated, map[string]string{"Content-Type": "application/json"},
+			[]byte(fmt.Sprintf(`{"id":%q,"score":%d}`, r.PathValue("id"), score))
+	})
+	if err != nil {
+		t.Fatalf("RegisterFuncRoute failed: %v", err)
+	}
+	url := fmt.Sprintf("http://localhost:%d/users/42/score", mockPort)
+	if err := waitForServer(url); err != nil {
+		t.Fatalf("Mock server not up: %v", err)
+	}
+
+	resp, err := http.Post(url, "text/plain", strings.NewReader("abc"))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("Expected 201, got %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Expected Content-Type application/json, got %q", ct)
+	}
+	if want := `{"id":"42","score":30}`; string(body) != want {
+		t.Errorf("Expected body %s, got %s", want, body)
+	}
+
+	// Re-registering through the control API replaces the Go handler
+	err = client.RegisterRoute(mockPort, http.MethodPost, "/users/{id}/score", []ResponseFuncConfig{SetStatusCode("", 204)})
+	if err != nil {
+		t.Fatalf("RegisterRoute failed: %v", err)
+	}
+	resp, err = http.Post(url, "text/plain", strings.NewReader("abc"))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected 204 after re-registering with steps, got %d", resp.StatusCode)
+	}
+
+	if err := controller.RegisterFuncRoute(mockPort, "FETCH", "/x", func(*http.Request) (int, map[string]string, []byte) { return 0, nil, nil }); err == nil {
+		t.Error("Expected an error for an invalid method")
+	}
+}
+
+func TestDynamicMockServer_DebugHeaders(t *testing.T) {
+	controller, client := startTestController(t)
+	controller.DebugHeaders = true
+	defer func() { controller.DebugHeaders = false }()
+	mockPort := freePort(t)
+	defer client.ResetPort(mockPort)
+
+	err := client.RegisterRoute(mockPort, http.MethodGet, "/users/{id}", []ResponseFuncConfig{
+		IfRequestHeaderSetCase("X-Tier", ConditionEqual, "gold", "Gold"),
+		SetJsonBody("", `{"tier":"standard"}`),
+		SetJsonBody("Gold", `{"tier":"gold"}`),
+	})
+	if err != nil {
+		t.Fatalf("RegisterRoute failed: %v", err)
+	}
+	url := fmt.Sprintf("http://localhost:%d/users/42", mockPort)
+	if err := waitForServer(url); err != nil {
+		t.Fatalf("Mock server not up: %v", err)
+	}
+
+	for _, c := range []struct {
+		tier     string
+		wantCase string
+	}{{"gold", "Gold"}, {"silver", ""}} {
+		req, _ := http.NewRequest(http.MethodGet, url, nil)
+		req.Header.Set("X-Tier", c.tier)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+		if got := resp.Header.Get("X-Mock-Active-Case"); got != c.wantCase {
+			t.Errorf("Tier %s: expected X-Mock-Active-Case %q, got %q", c.tier, c.wantCase, got)
+		}
+		if got := resp.Header.Get("X-Mock-Variables"); got != `{"id":"42"}` {
+			t.Errorf("Tier %s: expected X-Mock-Variables {\"id\":\"42\"}, got %q", c.tier, got)
+		}
+	}
+
+	controller.DebugHeaders = false
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if _, ok := resp.Header["X-Mock-Active-Case"]; ok {
+		t.Errorf("Expected no debug headers when DebugHeaders is off")
+	}
+}
+
+func TestDynamicMockServer_JsonChecksAndDynamicVariable(t *testing.T) {
+	_, client := startTestController(t)
+	mockPort := freePort(t)
+	defer client.ResetPort(mockPort)
+
+	err := client.RegisterRoute(mockPort, http.MethodPost, "/orders", []ResponseFuncConfig{
+		IfRequestJsonArrayLength("items", ConditionGreaterThan, 2, "BULK", "yes"),
+		IfRequestJsonObjectLength("meta", ConditionEqual, 0, "NO_META", "yes"),
+		IfRequestJsonType("customer.vip", "bool", "VIP_FLAG", "yes"),
+		ExtractRequestJsonBody("customer.age", "AGE"),
+		IfDynamicVariable("AGE", ConditionGreaterThanOrEqual, 18, "ADULT", "yes"),
+		IfDynamicVariableSetCase("AGE", ConditionLessThan, 18, "Minor"),
+		IfRequestJsonTypeSetCase("items", "string", "BadItems"),
+		IfRequestJsonArrayLengthSetCase("items", ConditionEqual, 0, "Empty"),
+		SetJsonBody("", `{"bulk":"{{.BULK}}","noMeta":"{{.NO_META}}","vip":"{{.VIP_FLAG}}","adult":"{{.ADULT}}"}`),
+		SetStatusCode("Minor", http.StatusForbidden),
+		SetStatusCode("BadItems", http.StatusBadRequest),
+		SetStatusCode("Empty", http.StatusUnprocessableEntity),
+	})
+	if err != nil {
+		t.Fatalf("RegisterRoute failed: %v", err)
+	}
+	url := fmt.Sprintf("http://localhost:%d/orders", mockPort)
+	if err := waitForServer(url); err != nil {
+		t.Fatalf("Mock server not up: %v", err)
+	}
+
+	post := func(body string) (int, map[string]string) {
+		t.Helper()
+		resp, err := http.Post(url, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		var out map[string]string
+		json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out
+	}
+
+	status, out := post(`{"items":[1,2,3],"meta":{},"customer":{"vip":true,"age":30}}`)
+	if status != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", status)
+	}
+	want := map[string]string{"bulk": "yes", "noMeta": "yes", "vip": "yes", "adult": "yes"}
+	for k, v := range want {
+		if out[k] != v {
+			t.Errorf("Expected %s=%q, got %q (%v)", k, v, out[k], out)
+		}
+	}
+
+	status, out = post(`{"items":[1],"meta":{"a":1},"customer":{"vip":"yes","age":30}}`)
+	if status != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", status)
+	}
+	for _, k := range []string{"bulk", "noMeta", "vip"} {
+		if out[k] == "yes" {
+			t.Errorf("Expected %s unset, got %v", k, out)
+		}
+	}
+
+	if status, _ := post(`{"items":[1],"customer":{"age":12}}`); status != http.StatusForbidden {
+		t.Errorf("Expected 403 for a minor, got %d", status)
+	}
+	if status, _ := post(`{"items":"abc","customer":{"age":30}}`); status != http.StatusBadRequest {
+		t.Errorf("Expected 400 for string items, got %d", status)
+	}
+	if status, _ := post(`{"items":[],"customer":{"age":30}}`); status != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422 for empty items, got %d", status)
+	}
+}
+
+func TestDynamicMockServer_WaitCancelledByClient(t *testing.T) {
+	controller, client := startTestController(t)
+	logPath := filepath.Join(t.TempDir(), "wait.log")
+	logger, err := NewLogger(logPath)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer logger.Close()
+	controller.Logger = logger
+	mockPort := freePort(t)
+	defer client.ResetPort(mockPort)
+
+	err = client.RegisterRoute(mockPort, http.MethodGet, "/slow", []ResponseFuncConfig{
+		SetWait("", 5000),
+		SetJsonBody("", `{"ok":true}`),
+	})
+	if err != nil {
+		t.Fatalf("RegisterRoute failed: %v", err)
+	}
+	if err := client.RegisterRoute(mockPort, http.MethodGet, "/ready", []ResponseFuncConfig{}); err != nil {
+		t.Fatalf("RegisterRoute failed: %v", err)
+	}
+	if err := waitForServer(fmt.Sprintf("http://localhost:%d/ready", mockPort)); err != nil {
+		t.Fatalf("Mock server not up: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("http://localhost:%d/slow", mockPort), nil)
+	if resp, err := http.DefaultClient.Do(req); err == nil {
+		resp.Body.Close()
+		t.Fatalf("Expected the request to be canceled, got status %d", resp.StatusCode)
+	}
+
+	// The handler logs the request once Finalize returns; it must not sit out the 5s wait
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		data, _ := os.ReadFile(logPath)
+		var entry LogEntry
+		found := false
+		for _, line := range strings.Split(string(data), "\n") {
+			if strings.Contains(line, `"MockRequest"`) && strings.Contains(line, `"/slow"`) {
+				json.Unmarshal([]byte(line), &entry)
+				found = true
+			}
+		}
+		if found {
+			if d, err := time.ParseDuration(entry.Duration); err != nil || d > time.Second {
+				t.Errorf("Expected the handler to return promptly, took %s", entry.Duration)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Handler still waiting 2s after the client disconnected")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
@@ -0,0 +1,72 @@
+package v1
+
+import (
+	"fmt"
+	"time"
+	"unicode/utf8"
+)
+
+// artifactPreviewMax caps how many bytes of a text artifact are shown in its log entry.
+const artifactPreviewMax = 4096
+
+// Artifact is a named blob (a screenshot, a DB dump, ...) attached to a stage run.
+type Artifact struct {
+	Name    string    `json:"name"`
+	Stage   string    `json:"stage"`
+	Time    time.Time `json:"time"`
+	Content []byte    `json:"content"`
+}
+
+// AttachArtifact associates content with the currently running stage, for reporting.
+// It is retrievable with StageArtifacts, delivered to subscribers as an EventArtifact,
+// and logged so the GUI detail popup shows it (a preview for text, the size for binary
+// content). Attaching outside a running stage only logs that it was dropped.
+func AttachArtifact(name string, content []byte) {
+	if IsDryRun() {
+		return
+	}
+	actionMu.Lock()
+	running, _ := runningStageLocked()
+	actionMu.Unlock()
+	stage, tester := running.stage, running.tester
+
+	if tester == nil || stage == "" {
+		Log(LogTypeInfo, fmt.Sprintf("Artifact %s dropped", name), "no stage is running")
+		return
+	}
+
+	a := Artifact{Name: name, Stage: stage, Time: clockNow(), Content: append([]byte(nil), content...)}
+	tester.mu.Lock()
+	if tester.artifacts == nil {
+		tester.artifacts = make(map[string][]Artifact)
+	}
+	tester.artifacts[stage] = append(tester.artifacts[stage], a)
+	tester.mu.Unlock()
+
+	Log(LogTypeInfo, fmt.Sprintf("Artifact attached: %s (%d bytes)", name, len(content)), artifactPreview(content))
+	tester.emit(TestEvent{Type: EventArtifact, Stage: stage, Time: a.Time, Artifact: &a})
+}
+
+// StageArtifacts returns the artifacts attached during the last run of the named stage.
+func (t *Tester) StageArtifacts(name string) []Artifact {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	src := t.artifacts[name]
+	dst := make([]Artifact, len(src))
+	copy(dst, src)
+	return dst
+}
+
+func artifactPreview(content []byte) string {
+	if !utf8.Valid(content) {
+		return fmt.Sprintf("(binary, %d bytes)", len(content))
+	}
+	if len(content) > artifactPreviewMax {
+		cut := artifactPreviewMax
+		for cut > 0 && !utf8.RuneStart(content[cut]) {
+			cut--
+		}
+		return string(content[:cut]) + "\n... (truncated)"
+	}
+	return string(content)
+}
@@ -0,0 +1,61 @@
+package v1
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAttachArtifact(t *testing.T) {
+	tester := NewTester()
+	tester.Stage("ArtifactStage", func() {
+		AttachArtifact("db-dump.txt", []byte("id|name\n1|Alice"))
+		AttachArtifact("screenshot.png", []byte{0x89, 'P', 'N', 'G', 0xff, 0xfe})
+		Fail("boom")
+	})
+	tester.Stage("OtherStage", func() {})
+
+	events := tester.Subscribe()
+	tester.RunStageByName("ArtifactStage")
+	tester.RunStageByName("OtherStage")
+	tester.Unsubscribe(events)
+
+	artifacts := tester.StageArtifacts("ArtifactStage")
+	if len(artifacts) != 2 {
+		t.Fatalf("Expected 2 artifacts, got %d", len(artifacts))
+	}
+	if artifacts[0].Name != "db-dump.txt" || string(artifacts[0].Content) != "id|name\n1|Alice" || artifacts[0].Stage != "ArtifactStage" {
+		t.Errorf("Unexpected first artifact: %+v", artifacts[0])
+	}
+	if artifacts[1].Name != "screenshot.png" || len(artifacts[1].Content) != 6 {
+		t.Errorf("Unexpected second artifact: %+v", artifacts[1])
+	}
+	if got := tester.StageArtifacts("OtherStage"); len(got) != 0 {
+		t.Errorf("Expected no artifacts for OtherStage, got %d", len(got))
+	}
+
+	var details []string
+	for _, e := range tester.StageLogs("ArtifactStage") {
+		if strings.HasPrefix(e.Summary, "Artifact attached") {
+			details = append(details, e.Detail)
+		}
+	}
+	if len(details) != 2 || details[0] != "id|name\n1|Alice" || details[1] != "(binary, 6 bytes)" {
+		t.Errorf("Unexpected artifact log details: %q", details)
+	}
+
+	var names []string
+	for ev := range events {
+		if ev.Type == EventArtifact {
+			names = append(names, ev.Artifact.Name)
+		}
+	}
+	if strings.Join(names, ",") != "db-dump.txt,screenshot.png" {
+		t.Errorf("Expected artifact events for both artifacts, got %v", names)
+	}
+
+	// A re-run replaces the previous run's artifacts
+	tester.RunStageByName("ArtifactStage")
+	if got := tester.StageArtifacts("ArtifactStage"); len(got) != 2 {
+		t.Errorf("Expected artifacts of the last run only, got %d", len(got))
+	}
+}
@@ -1,6 +1,9 @@
 package v1
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 // TestError represents a controlled test failure.
 type TestError struct {
@@ -40,3 +43,58 @@ func AssertNoError(err error) {
 		Fail("Unexpected error: %v", err)
 	}
 }
+
+// ExpectEqual asserts that actual deeply equals expected. Numbers compare by value across
+// Go types (int 30 == float64 30). On mismatch every differing path is reported, e.g. ".user.age: 30 != 31".
+func ExpectEqual(expected, actual interface{}) {
+	if IsDryRun() {
+		return
+	}
+	if d := diff(expected, actual); len(d) > 0 {
+		Fail("ExpectEqual failed (expected != actual):\n%s", strings.Join(d, "\n"))
+	}
+	Logf(LogTypeExpect, "Values equal: %v - PASSED", actual)
+}
+
+// ExpectErrorContains asserts that err is non-nil and its message contains substr.
+func ExpectErrorContains(err error, substr string) {
+	if err == nil {
+		Fail("ExpectErrorContains failed: expected an error containing %q, got nil", substr)
+		return
+	}
+	if !strings.Contains(err.Error(), substr) {
+		Fail("ExpectErrorContains failed: error %q does not contain %q", err.Error(), substr)
+		return
+	}
+	Log(LogTypeExpect, fmt.Sprintf("Error contains %q - PASSED", substr), err.Error())
+}
+
+// ExpectFailure runs fn and asserts that it fails with a TestError (e.g. via Fail or a failing Expect helper).
+// The failure is contained so the stage continues; if fn does not fail, ExpectFailure fails instead.
+// Panics other than TestError are re-raised.
+func ExpectFailure(fn func()) {
+	if IsDryRun() {
+		// Still run fn so its actions are discovered; Fail doesn't panic in dry-run.
+		fn()
+		return
+	}
+
+	failed, msg := func() (failed bool, msg string) {
+		defer func() {
+			if r := recover(); r != nil {
+				te, ok := r.(TestError)
+				if !ok {
+					panic(r)
+				}
+				failed, msg = true, te.Message
+			}
+		}()
+		fn()
+		return false, ""
+	}()
+
+	if !failed {
+		Fail("ExpectFailure failed: expected the block to fail, but it succeeded")
+	}
+	Log(LogTypeExpect, "Block failed as expected - PASSED", msg)
+}
@@ -2,6 +2,7 @@ package v1
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 )
 
@@ -70,3 +71,63 @@ func TestAssertNoError(t *testing.T) {
 	}()
 	AssertNoError(fmt.Errorf("some error"))
 }
+
+func TestExpectFailure(t *testing.T) {
+	// Inner block fails: ExpectFailure passes and execution continues
+	continued := false
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				t.Errorf("ExpectFailure panicked for a failing block: %v", r)
+			}
+		}()
+		ExpectFailure(func() { Fail("expected failure") })
+		continued = true
+	}()
+	if !continued {
+		t.Error("Expected execution to continue after ExpectFailure")
+	}
+
+	// Inner block succeeds: ExpectFailure fails
+	func() {
+		defer func() {
+			r := recover()
+			if r == nil {
+				t.Fatal("Expected ExpectFailure to fail for a passing block")
+			}
+			if _, ok := r.(TestError); !ok {
+				t.Errorf("Unexpected panic type: %T", r)
+			}
+		}()
+		ExpectFailure(func() {})
+	}()
+
+	// Non-TestError panics are not swallowed
+	func() {
+		defer func() {
+			if r := recover(); r != "crash" {
+				t.Errorf("Expected the original panic to propagate, got %v", r)
+			}
+		}()
+		ExpectFailure(func() { panic("crash") })
+	}()
+}
+
+func TestExpectEqual(t *testing.T) {
+	ExpectEqual(map[string]interface{}{"id": 1, "tags": []string{"a"}}, map[string]interface{}{"id": int64(1), "tags": []string{"a"}})
+
+	defer func() {
+		r := recover()
+		te, ok := r.(TestError)
+		if !ok {
+			t.Fatalf("Expected TestError panic, got %v", r)
+		}
+		if !strings.Contains(te.Message, ".user.age: 30 != 31") || strings.Contains(te.Message, ".user.name") {
+			t.Errorf("Expected failure to pinpoint .user.age only, got: %s", te.Message)
+		}
+	}()
+	ExpectEqual(
+		map[string]interface{}{"user": map[string]interface{}{"name": "alice", "age": 30}},
+		map[string]interface{}{"user": map[string]interface{}{"name": "alice", "age": 31}},
+	)
+}
@@ -0,0 +1,87 @@
+package v1
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock supplies the current time to the tester and helpers (log timestamps, stage
+// durations, deadlines, Sleep, retry delays). Replace it with SetClock to make
+// time-dependent behavior deterministic in tests.
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time        { return time.Now() }
+func (realClock) Sleep(d time.Duration) { time.Sleep(d) }
+
+var (
+	clockMu sync.Mutex
+	clock   Clock = realClock{}
+)
+
+// SetClock replaces the package clock. Passing nil restores the real clock.
+func SetClock(c Clock) {
+	clockMu.Lock()
+	defer clockMu.Unlock()
+	if c == nil {
+		c = realClock{}
+	}
+	clock = c
+}
+
+func currentClock() Clock {
+	clockMu.Lock()
+	defer clockMu.Unlock()
+	return clock
+}
+
+// clockNow returns the current time from the package clock.
+func clockNow() time.Time {
+	return currentClock().Now()
+}
+
+// clockSleep waits for d on the package clock.
+func clockSleep(d time.Duration) {
+	currentClock().Sleep(d)
+}
+
+// FakeClock is a manually driven Clock for tests. Sleep advances it instantly.
+type FakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewFakeClock returns a FakeClock set to t.
+func NewFakeClock(t time.Time) *FakeClock {
+	return &FakeClock{now: t}
+}
+
+// Now returns the fake current time.
+func (f *FakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// Sleep advances the clock by d without waiting.
+func (f *FakeClock) Sleep(d time.Duration) {
+	f.Advance(d)
+}
+
+// Advance moves the clock forward by d.
+func (f *FakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+}
+
+// Set moves the clock to t.
+func (f *FakeClock) Set(t time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = t
+}
@@ -0,0 +1,40 @@
+package v1
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	fake := NewFakeClock(start)
+	SetClock(fake)
+	defer SetClock(nil)
+
+	tester := NewTester()
+	tester.Stage("ClockStage", func() {
+		Log(LogTypeInfo, "before sleep", "")
+		Sleep(90 * time.Second)
+		Log(LogTypeInfo, "after sleep", "")
+	})
+	tester.RunStageByName("ClockStage")
+
+	times := map[string]time.Time{}
+	for _, e := range tester.StageLogs("ClockStage") {
+		times[e.Summary] = e.Time
+	}
+	if !times["before sleep"].Equal(start) {
+		t.Errorf("Expected the first entry at %v, got %v", start, times["before sleep"])
+	}
+	if want := start.Add(90 * time.Second); !times["after sleep"].Equal(want) {
+		t.Errorf("Expected the entry after Sleep at %v, got %v", want, times["after sleep"])
+	}
+	if d := tester.LastResults()[0].Duration; d != 90*time.Second {
+		t.Errorf("Expected the stage duration from the fake clock, got %v", d)
+	}
+
+	SetClock(nil)
+	if time.Since(clockNow()) > time.Minute {
+		t.Errorf("Expected SetClock(nil) to restore the real clock")
+	}
+}
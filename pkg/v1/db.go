@@ -2,16 +2,34 @@ package v1
 
 import (
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
 	"strings"
+	"text/tabwriter"
+	"time"
 )
 
+// fetchLogMaxRows caps how many result rows Fetch renders into the log detail.
+const fetchLogMaxRows = 20
+
 // Field represents a database column.
 type Field struct {
 	Name string
 	Type string
+	// Default, when set, is appended to the column definition as "DEFAULT <Default>".
+	// DefaultNow is translated to the driver's current-timestamp expression; any other
+	// value is used verbatim (e.g. "0" or "'active'").
+	Default string
 }
 
+// DefaultNow is the portable Field.Default for "the time the row is inserted":
+// CURRENT_TIMESTAMP on SQLite/Postgres/MySQL and SYSTIMESTAMP on Oracle.
+const DefaultNow = "NOW"
+
 // Index represents a database index (simple list of columns).
 type Index struct {
 	Columns []string
@@ -24,9 +42,76 @@ type InsertField struct {
 }
 
 // DBClient wraps the sql.DB connection.
+//
+// Table names are never quoted by the helpers: they are used exactly as given, so Oracle
+// folds them to uppercase ("UserAccounts" becomes USERACCOUNTS) and any later unquoted
+// reference, in either case, finds the same table. A name passed in double quotes
+// (`"UserAccounts"`) is kept quoted and so is case-sensitive; every later reference must
+// then quote it the same way.
 type DBClient struct {
 	DB         *sql.DB
 	DriverName string
+	// TablePrefix is prepended to the table name by every table helper (SetupTable, InsertOne,
+	// Update, ExpectExists, ...), e.g. "run123_" so tests can share one DB without collisions.
+	// Raw queries (Fetch, QueryData, TryExec) should build names with Table.
+	TablePrefix string
+	// SlowQueryThreshold, when positive, logs a "SLOW QUERY" warning for every statement
+	// or query that takes longer. Zero disables the check.
+	SlowQueryThreshold time.Duration
+}
+
+// Table returns tableName with TablePrefix applied, for use in raw queries. A quoted name
+// stays quoted with the prefix inside the quotes (see DBClient on identifier case); any
+// other use of double quotes fails.
+func (c *DBClient) Table(tableName string) string {
+	if inner, ok := unquoteIdent(tableName); ok {
+		return `"` + c.TablePrefix + inner + `"`
+	}
+	name := c.TablePrefix + tableName
+	if strings.Contains(name, `"`) {
+		Fail("Table name %s is not a valid identifier: quote the whole name (\"Users\") or leave it unquoted", name)
+	}
+	return name
+}
+
+// unquoteIdent returns the name inside a double-quoted identifier such as "Users".
+func unquoteIdent(name string) (string, bool) {
+	if len(name) < 3 || name[0] != '"' || name[len(name)-1] != '"' {
+		return "", false
+	}
+	inner := name[1 : len(name)-1]
+	if strings.Contains(inner, `"`) {
+		return "", false
+	}
+	return inner, true
+}
+
+// execDB runs DB.Exec, reporting it to the DB hooks and warning when it exceeds SlowQueryThreshold.
+func (c *DBClient) execDB(query string, args ...interface{}) (sql.Result, error) {
+	var res sql.Result
+	elapsed, err := observeDB(DBOpExec, query, args, func() (err error) {
+		res, err = c.DB.Exec(query, args...)
+		return err
+	})
+	c.checkSlowQuery(query, elapsed)
+	return res, err
+}
+
+// queryDB runs DB.Query, reporting it to the DB hooks and warning when it exceeds SlowQueryThreshold.
+func (c *DBClient) queryDB(query string, args ...interface{}) (*sql.Rows, error) {
+	var rows *sql.Rows
+	elapsed, err := observeDB(DBOpQuery, query, args, func() (err error) {
+		rows, err = c.DB.Query(query, args...)
+		return err
+	})
+	c.checkSlowQuery(query, elapsed)
+	return rows, err
+}
+
+func (c *DBClient) checkSlowQuery(query string, elapsed time.Duration) {
+	if c.SlowQueryThreshold > 0 && elapsed > c.SlowQueryThreshold {
+		Log(LogTypeDB, fmt.Sprintf("SLOW QUERY %s: %s", elapsed.Round(time.Millisecond), query), fmt.Sprintf("Threshold: %s", c.SlowQueryThreshold))
+	}
 }
 
 // Connect connects to the database.
@@ -61,60 +146,80 @@ func (c *DBClient) SetupTable(tableName string, isReplace bool, fields []Field,
 	if isReplace {
 		c.DropTable(tableName)
 	}
+	table := c.Table(tableName)
 
 	// Build CREATE TABLE statement
 	var fieldDefs []string
 	for _, f := range fields {
-		fieldDefs = append(fieldDefs, fmt.Sprintf("%s %s", f.Name, f.Type))
+		def := fmt.Sprintf("%s %s", f.Name, f.Type)
+		if f.Default != "" {
+			def += " DEFAULT " + c.defaultExpr(f.Default)
+		}
+		fieldDefs = append(fieldDefs, def)
 	}
 
 	var query string
 	if c.DriverName == "oracle" {
-		query = fmt.Sprintf("CREATE TABLE %s (%s)", tableName, strings.Join(fieldDefs, ", "))
+		query = fmt.Sprintf("CREATE TABLE %s (%s)", table, strings.Join(fieldDefs, ", "))
 	} else {
-		query = fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)", tableName, strings.Join(fieldDefs, ", "))
+		query = fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)", table, strings.Join(fieldDefs, ", "))
 	}
 
-	_, err := c.DB.Exec(query)
+	_, err := c.execDB(query)
 	if err != nil {
 		// If Oracle and table exists (ORA-00955), treat as success if we were mimicking IF NOT EXISTS
 		if c.DriverName == "oracle" && strings.Contains(err.Error(), "ORA-00955") {
 			// Ignored
 		} else {
-			Fail("Failed to create table %s: %v", tableName, err)
+			Fail("Failed to create table %s: %v", table, err)
 		}
 	}
 
 	// Create Indexes
 	for i, idx := range indexes {
-		idxName := fmt.Sprintf("idx_%s_%d", tableName, i)
+		idxName := fmt.Sprintf("idx_%s_%d", table, i)
+		if inner, ok := unquoteIdent(table); ok {
+			idxName = fmt.Sprintf(`"idx_%s_%d"`, inner, i)
+		}
 		var idxQuery string
 		if c.DriverName == "oracle" {
-			idxQuery = fmt.Sprintf("CREATE INDEX %s ON %s (%s)", idxName, tableName, strings.Join(idx.Columns, ", "))
+			idxQuery = fmt.Sprintf("CREATE INDEX %s ON %s (%s)", idxName, table, strings.Join(idx.Columns, ", "))
 		} else {
-			idxQuery = fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (%s)", idxName, tableName, strings.Join(idx.Columns, ", "))
+			idxQuery = fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (%s)", idxName, table, strings.Join(idx.Columns, ", "))
 		}
-		_, err := c.DB.Exec(idxQuery)
+		_, err := c.execDB(idxQuery)
 		if err != nil {
 			if c.DriverName == "oracle" && strings.Contains(err.Error(), "ORA-00955") {
 				// Ignored
 			} else {
-				Fail("Failed to create index on %s: %v", tableName, err)
+				Fail("Failed to create index on %s: %v", table, err)
 			}
 		}
 	}
 }
 
+// defaultExpr translates a Field.Default into the driver's SQL.
+func (c *DBClient) defaultExpr(value string) string {
+	if value != DefaultNow {
+		return value
+	}
+	if c.DriverName == "oracle" {
+		return "SYSTIMESTAMP"
+	}
+	return "CURRENT_TIMESTAMP"
+}
+
 // DropTable drops a table.
 func (c *DBClient) DropTable(tableName string) {
 	RecordAction(fmt.Sprintf("DB DropTable: %s", tableName), func() { c.DropTable(tableName) })
 	if IsDryRun() {
 		return
 	}
+	table := c.Table(tableName)
 	if c.DB == nil {
 		Fail("DBClient is not connected")
 	}
-	Logf(LogTypeDB, "Dropping table '%s'", tableName)
+	Logf(LogTypeDB, "Dropping table '%s'", table)
 
 	var query string
 	if c.DriverName == "oracle" {
@@ -124,14 +229,14 @@ func (c *DBClient) DropTable(tableName string) {
 			EXECUTE IMMEDIATE 'DROP TABLE %s PURGE';
 			EXCEPTION WHEN OTHERS THEN
 				IF SQLCODE != -942 THEN RAISE; END IF;
-			END;`, tableName)
+			END;`, table)
 	} else {
-		query = fmt.Sprintf("DROP TABLE IF EXISTS %s", tableName)
+		query = fmt.Sprintf("DROP TABLE IF EXISTS %s", table)
 	}
 
-	_, err := c.DB.Exec(query)
+	_, err := c.execDB(query)
 	if err != nil {
-		Fail("Failed to drop table %s: %v", tableName, err)
+		Fail("Failed to drop table %s: %v", table, err)
 	}
 }
 
@@ -141,13 +246,14 @@ func (c *DBClient) CleanTable(tableName string) {
 	if IsDryRun() {
 		return
 	}
+	table := c.Table(tableName)
 	if c.DB == nil {
 		Fail("DBClient is not connected")
 	}
-	Logf(LogTypeDB, "Cleaning table '%s'", tableName)
-	_, err := c.DB.Exec(fmt.Sprintf("DELETE FROM %s", tableName))
+	Logf(LogTypeDB, "Cleaning table '%s'", table)
+	_, err := c.execDB(fmt.Sprintf("DELETE FROM %s", table))
 	if err != nil {
-		Fail("Failed to clean table %s: %v", tableName, err)
+		Fail("Failed to clean table %s: %v", table, err)
 	}
 }
 
@@ -158,7 +264,7 @@ func (c *DBClient) DeleteOne(tableName string, where string, args ...interface{}
 	if IsDryRun() {
 		return
 	}
-	c.deleteWithLimitInternal(tableName, where, 1, args...)
+	c.deleteWithLimitInternal(tableName, where, "", 1, args...)
 }
 
 // DeleteWithLimit deletes up to `limit` rows matching the where clause.
@@ -168,11 +274,23 @@ func (c *DBClient) DeleteWithLimit(tableName string, where string, limit int, ar
 	if IsDryRun() {
 		return
 	}
-	c.deleteWithLimitInternal(tableName, where, limit, args...)
+	c.deleteWithLimitInternal(tableName, where, "", limit, args...)
+}
+
+// DeleteWithLimitOrdered deletes up to `limit` rows matching the where clause,
+// picking rows in the given ORDER BY order (e.g. "created_at ASC" deletes the oldest first).
+// If limit <= 0, it deletes all rows matching the condition and orderBy is ignored.
+func (c *DBClient) DeleteWithLimitOrdered(tableName string, where string, orderBy string, limit int, args ...interface{}) {
+	RecordAction(fmt.Sprintf("DB DeleteWithLimitOrdered: %s", tableName), func() { c.DeleteWithLimitOrdered(tableName, where, orderBy, limit, args...) })
+	if IsDryRun() {
+		return
+	}
+	c.deleteWithLimitInternal(tableName, where, orderBy, limit, args...)
 }
 
 // deleteWithLimitInternal contains the shared delete logic.
-func (c *DBClient) deleteWithLimitInternal(tableName string, where string, limit int, args ...interface{}) {
+func (c *DBClient) deleteWithLimitInternal(tableName string, where string, orderBy string, limit int, args ...interface{}) {
+	tableName = c.Table(tableName)
 	if c.DB == nil {
 		Fail("DBClient is not connected")
 	}
@@ -194,24 +312,34 @@ func (c *DBClient) deleteWithLimitInternal(tableName string, where string, limit
 	var allArgs []interface{}
 	allArgs = append(allArgs, args...)
 
+	orderClause := ""
+	if strings.TrimSpace(orderBy) != "" {
+		orderClause = " ORDER BY " + orderBy
+	}
+
 	if limit > 0 {
 		switch c.DriverName {
 		case "oracle":
-			query = fmt.Sprintf("DELETE FROM %s WHERE (%s) AND ROWNUM <= %d", tableName, finalWhere, limit)
+			if orderClause != "" {
+				// ROWNUM is assigned before ORDER BY, so order in an inner query first
+				query = fmt.Sprintf("DELETE FROM %s WHERE ROWID IN (SELECT rid FROM (SELECT ROWID AS rid FROM %s WHERE (%s)%s) WHERE ROWNUM <= %d)", tableName, tableName, finalWhere, orderClause, limit)
+			} else {
+				query = fmt.Sprintf("DELETE FROM %s WHERE (%s) AND ROWNUM <= %d", tableName, finalWhere, limit)
+			}
 		case "postgres", "postgresql":
 			// Postgres has no DELETE ... LIMIT; use CTE
-			query = fmt.Sprintf("WITH cte AS (SELECT ctid FROM %s WHERE %s LIMIT %d) DELETE FROM %s WHERE ctid IN (SELECT ctid FROM cte)", tableName, finalWhere, limit, tableName)
+			query = fmt.Sprintf("WITH cte AS (SELECT ctid FROM %s WHERE %s%s LIMIT %d) DELETE FROM %s WHERE ctid IN (SELECT ctid FROM cte)", tableName, finalWhere, orderClause, limit, tableName)
 		case "sqlite3":
 			// Some SQLite builds don't accept DELETE ... LIMIT; use rowid subquery
-			query = fmt.Sprintf("DELETE FROM %s WHERE rowid IN (SELECT rowid FROM %s WHERE %s LIMIT %d)", tableName, tableName, finalWhere, limit)
+			query = fmt.Sprintf("DELETE FROM %s WHERE rowid IN (SELECT rowid FROM %s WHERE %s%s LIMIT %d)", tableName, tableName, finalWhere, orderClause, limit)
 		default:
-			// MySQL/SQLite support LIMIT in DELETE
-			query = fmt.Sprintf("DELETE FROM %s WHERE %s LIMIT %d", tableName, finalWhere, limit)
+			// MySQL/SQLite support LIMIT (and ORDER BY) in DELETE
+			query = fmt.Sprintf("DELETE FROM %s WHERE %s%s LIMIT %d", tableName, finalWhere, orderClause, limit)
 		}
 	}
 
 	Log(LogTypeDB, "Delete Rows", fmt.Sprintf("Query: %s\nArgs: %v", query, allArgs))
-	_, err := c.DB.Exec(query, allArgs...)
+	_, err := c.execDB(query, allArgs...)
 	if err != nil {
 		Fail("Failed to delete from %s: %v", tableName, err)
 	}
@@ -238,6 +366,27 @@ func (c *DBClient) InsertOne(tableName string, fields []InsertField) {
 	if IsDryRun() {
 		return
 	}
+	if err := c.insertOne(tableName, fields); err != nil {
+		Fail("Failed to insert into %s: %v", tableName, err)
+	}
+}
+
+// TryInsertOne is like InsertOne but returns the database error instead of failing,
+// so an expected failure (e.g. a constraint violation) can be asserted with ExpectErrorContains.
+func (c *DBClient) TryInsertOne(tableName string, fields []InsertField) error {
+	RecordAction(fmt.Sprintf("DB TryInsertOne: %s", tableName), func() { c.TryInsertOne(tableName, fields) })
+	if IsDryRun() {
+		return nil
+	}
+	err := c.insertOne(tableName, fields)
+	if err != nil {
+		Log(LogTypeDB, "Insert One returned error", err.Error())
+	}
+	return err
+}
+
+func (c *DBClient) insertOne(tableName string, fields []InsertField) error {
+	tableName = c.Table(tableName)
 	if c.DB == nil {
 		Fail("DBClient is not connected")
 	}
@@ -268,10 +417,25 @@ func (c *DBClient) InsertOne(tableName string, fields []InsertField) {
 	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", tableName, strings.Join(cols, ", "), strings.Join(placeholders, ", "))
 	Log(LogTypeDB, "Insert One", fmt.Sprintf("Query: %s\nArgs: %v", query, values))
 
-	_, err := c.DB.Exec(query, values...)
+	_, err := c.execDB(query, values...)
+	return err
+}
+
+// TryExec executes a statement and returns its error instead of failing.
+func (c *DBClient) TryExec(query string, args ...interface{}) error {
+	RecordAction("DB TryExec", func() { c.TryExec(query, args...) })
+	if IsDryRun() {
+		return nil
+	}
+	if c.DB == nil {
+		Fail("DBClient is not connected")
+	}
+	Log(LogTypeDB, "Exec", fmt.Sprintf("Query: %s\nArgs: %v", query, args))
+	_, err := c.execDB(query, args...)
 	if err != nil {
-		Fail("Failed to insert into %s: %v", tableName, err)
+		Log(LogTypeDB, "Exec returned error", err.Error())
 	}
+	return err
 }
 
 // ReplaceData inserts or replaces data.
@@ -281,10 +445,11 @@ func (c *DBClient) ReplaceData(tableName string, values []interface{}) {
 	if IsDryRun() {
 		return
 	}
+	table := c.Table(tableName)
 	if c.DB == nil {
 		Fail("DBClient is not connected")
 	}
-	Log(LogTypeDB, fmt.Sprintf("Replacing data in '%s'", tableName), fmt.Sprintf("%v", values))
+	Log(LogTypeDB, fmt.Sprintf("Replacing data in '%s'", table), fmt.Sprintf("%v", values))
 	// We need to know placeholders.
 	placeholders := make([]string, len(values))
 	for i := range values {
@@ -301,10 +466,10 @@ func (c *DBClient) ReplaceData(tableName string, values []interface{}) {
 	// but without PK, DELETE is hard.
 	// I'll stick to INSERT for now or try "REPLACE INTO" which works on SQLite/MySQL.
 
-	query := fmt.Sprintf("INSERT INTO %s VALUES (%s)", tableName, strings.Join(placeholders, ", "))
-	_, err := c.DB.Exec(query, values...)
+	query := fmt.Sprintf("INSERT INTO %s VALUES (%s)", table, strings.Join(placeholders, ", "))
+	_, err := c.execDB(query, values...)
 	if err != nil {
-		Fail("Failed to insert/replace data into %s: %v", tableName, err)
+		Fail("Failed to insert/replace data into %s: %v", table, err)
 	}
 }
 
@@ -330,7 +495,7 @@ func (c *DBClient) QueryData(query string, args ...interface{}) *sql.Rows {
 	}
 
 	Log(LogTypeDB, "Query Data", fmt.Sprintf("Query: %s\nArgs: %v", finalQuery, args))
-	rows, err := c.DB.Query(finalQuery, args...)
+	rows, err := c.queryDB(finalQuery, args...)
 	if err != nil {
 		Fail("Failed to query data: %v", err)
 	}
@@ -342,6 +507,8 @@ func (c *DBClient) QueryData(query string, args ...interface{}) *sql.Rows {
 // QueryResult holds the results of a Fetch operation.
 type QueryResult struct {
 	Rows []RowResult
+	// Columns lists the (lowercased) column names in query order.
+	Columns []string
 }
 
 // RowResult represents a single row from the database.
@@ -364,6 +531,10 @@ func (c *DBClient) Fetch(query string, args ...interface{}) *QueryResult {
 	}
 
 	var results []RowResult
+	keys := make([]string, len(columns))
+	for i, col := range columns {
+		keys[i] = strings.ToLower(col)
+	}
 
 	for rows.Next() {
 		// Prepare a slice of interface{} to hold values
@@ -378,11 +549,10 @@ func (c *DBClient) Fetch(query string, args ...interface{}) *QueryResult {
 		}
 
 		rowData := make(map[string]interface{})
-		for i, col := range columns {
+		for i, key := range keys {
 			val := values[i]
 
 			// Handle []byte as string for convenience, common in some drivers/types
-			key := strings.ToLower(col)
 			if b, ok := val.([]byte); ok {
 				rowData[key] = string(b)
 			} else {
@@ -392,7 +562,107 @@ func (c *DBClient) Fetch(query string, args ...interface{}) *QueryResult {
 		results = append(results, RowResult{Data: rowData})
 	}
 
-	return &QueryResult{Rows: results}
+	result := &QueryResult{Rows: results, Columns: keys}
+	Log(LogTypeDB, fmt.Sprintf("Fetched %d row(s)", len(results)), result.table(fetchLogMaxRows))
+	return result
+}
+
+// FetchNamed is like Fetch but takes ":name" style parameters, e.g.
+//
+//	db.FetchNamed("SELECT * FROM users WHERE age > :min AND name = :name",
+//	    map[string]interface{}{"min": 18, "name": "Alice"})
+//
+// Names are translated to the driver's placeholder scheme and args are ordered to match.
+// A name may appear more than once; every name used must be present in params.
+func (c *DBClient) FetchNamed(query string, params map[string]interface{}) *QueryResult {
+	RecordAction("DB FetchNamed", func() { c.FetchNamed(query, params) })
+	if IsDryRun() {
+		return &QueryResult{}
+	}
+	finalQuery, args := bindNamedParams(c.DriverName, query, params)
+	return c.Fetch(finalQuery, args...)
+}
+
+// bindNamedParams rewrites ":name" parameters into positional placeholders and returns the ordered args.
+// Postgres gets $n; other drivers get "?" (which QueryData rewrites to :n for Oracle).
+// Quoted literals and "::" casts are left untouched.
+func bindNamedParams(driverName, query string, params map[string]interface{}) (string, []interface{}) {
+	isIdentChar := func(ch byte, first bool) bool {
+		if ch == '_' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') {
+			return true
+		}
+		return !first && ch >= '0' && ch <= '9'
+	}
+
+	var sb strings.Builder
+	var args []interface{}
+	inQuote := false
+	for i := 0; i < len(query); i++ {
+		ch := query[i]
+		if ch == '\'' {
+			inQuote = !inQuote
+			sb.WriteByte(ch)
+			continue
+		}
+		if inQuote || ch != ':' {
+			sb.WriteByte(ch)
+			continue
+		}
+		// Skip "::" casts
+		if i+1 < len(query) && query[i+1] == ':' {
+			sb.WriteString("::")
+			i++
+			continue
+		}
+		if i+1 >= len(query) || !isIdentChar(query[i+1], true) {
+			sb.WriteByte(ch)
+			continue
+		}
+
+		j := i + 1
+		for j < len(query) && isIdentChar(query[j], false) {
+			j++
+		}
+		name := query[i+1 : j]
+		val, ok := params[name]
+		if !ok {
+			Fail("FetchNamed: missing value for parameter :%s", name)
+		}
+		args = append(args, val)
+		if driverName == "postgres" || driverName == "postgresql" {
+			sb.WriteString(fmt.Sprintf("$%d", len(args)))
+		} else {
+			sb.WriteString("?")
+		}
+		i = j - 1
+	}
+	return sb.String(), args
+}
+
+// table renders up to maxRows rows as an aligned text table (header + rows), for log details.
+func (qr *QueryResult) table(maxRows int) string {
+	var sb strings.Builder
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(qr.Columns, "\t"))
+	for i, row := range qr.Rows {
+		if i >= maxRows {
+			break
+		}
+		cells := make([]string, len(qr.Columns))
+		for j, col := range qr.Columns {
+			if v := row.Data[col]; v == nil {
+				cells[j] = "NULL"
+			} else {
+				cells[j] = fmt.Sprintf("%v", v)
+			}
+		}
+		fmt.Fprintln(w, strings.Join(cells, "\t"))
+	}
+	w.Flush()
+	if len(qr.Rows) > maxRows {
+		fmt.Fprintf(&sb, "... %d more row(s)\n", len(qr.Rows)-maxRows)
+	}
+	return strings.TrimRight(sb.String(), "\n")
 }
 
 // Update performs a partial update on a table based on a condition.
@@ -404,6 +674,7 @@ func (c *DBClient) Update(tableName string, updates map[string]interface{}, wher
 	if IsDryRun() {
 		return
 	}
+	table := c.Table(tableName)
 	if c.DB == nil {
 		Fail("DBClient is not connected")
 	}
@@ -441,16 +712,316 @@ func (c *DBClient) Update(tableName string, updates map[string]interface{}, wher
 	// Append WHERE args
 	values = append(values, args...)
 
-	query := fmt.Sprintf("UPDATE %s SET %s WHERE %s", tableName, strings.Join(sets, ", "), finalWhere)
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE %s", table, strings.Join(sets, ", "), finalWhere)
 
 	Log(LogTypeDB, "Update Table", fmt.Sprintf("Query: %s\nArgs: %v", query, values))
 
-	_, err := c.DB.Exec(query, values...)
+	_, err := c.execDB(query, values...)
+	if err != nil {
+		Fail("Failed to update table %s: %v", table, err)
+	}
+}
+
+// UpdateMany applies different updates to many rows in one call: for each key of
+// updatesByKey it sets the given columns on the rows where keyColumn equals that key.
+// The statements (one UPDATE per key, in key order) run in a single transaction, so
+// either every update applies or, on the first error, none does.
+func (c *DBClient) UpdateMany(tableName string, keyColumn string, updatesByKey map[interface{}]map[string]interface{}) {
+	RecordAction(fmt.Sprintf("DB UpdateMany: %s", tableName), func() { c.UpdateMany(tableName, keyColumn, updatesByKey) })
+	if IsDryRun() {
+		return
+	}
+	table := c.Table(tableName)
+	if c.DB == nil {
+		Fail("DBClient is not connected")
+	}
+	if len(updatesByKey) == 0 {
+		return
+	}
+
+	keys := make([]interface{}, 0, len(updatesByKey))
+	for k := range updatesByKey {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+
+	tx, err := c.DB.Begin()
 	if err != nil {
-		Fail("Failed to update table %s: %v", tableName, err)
+		Fail("Failed to begin transaction for updates to %s: %v", table, err)
+	}
+	var rows int64
+	var details []string
+	for _, key := range keys {
+		updates := updatesByKey[key]
+		if len(updates) == 0 {
+			continue
+		}
+		cols := make([]string, 0, len(updates))
+		for col := range updates {
+			cols = append(cols, col)
+		}
+		sort.Strings(cols)
+
+		sets := make([]string, len(cols))
+		values := make([]interface{}, 0, len(cols)+1)
+		for i, col := range cols {
+			ph := "?"
+			if c.DriverName == "oracle" {
+				ph = fmt.Sprintf(":%d", i+1)
+			}
+			sets[i] = fmt.Sprintf("%s = %s", col, ph)
+			values = append(values, updates[col])
+		}
+		keyPh := "?"
+		if c.DriverName == "oracle" {
+			keyPh = fmt.Sprintf(":%d", len(cols)+1)
+		}
+		values = append(values, key)
+		query := fmt.Sprintf("UPDATE %s SET %s WHERE %s = %s", table, strings.Join(sets, ", "), keyColumn, keyPh)
+
+		var res sql.Result
+		elapsed, err := observeDB(DBOpTxExec, query, values, func() (err error) {
+			res, err = tx.Exec(query, values...)
+			return err
+		})
+		c.checkSlowQuery(query, elapsed)
+		if err != nil {
+			tx.Rollback()
+			Fail("Failed to update %s where %s = %v (rolled back): %v", table, keyColumn, key, err)
+		}
+		if n, err := res.RowsAffected(); err == nil {
+			rows += n
+		}
+		details = append(details, fmt.Sprintf("%s = %v: %v", keyColumn, key, values[:len(cols)]))
+	}
+	if err := tx.Commit(); err != nil {
+		Fail("Failed to commit updates to %s: %v", table, err)
+	}
+	Log(LogTypeDB, fmt.Sprintf("Updated %d key(s) in '%s' (%d row(s))", len(details), table, rows), strings.Join(details, "\n"))
+}
+
+// Upsert inserts a row, or updates its non-key columns if a row with the same keyColumns exists.
+// values maps column name -> value and must include every key column.
+// SQLite/Postgres use INSERT ... ON CONFLICT (which requires a unique index on keyColumns),
+// MySQL uses ON DUPLICATE KEY UPDATE and Oracle uses MERGE.
+func (c *DBClient) Upsert(tableName string, values map[string]interface{}, keyColumns []string) {
+	RecordAction(fmt.Sprintf("DB Upsert: %s", tableName), func() { c.Upsert(tableName, values, keyColumns) })
+	if IsDryRun() {
+		return
+	}
+	table := c.Table(tableName)
+	if c.DB == nil {
+		Fail("DBClient is not connected")
+	}
+	if len(keyColumns) == 0 {
+		Fail("Upsert requires at least one key column")
+	}
+
+	isKey := make(map[string]bool, len(keyColumns))
+	for _, k := range keyColumns {
+		if _, ok := values[k]; !ok {
+			Fail("Upsert: key column %s is missing from values", k)
+		}
+		isKey[k] = true
+	}
+	// Sorted so the generated query (and its log) is deterministic
+	cols := make([]string, 0, len(values))
+	for col := range values {
+		cols = append(cols, col)
+	}
+	sort.Strings(cols)
+
+	var args []interface{}
+	var placeholders, updateCols []string
+	for i, col := range cols {
+		switch c.DriverName {
+		case "oracle":
+			placeholders = append(placeholders, fmt.Sprintf(":%d", i+1))
+		case "postgres", "postgresql":
+			placeholders = append(placeholders, fmt.Sprintf("$%d", i+1))
+		default:
+			placeholders = append(placeholders, "?")
+		}
+		args = append(args, values[col])
+		if !isKey[col] {
+			updateCols = append(updateCols, col)
+		}
+	}
+
+	var query string
+	switch c.DriverName {
+	case "oracle":
+		var src, on, sets, srcCols []string
+		for i, col := range cols {
+			src = append(src, fmt.Sprintf("%s AS %s", placeholders[i], col))
+			srcCols = append(srcCols, "s."+col)
+		}
+		for _, k := range keyColumns {
+			on = append(on, fmt.Sprintf("t.%s = s.%s", k, k))
+		}
+		for _, col := range updateCols {
+			sets = append(sets, fmt.Sprintf("t.%s = s.%s", col, col))
+		}
+		query = fmt.Sprintf("MERGE INTO %s t USING (SELECT %s FROM dual) s ON (%s)", table, strings.Join(src, ", "), strings.Join(on, " AND "))
+		if len(sets) > 0 {
+			query += " WHEN MATCHED THEN UPDATE SET " + strings.Join(sets, ", ")
+		}
+		query += fmt.Sprintf(" WHEN NOT MATCHED THEN INSERT (%s) VALUES (%s)", strings.Join(cols, ", "), strings.Join(srcCols, ", "))
+	case "mysql":
+		var sets []string
+		for _, col := range updateCols {
+			sets = append(sets, fmt.Sprintf("%s = VALUES(%s)", col, col))
+		}
+		if len(sets) == 0 {
+			// Nothing to update; a no-op assignment keeps the statement valid
+			sets = append(sets, fmt.Sprintf("%s = %s", keyColumns[0], keyColumns[0]))
+		}
+		query = fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON DUPLICATE KEY UPDATE %s", table, strings.Join(cols, ", "), strings.Join(placeholders, ", "), strings.Join(sets, ", "))
+	default:
+		var sets []string
+		for _, col := range updateCols {
+			sets = append(sets, fmt.Sprintf("%s = excluded.%s", col, col))
+		}
+		action := "DO NOTHING"
+		if len(sets) > 0 {
+			action = "DO UPDATE SET " + strings.Join(sets, ", ")
+		}
+		query = fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON CONFLICT (%s) %s", table, strings.Join(cols, ", "), strings.Join(placeholders, ", "), strings.Join(keyColumns, ", "), action)
+	}
+
+	Log(LogTypeDB, "Upsert", fmt.Sprintf("Query: %s\nArgs: %v", query, args))
+	if _, err := c.execDB(query, args...); err != nil {
+		Fail("Failed to upsert into %s: %v", table, err)
 	}
 }
 
+// WhereEq builds an equality WHERE clause ("a = ? AND b = ?") from cols, with columns in sorted
+// order and args aligned to the placeholders. A nil value becomes "col IS NULL" and takes no arg.
+// Use it with Fetch, Update, DeleteWithLimit, etc.; an empty map returns an empty clause.
+func WhereEq(cols map[string]interface{}) (clause string, args []interface{}) {
+	names := make([]string, 0, len(cols))
+	for col := range cols {
+		names = append(names, col)
+	}
+	sort.Strings(names)
+
+	conds := make([]string, 0, len(names))
+	for _, col := range names {
+		if cols[col] == nil {
+			conds = append(conds, col+" IS NULL")
+			continue
+		}
+		conds = append(conds, col+" = ?")
+		args = append(args, cols[col])
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+// ExpectExists asserts that at least one row in the table matches the where clause.
+func (c *DBClient) ExpectExists(tableName string, where string, args ...interface{}) {
+	RecordAction(fmt.Sprintf("DB ExpectExists: %s", tableName), func() { c.ExpectExists(tableName, where, args...) })
+	if IsDryRun() {
+		return
+	}
+	if !c.rowExists(tableName, where, args...) {
+		Fail("Expected a row in %s matching %q %v, found none", tableName, where, args)
+	}
+	Logf(LogTypeExpect, "Row exists in %s where %s %v - PASSED", tableName, where, args)
+}
+
+// ExpectNotExists asserts that no row in the table matches the where clause.
+func (c *DBClient) ExpectNotExists(tableName string, where string, args ...interface{}) {
+	RecordAction(fmt.Sprintf("DB ExpectNotExists: %s", tableName), func() { c.ExpectNotExists(tableName, where, args...) })
+	if IsDryRun() {
+		return
+	}
+	if c.rowExists(tableName, where, args...) {
+		Fail("Expected no row in %s matching %q %v, but found one", tableName, where, args)
+	}
+	Logf(LogTypeExpect, "No row in %s where %s %v - PASSED", tableName, where, args)
+}
+
+// ExpectNoOrphans asserts referential integrity: every non-NULL childTable.fkColumn value
+// must match some parentTable.pkColumn. It fails listing the orphaned keys otherwise.
+func (c *DBClient) ExpectNoOrphans(childTable, fkColumn, parentTable, pkColumn string) {
+	RecordAction(fmt.Sprintf("DB ExpectNoOrphans: %s.%s -> %s.%s", childTable, fkColumn, parentTable, pkColumn), func() {
+		c.ExpectNoOrphans(childTable, fkColumn, parentTable, pkColumn)
+	})
+	if IsDryRun() {
+		return
+	}
+	child, parent := c.Table(childTable), c.Table(parentTable)
+	if c.DB == nil {
+		Fail("DBClient is not connected")
+	}
+
+	query := fmt.Sprintf("SELECT DISTINCT ch.%s FROM %s ch LEFT JOIN %s p ON ch.%s = p.%s WHERE ch.%s IS NOT NULL AND p.%s IS NULL ORDER BY 1",
+		fkColumn, child, parent, fkColumn, pkColumn, fkColumn, pkColumn)
+	Log(LogTypeDB, "Check Orphans", fmt.Sprintf("Query: %s", query))
+	rows, err := c.queryDB(query)
+	if err != nil {
+		Fail("Failed to check orphans in %s: %v", child, err)
+	}
+	defer rows.Close()
+	var orphans []string
+	for rows.Next() {
+		var key interface{}
+		if err := rows.Scan(&key); err != nil {
+			Fail("Failed to scan orphan key: %v", err)
+		}
+		if b, ok := key.([]byte); ok {
+			key = string(b)
+		}
+		orphans = append(orphans, fmt.Sprintf("%v", key))
+	}
+	if err := rows.Err(); err != nil {
+		Fail("Failed to check orphans in %s: %v", child, err)
+	}
+	if len(orphans) > 0 {
+		Fail("Found %d orphaned %s.%s value(s) with no matching %s.%s: %s",
+			len(orphans), child, fkColumn, parent, pkColumn, strings.Join(orphans, ", "))
+	}
+	Logf(LogTypeExpect, "No orphans in %s.%s -> %s.%s - PASSED", child, fkColumn, parent, pkColumn)
+}
+
+// rowExists runs a driver-appropriate "SELECT 1 ... LIMIT 1" and reports whether a row came back.
+func (c *DBClient) rowExists(tableName string, where string, args ...interface{}) bool {
+	tableName = c.Table(tableName)
+	if c.DB == nil {
+		Fail("DBClient is not connected")
+	}
+
+	finalWhere := where
+	if c.DriverName == "oracle" {
+		argCounter := 1
+		count := strings.Count(where, "?")
+		for i := 0; i < count; i++ {
+			finalWhere = strings.Replace(finalWhere, "?", fmt.Sprintf(":%d", argCounter), 1)
+			argCounter++
+		}
+	}
+
+	var query string
+	switch c.DriverName {
+	case "oracle":
+		query = fmt.Sprintf("SELECT 1 FROM %s WHERE (%s) AND ROWNUM <= 1", tableName, finalWhere)
+	default:
+		query = fmt.Sprintf("SELECT 1 FROM %s WHERE %s LIMIT 1", tableName, finalWhere)
+	}
+
+	Log(LogTypeDB, "Check Row Exists", fmt.Sprintf("Query: %s\nArgs: %v", query, args))
+	rows, err := c.queryDB(query, args...)
+	if err != nil {
+		Fail("Failed to check rows in %s: %v", tableName, err)
+	}
+	defer rows.Close()
+	exists := rows.Next()
+	if err := rows.Err(); err != nil {
+		Fail("Failed to check rows in %s: %v", tableName, err)
+	}
+	return exists
+}
+
 // --- QueryResult Helpers ---
 
 // GetRow returns the row at the specified index. Panics if index is out of bounds.
@@ -481,6 +1052,52 @@ func (qr *QueryResult) ExpectCount(expected int) {
 	Logf(LogTypeExpect, "Row Count %d == %d - PASSED", count, expected)
 }
 
+// ExpectRows asserts that the result contains exactly the expected rows, in any order.
+// Column names are case-insensitive and numbers compare with a small tolerance.
+// On failure, every unmatched expected row and every extra actual row is listed.
+func (qr *QueryResult) ExpectRows(expected []map[string]interface{}) {
+	if IsDryRun() {
+		return
+	}
+	matched := make([]bool, len(qr.Rows))
+	var missing []string
+	for _, exp := range expected {
+		want := make(map[string]interface{}, len(exp))
+		for k, v := range exp {
+			want[strings.ToLower(k)] = v
+		}
+		found := false
+		for j, row := range qr.Rows {
+			if !matched[j] && rowsEqual(want, row.Data) {
+				matched[j] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, fmt.Sprintf("%v", want))
+		}
+	}
+	var extra []string
+	for j, row := range qr.Rows {
+		if !matched[j] {
+			extra = append(extra, fmt.Sprintf("%v", row.Data))
+		}
+	}
+	if len(missing) > 0 || len(extra) > 0 {
+		var sb strings.Builder
+		sb.WriteString("ExpectRows failed:")
+		for _, m := range missing {
+			sb.WriteString("\n  missing: " + m)
+		}
+		for _, e := range extra {
+			sb.WriteString("\n  extra:   " + e)
+		}
+		Fail("%s", sb.String())
+	}
+	Logf(LogTypeExpect, "Rows match %d expected row(s) - PASSED", len(expected))
+}
+
 // --- RowResult Helpers ---
 
 // Get returns the value of a field. Panics if field does not exist.
@@ -543,3 +1160,178 @@ func (r *RowResult) ExpectCond(field string, condition string, expected interfac
 
 	Logf(LogTypeExpect, "DB Field '%s' %s %v - PASSED", field, condition, expected)
 }
+
+// ExpectNull asserts that the field exists and is SQL NULL.
+func (r *RowResult) ExpectNull(field string) {
+	if IsDryRun() {
+		return
+	}
+	val := r.Get(field)
+	if !isSQLNull(val) {
+		Fail("ExpectNull failed for field '%s': expected NULL, got %v (%T)", field, val, val)
+	}
+	Logf(LogTypeExpect, "DB Field '%s' IS NULL - PASSED", field)
+}
+
+// ExpectNotNull asserts that the field exists and is not SQL NULL.
+// An empty string counts as a value (note that Oracle itself stores empty strings as NULL).
+func (r *RowResult) ExpectNotNull(field string) {
+	if IsDryRun() {
+		return
+	}
+	val := r.Get(field)
+	if isSQLNull(val) {
+		Fail("ExpectNotNull failed for field '%s': got NULL", field)
+	}
+	Logf(LogTypeExpect, "DB Field '%s' IS NOT NULL (%v) - PASSED", field, val)
+}
+
+// isSQLNull reports whether a scanned value represents NULL: a nil interface, a nil
+// pointer, or a driver.Valuer (e.g. sql.NullString) whose value is nil.
+func isSQLNull(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	if valuer, ok := v.(driver.Valuer); ok {
+		dv, err := valuer.Value()
+		return err == nil && dv == nil
+	}
+	rv := reflect.ValueOf(v)
+	return rv.Kind() == reflect.Ptr && rv.IsNil()
+}
+
+// queryResultsTolerance is the relative tolerance used when comparing numeric values across result sets.
+const queryResultsTolerance = 1e-9
+
+// ExpectQueryResultsEqual asserts that queryA on a and queryB on b return the same rows.
+// Row order is ignored, and numbers compare with a small tolerance (so int64 10 == float64 10.0).
+// args are passed to both queries. Useful for verifying a migration copied data intact.
+func ExpectQueryResultsEqual(a *DBClient, queryA string, b *DBClient, queryB string, args ...interface{}) {
+	RecordAction("DB ExpectQueryResultsEqual", func() { ExpectQueryResultsEqual(a, queryA, b, queryB, args...) })
+	if IsDryRun() {
+		return
+	}
+	resA := a.Fetch(queryA, args...)
+	resB := b.Fetch(queryB, args...)
+
+	if resA.Count() != resB.Count() {
+		Fail("ExpectQueryResultsEqual failed: row count %d != %d", resA.Count(), resB.Count())
+	}
+
+	matched := make([]bool, len(resB.Rows))
+	for i, rowA := range resA.Rows {
+		found := false
+		for j, rowB := range resB.Rows {
+			if !matched[j] && rowsEqual(rowA.Data, rowB.Data) {
+				matched[j] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			Fail("ExpectQueryResultsEqual failed: row %d of the first result has no match in the second: %v", i, rowA.Data)
+		}
+	}
+	Logf(LogTypeExpect, "Query results equal (%d rows) - PASSED", resA.Count())
+}
+
+// ExpectAggregate asserts the single value returned by query, typically an aggregate such
+// as SUM, AVG or MAX. Numbers compare with a small relative tolerance, so AVG results and
+// drivers returning DECIMAL as text still match; a nil expected value matches SQL NULL
+// (e.g. SUM over no rows). The query must return exactly one row with one column.
+func (c *DBClient) ExpectAggregate(query string, expected interface{}, args ...interface{}) {
+	RecordAction("DB ExpectAggregate", func() { c.ExpectAggregate(query, expected, args...) })
+	if IsDryRun() {
+		return
+	}
+	if c.DB == nil {
+		Fail("DBClient is not connected")
+	}
+	Log(LogTypeDB, "Check Aggregate", fmt.Sprintf("Query: %s\nArgs: %v", query, args))
+	rows, err := c.queryDB(query, args...)
+	if err != nil {
+		Fail("ExpectAggregate query failed: %v\nQuery: %s", err, query)
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		Fail("ExpectAggregate failed to read columns: %v", err)
+	}
+	if len(cols) != 1 {
+		Fail("ExpectAggregate failed: query returned %d columns, expected 1\nQuery: %s", len(cols), query)
+	}
+	var values []interface{}
+	for rows.Next() {
+		var v interface{}
+		if err := rows.Scan(&v); err != nil {
+			Fail("ExpectAggregate failed to scan value: %v", err)
+		}
+		if b, ok := v.([]byte); ok {
+			v = string(b)
+		}
+		values = append(values, v)
+	}
+	if err := rows.Err(); err != nil {
+		Fail("ExpectAggregate query failed: %v\nQuery: %s", err, query)
+	}
+	if len(values) != 1 {
+		Fail("ExpectAggregate failed: query returned %d rows, expected 1\nQuery: %s", len(values), query)
+	}
+	actual := values[0]
+
+	if !aggregateEqual(actual, expected) {
+		Fail("ExpectAggregate failed: expected %v, got %v\nQuery: %s", expected, actual, query)
+	}
+	Logf(LogTypeExpect, "Aggregate %v == %v - PASSED", actual, expected)
+}
+
+// aggregateEqual compares an aggregate result with the expected value, with the numeric
+// tolerance of rowsEqual; numeric strings count as numbers.
+func aggregateEqual(actual, expected interface{}) bool {
+	if actual == nil || expected == nil {
+		return actual == nil && expected == nil
+	}
+	fa, okA := aggregateNumber(actual)
+	fe, okE := aggregateNumber(expected)
+	if okA && okE {
+		scale := math.Max(1, math.Max(math.Abs(fa), math.Abs(fe)))
+		return math.Abs(fa-fe) <= queryResultsTolerance*scale
+	}
+	return fmt.Sprintf("%v", actual) == fmt.Sprintf("%v", expected)
+}
+
+func aggregateNumber(v interface{}) (float64, bool) {
+	if isNumber(v) {
+		return toFloat64(v), true
+	}
+	if s, ok := v.(string); ok {
+		f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// rowsEqual compares two rows column by column, with numeric tolerance.
+func rowsEqual(a, b map[string]interface{}) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for col, va := range a {
+		vb, ok := b[col]
+		if !ok {
+			return false
+		}
+		if isNumber(va) && isNumber(vb) {
+			fa, fb := toFloat64(va), toFloat64(vb)
+			scale := math.Max(1, math.Max(math.Abs(fa), math.Abs(fb)))
+			if math.Abs(fa-fb) > queryResultsTolerance*scale {
+				return false
+			}
+			continue
+		}
+		if !valuesEqual(va, vb) {
+			return false
+		}
+	}
+	return true
+}
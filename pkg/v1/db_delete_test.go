@@ -38,3 +38,35 @@ func TestDBDeleteHelpers(t *testing.T) {
 	res3 := db.Fetch("SELECT COUNT(*) as cnt FROM items", sql.Named("unused", ""))
 	res3.GetRow(0).Expect("cnt", int64(0))
 }
+
+func TestDBDeleteWithLimitOrdered(t *testing.T) {
+	db := Connect("sqlite3", ":memory:")
+	defer db.DB.Close()
+
+	db.SetupTable("events", true, []Field{
+		{Name: "id", Type: "INTEGER PRIMARY KEY"},
+		{Name: "kind", Type: "TEXT"},
+		{Name: "created_at", Type: "INTEGER"},
+	}, nil)
+
+	// Insert out of creation order so rowid order != created_at order
+	db.ReplaceData("events", []interface{}{1, "audit", 300})
+	db.ReplaceData("events", []interface{}{2, "audit", 100})
+	db.ReplaceData("events", []interface{}{3, "audit", 200})
+	db.ReplaceData("events", []interface{}{4, "other", 50})
+
+	// Delete the oldest audit event
+	db.DeleteWithLimitOrdered("events", "kind = ?", "created_at ASC", 1, "audit")
+	db.Fetch("SELECT COUNT(*) AS cnt FROM events WHERE id = ?", 2).GetRow(0).Expect("cnt", int64(0))
+	db.Fetch("SELECT COUNT(*) AS cnt FROM events").GetRow(0).Expect("cnt", int64(3))
+
+	// Delete the newest audit event
+	db.DeleteWithLimitOrdered("events", "kind = ?", "created_at DESC", 1, "audit")
+	db.Fetch("SELECT COUNT(*) AS cnt FROM events WHERE id = ?", 1).GetRow(0).Expect("cnt", int64(0))
+
+	// Only the middle audit event and the non-matching row remain
+	res := db.Fetch("SELECT id FROM events ORDER BY id")
+	res.ExpectCount(2)
+	res.GetRow(0).Expect("id", int64(3))
+	res.GetRow(1).Expect("id", int64(4))
+}
@@ -0,0 +1,93 @@
+package v1
+
+import (
+	"sync"
+	"time"
+)
+
+// DBEvent operations.
+const (
+	DBOpExec         = "Exec"
+	DBOpQuery        = "Query"
+	DBOpPreparedExec = "PreparedExec"
+	DBOpTxExec       = "TxExec"
+	DBOpBegin        = "Begin"
+	DBOpCommit       = "Commit"
+	DBOpRollback     = "Rollback"
+	DBOpPrepare      = "Prepare"
+)
+
+// DBEvent phases: every operation reports DBPhaseBefore when it starts and DBPhaseAfter
+// when it returns.
+const (
+	DBPhaseBefore = "before"
+	DBPhaseAfter  = "after"
+)
+
+// DBEvent describes one database operation run by the DB helpers, for RegisterDBHook.
+type DBEvent struct {
+	Op    string
+	Phase string
+	// Query is the statement as sent to the driver (after Oracle placeholder rewriting). It
+	// is empty for Begin, Commit and Rollback.
+	Query string
+	Args  []interface{}
+	// Duration and Err are set for DBPhaseAfter events. For queries, Err covers sending
+	// the query, not reading its rows.
+	Duration time.Duration
+	Err      error
+}
+
+// DBHook receives DBEvents, e.g. to collect custom metrics or logs.
+type DBHook func(event DBEvent)
+
+// dbHookEntry gives each registration an identity so it can be unregistered.
+type dbHookEntry struct {
+	hook DBHook
+}
+
+var (
+	dbHooks   []*dbHookEntry
+	dbHooksMu sync.Mutex
+)
+
+// RegisterDBHook adds a hook called before and after every statement and query run
+// through a DBClient, its transactions (including Begin, Commit, Rollback and savepoints)
+// and prepared inserts (including Prepare). Hooks run synchronously on the calling
+// goroutine. Call the returned function to unregister the hook.
+func RegisterDBHook(h DBHook) func() {
+	entry := &dbHookEntry{hook: h}
+	dbHooksMu.Lock()
+	defer dbHooksMu.Unlock()
+	dbHooks = append(dbHooks, entry)
+	return func() {
+		dbHooksMu.Lock()
+		defer dbHooksMu.Unlock()
+		for i, e := range dbHooks {
+			if e == entry {
+				dbHooks = append(dbHooks[:i], dbHooks[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+func emitDBEvent(ev DBEvent) {
+	dbHooksMu.Lock()
+	hooks := append([]*dbHookEntry(nil), dbHooks...)
+	dbHooksMu.Unlock()
+	for _, e := range hooks {
+		e.hook(ev)
+	}
+}
+
+// observeDB runs one database operation between its before and after events and
+// returns how long it took.
+func observeDB(op, query string, args []interface{}, run func() error) (time.Duration, error) {
+	emitDBEvent(DBEvent{Op: op, Phase: DBPhaseBefore, Query: query, Args: args})
+	start := clockNow()
+	err := run()
+	elapsed := clockNow().Sub(start)
+	emitDBEvent(DBEvent{Op: op, Phase: DBPhaseAfter, Query: query, Args: args, Duration: elapsed, Err: err})
+	return elapsed, err
+}
@@ -0,0 +1,79 @@
+package v1
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestRegisterDBHook(t *testing.T) {
+	db := Connect("sqlite3", ":memory:")
+	defer db.DB.Close()
+
+	var events []DBEvent
+	unregister := RegisterDBHook(func(ev DBEvent) {
+		events = append(events, ev)
+	})
+	defer unregister()
+
+	db.SetupTable("hooked_items", true, []Field{{Name: "id", Type: "INTEGER"}}, nil)
+	db.InsertOne("hooked_items", []InsertField{{Key: "id", Value: 7}})
+
+	SetClock(&steppingClock{step: 300 * time.Millisecond})
+	events = nil
+	db.Fetch("SELECT id FROM hooked_items WHERE id = ?", 7)
+	SetClock(nil)
+
+	if len(events) != 2 {
+		t.Fatalf("Expected a before and an after event for the fetch, got %+v", events)
+	}
+	before, after := events[0], events[1]
+	const query = "SELECT id FROM hooked_items WHERE id = ?"
+	if before.Op != DBOpQuery || before.Phase != DBPhaseBefore || before.Query != query || before.Duration != 0 {
+		t.Errorf("Unexpected before event: %+v", before)
+	}
+	if after.Op != DBOpQuery || after.Phase != DBPhaseAfter || after.Query != query || after.Err != nil {
+		t.Errorf("Unexpected after event: %+v", after)
+	}
+	if len(after.Args) != 1 || after.Args[0] != 7 {
+		t.Errorf("Expected args [7], got %v", after.Args)
+	}
+	if after.Duration != 300*time.Millisecond {
+		t.Errorf("Expected duration 300ms, got %s", after.Duration)
+	}
+
+	events = nil
+	ExpectFailure(func() { db.Fetch("SELECT missing FROM hooked_items") })
+	if len(events) != 2 || events[1].Err == nil {
+		t.Errorf("Expected the after event to carry the query error, got %+v", events)
+	}
+
+	events = nil
+	tx := db.BeginTx()
+	tx.Savepoint("sp")
+	tx.Exec("INSERT INTO hooked_items (id) VALUES (?)", 8)
+	tx.Commit()
+	ins := db.PreparedInsert("hooked_items", []string{"id"})
+	ins.Exec(9)
+	ins.Close()
+	var ops []string
+	for _, ev := range events {
+		if ev.Phase == DBPhaseAfter {
+			ops = append(ops, ev.Op)
+		}
+	}
+	want := []string{DBOpBegin, DBOpTxExec, DBOpTxExec, DBOpCommit, DBOpPrepare, DBOpPreparedExec}
+	if strings.Join(ops, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected %v, got %v", want, ops)
+	}
+
+	// After unregistering, the hook sees nothing
+	unregister()
+	events = nil
+	db.Fetch("SELECT id FROM hooked_items")
+	if len(events) != 0 {
+		t.Errorf("Expected no events after unregistering, got %+v", events)
+	}
+}
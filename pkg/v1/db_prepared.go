@@ -0,0 +1,93 @@
+package v1
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// PreparedInsertStmt is a reusable INSERT for one table and column list, created with
+// PreparedInsert. It prepares the statement once, so seeding many rows avoids re-preparing
+// it per row as InsertOne does. Close it when done.
+type PreparedInsertStmt struct {
+	Stmt    *sql.Stmt
+	Table   string
+	Columns []string
+	query   string
+	client  *DBClient
+	rows    int
+}
+
+// PreparedInsert prepares "INSERT INTO table (columns...) VALUES (...)" for repeated Exec calls.
+// Individual Exec calls are neither recorded as actions nor logged; Close logs the row count.
+func (c *DBClient) PreparedInsert(tableName string, columns []string) *PreparedInsertStmt {
+	RecordAction(fmt.Sprintf("DB PreparedInsert: %s", tableName), func() { c.PreparedInsert(tableName, columns) })
+	if IsDryRun() {
+		return &PreparedInsertStmt{Table: tableName, Columns: columns}
+	}
+	table := c.Table(tableName)
+	if c.DB == nil {
+		Fail("DBClient is not connected")
+	}
+	if len(columns) == 0 {
+		Fail("PreparedInsert requires at least one column")
+	}
+
+	placeholders := make([]string, len(columns))
+	for i, col := range columns {
+		if strings.TrimSpace(col) == "" {
+			Fail("PreparedInsert expects non-empty column names (got %q)", col)
+		}
+		placeholders[i] = "?"
+		if c.DriverName == "oracle" {
+			placeholders[i] = fmt.Sprintf(":%d", i+1)
+		}
+	}
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", table, strings.Join(columns, ", "), strings.Join(placeholders, ", "))
+	var stmt *sql.Stmt
+	_, err := observeDB(DBOpPrepare, query, nil, func() error {
+		var err error
+		stmt, err = c.DB.Prepare(query)
+		return err
+	})
+	if err != nil {
+		Fail("Failed to prepare insert into %s: %v", table, err)
+	}
+	Log(LogTypeDB, fmt.Sprintf("Prepared insert into '%s'", table), fmt.Sprintf("Query: %s", query))
+	return &PreparedInsertStmt{Stmt: stmt, Table: table, Columns: columns, query: query, client: c}
+}
+
+// Exec inserts one row; values must match the prepared columns in number and order.
+func (p *PreparedInsertStmt) Exec(values ...interface{}) {
+	if IsDryRun() {
+		return
+	}
+	if p.Stmt == nil {
+		Fail("Prepared insert into %s is not prepared or already closed", p.Table)
+	}
+	if len(values) != len(p.Columns) {
+		Fail("Prepared insert into %s expects %d value(s) (%s), got %d", p.Table, len(p.Columns), strings.Join(p.Columns, ", "), len(values))
+	}
+	elapsed, err := observeDB(DBOpPreparedExec, p.query, values, func() error {
+		_, err := p.Stmt.Exec(values...)
+		return err
+	})
+	p.client.checkSlowQuery(p.query, elapsed)
+	if err != nil {
+		Fail("Failed to insert into %s: %v\nArgs: %v", p.Table, err, values)
+	}
+	p.rows++
+}
+
+// Close releases the prepared statement.
+func (p *PreparedInsertStmt) Close() {
+	RecordAction(fmt.Sprintf("DB PreparedInsert Close: %s", p.Table), func() { p.Close() })
+	if IsDryRun() || p.Stmt == nil {
+		return
+	}
+	if err := p.Stmt.Close(); err != nil {
+		Fail("Failed to close prepared insert into %s: %v", p.Table, err)
+	}
+	p.Stmt = nil
+	Log(LogTypeDB, fmt.Sprintf("Prepared insert into '%s' closed", p.Table), fmt.Sprintf("Inserted %d row(s)", p.rows))
+}
@@ -0,0 +1,47 @@
+package v1
+
+import (
+	"fmt"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestPreparedInsert(t *testing.T) {
+	db := Connect("sqlite3", ":memory:")
+	defer db.DB.Close()
+
+	db.SetupTable("seeded", true, []Field{
+		{Name: "id", Type: "INTEGER PRIMARY KEY"},
+		{Name: "name", Type: "TEXT"},
+		{Name: "score", Type: "INTEGER"},
+	}, nil)
+
+	const n = 2000
+	ins := db.PreparedInsert("seeded", []string{"id", "name", "score"})
+	for i := 1; i <= n; i++ {
+		ins.Exec(i, fmt.Sprintf("user%d", i), i*10)
+	}
+	ExpectFailure(func() { ins.Exec(n + 1) })
+	ExpectFailure(func() { ins.Exec(1, "duplicate id", 0) })
+	ins.Close()
+	ExpectFailure(func() { ins.Exec(n+2, "after close", 0) })
+
+	db.Fetch("SELECT COUNT(*) AS c FROM seeded").GetRow(0).Expect("c", n)
+	row := db.Fetch("SELECT name, score FROM seeded WHERE id = ?", 1234).GetRow(0)
+	row.Expect("name", "user1234")
+	row.Expect("score", 12340)
+}
+
+func BenchmarkPreparedInsert(b *testing.B) {
+	db := Connect("sqlite3", ":memory:")
+	defer db.DB.Close()
+	db.SetupTable("bench", true, []Field{{Name: "id", Type: "INTEGER"}, {Name: "name", Type: "TEXT"}}, nil)
+
+	ins := db.PreparedInsert("bench", []string{"id", "name"})
+	defer ins.Close()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ins.Exec(i, "name")
+	}
+}
@@ -0,0 +1,293 @@
+package v1
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// columnTypeAliases maps type names to one canonical spelling, so a declared INT matches an
+// introspected INTEGER, VARCHAR2 matches VARCHAR, and so on.
+var columnTypeAliases = map[string]string{
+	"INT":                         "INTEGER",
+	"INT4":                        "INTEGER",
+	"MEDIUMINT":                   "INTEGER",
+	"SERIAL":                      "INTEGER",
+	"INT2":                        "SMALLINT",
+	"INT8":                        "BIGINT",
+	"BIGSERIAL":                   "BIGINT",
+	"CHARACTER VARYING":           "VARCHAR",
+	"VARCHAR2":                    "VARCHAR",
+	"NVARCHAR":                    "VARCHAR",
+	"NVARCHAR2":                   "VARCHAR",
+	"CHARACTER":                   "CHAR",
+	"NCHAR":                       "CHAR",
+	"BPCHAR":                      "CHAR",
+	"BOOL":                        "BOOLEAN",
+	"DOUBLE PRECISION":            "DOUBLE",
+	"FLOAT8":                      "DOUBLE",
+	"FLOAT4":                      "REAL",
+	"DECIMAL":                     "NUMERIC",
+	"NUMBER":                      "NUMERIC",
+	"TIMESTAMP WITHOUT TIME ZONE": "TIMESTAMP",
+	"TIMESTAMP WITH TIME ZONE":    "TIMESTAMPTZ",
+}
+
+// columnTypeConstraints start the constraint part of a Field.Type such as "INTEGER PRIMARY KEY".
+var columnTypeConstraints = []string{
+	" PRIMARY KEY", " NOT NULL", " NULL", " UNIQUE", " DEFAULT", " REFERENCES", " CHECK",
+	" AUTOINCREMENT", " COLLATE", " GENERATED", " CONSTRAINT",
+}
+
+// normalizeColumnType reduces a declared or introspected column type to a comparable name:
+// upper case, without constraints or size/precision, with aliases resolved for the driver.
+func normalizeColumnType(driverName, columnType string) string {
+	t := " " + strings.ToUpper(strings.TrimSpace(columnType))
+	for _, kw := range columnTypeConstraints {
+		if i := strings.Index(t, kw); i >= 0 {
+			t = t[:i]
+		}
+	}
+	if i := strings.Index(t, "("); i >= 0 {
+		t = t[:i]
+	}
+	t = strings.Join(strings.Fields(t), " ")
+	if alias, ok := columnTypeAliases[t]; ok {
+		t = alias
+	}
+	switch driverName {
+	case "oracle":
+		// Oracle stores integer types as NUMBER
+		if t == "INTEGER" || t == "SMALLINT" || t == "BIGINT" {
+			t = "NUMERIC"
+		}
+	case "mysql":
+		// MySQL stores BOOLEAN as TINYINT(1)
+		if t == "BOOLEAN" {
+			t = "TINYINT"
+		}
+	}
+	return t
+}
+
+// ExpectSchema asserts that the live table has exactly the expected columns, by name and
+// type, and the expected secondary indexes, by column list. Types compare by name only
+// (VARCHAR(50) matches VARCHAR(100)); constraints in Field.Type are ignored and aliases such
+// as INT/INTEGER or VARCHAR2/VARCHAR are treated as equal. Indexes backing primary key or
+// unique constraints are not compared, while CREATE UNIQUE INDEX indexes are. MySQL stores
+// both kinds of unique index alike, so there every unique index is compared. It fails
+// listing every difference.
+func (c *DBClient) ExpectSchema(tableName string, expected []Field, indexes []Index) {
+	RecordAction(fmt.Sprintf("DB ExpectSchema: %s", tableName), func() { c.ExpectSchema(tableName, expected, indexes) })
+	if IsDryRun() {
+		return
+	}
+	table := c.Table(tableName)
+	if c.DB == nil {
+		Fail("DBClient is not connected")
+	}
+
+	columns := c.liveColumns(table)
+	if len(columns) == 0 {
+		Fail("ExpectSchema failed: table %s does not exist or has no columns", table)
+	}
+	var diffs []string
+
+	liveTypes := make(map[string]string, len(columns))
+	for _, col := range columns {
+		liveTypes[strings.ToLower(col[0])] = col[1]
+	}
+	expectedNames := make(map[string]bool, len(expected))
+	for _, f := range expected {
+		name := strings.ToLower(f.Name)
+		expectedNames[name] = true
+		liveType, ok := liveTypes[name]
+		if !ok {
+			diffs = append(diffs, fmt.Sprintf("missing column %s (%s)", f.Name, f.Type))
+			continue
+		}
+		want, got := normalizeColumnType(c.DriverName, f.Type), normalizeColumnType(c.DriverName, liveType)
+		if want != got {
+			diffs = append(diffs, fmt.Sprintf("column %s: type %s != %s", f.Name, want, got))
+		}
+	}
+	for _, col := range columns {
+		if !expectedNames[strings.ToLower(col[0])] {
+			diffs = append(diffs, fmt.Sprintf("unexpected column %s (%s)", col[0], col[1]))
+		}
+	}
+
+	liveIndexes := make(map[string]bool)
+	for _, cols := range c.liveIndexes(table) {
+		liveIndexes[indexKey(cols)] = true
+	}
+	expectedIndexes := make(map[string]bool, len(indexes))
+	for _, idx := range indexes {
+		key := indexKey(idx.Columns)
+		expectedIndexes[key] = true
+		if !liveIndexes[key] {
+			diffs = append(diffs, fmt.Sprintf("missing index (%s)", key))
+		}
+	}
+	var unexpected []string
+	for key := range liveIndexes {
+		if !expectedIndexes[key] {
+			unexpected = append(unexpected, fmt.Sprintf("unexpected index (%s)", key))
+		}
+	}
+	sort.Strings(unexpected)
+	diffs = append(diffs, unexpected...)
+
+	if len(diffs) > 0 {
+		Fail("ExpectSchema failed for %s:\n%s", table, strings.Join(diffs, "\n"))
+	}
+	Logf(LogTypeExpect, "Schema of %s matches (%d columns, %d indexes) - PASSED", table, len(expected), len(indexes))
+}
+
+func indexKey(columns []string) string {
+	lower := make([]string, len(columns))
+	for i, col := range columns {
+		lower[i] = strings.ToLower(strings.TrimSpace(col))
+	}
+	return strings.Join(lower, ", ")
+}
+
+// catalogTableName returns how table is stored in the catalog and the SQL expression that
+// matches it against the bound name: unquoted names are folded like the database does,
+// quoted names compare exactly.
+func catalogTableName(table, folded string) (string, string) {
+	if inner, ok := unquoteIdent(table); ok {
+		return inner, "%s"
+	}
+	return table, folded
+}
+
+// liveColumns returns the [name, type] of every column of table, in table order.
+func (c *DBClient) liveColumns(table string) [][2]string {
+	var query string
+	var args []interface{}
+	switch c.DriverName {
+	case "sqlite3":
+		var columns [][2]string
+		for _, row := range c.schemaRows(fmt.Sprintf("PRAGMA table_info(%s)", table)) {
+			columns = append(columns, [2]string{row[1], row[2]})
+		}
+		return columns
+	case "oracle":
+		name, match := catalogTableName(table, "UPPER(%s)")
+		query = "SELECT column_name, data_type FROM user_tab_columns WHERE table_name = " + fmt.Sprintf(match, ":1") + " ORDER BY column_id"
+		args = []interface{}{name}
+	case "postgres", "postgresql":
+		name, match := catalogTableName(table, "LOWER(%s)")
+		query = "SELECT column_name, data_type FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = " + fmt.Sprintf(match, "$1") + " ORDER BY ordinal_position"
+		args = []interface{}{name}
+	default:
+		name, _ := catalogTableName(table, "%s")
+		query = "SELECT column_name, data_type FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = ? ORDER BY ordinal_position"
+		args = []interface{}{name}
+	}
+	var columns [][2]string
+	for _, row := range c.schemaRows(query, args...) {
+		columns = append(columns, [2]string{row[0], row[1]})
+	}
+	return columns
+}
+
+// liveIndexes returns the column lists of the secondary indexes of table, unique ones
+// included, leaving out the indexes that back primary key and unique constraints (and, on
+// MySQL, the ones it creates for foreign keys).
+func (c *DBClient) liveIndexes(table string) [][]string {
+	var query string
+	name, match := table, "%s"
+	switch c.DriverName {
+	case "sqlite3":
+		var indexes [][]string
+		for _, row := range c.schemaRows(fmt.Sprintf("PRAGMA index_list(%s)", table)) {
+			// origin "c" is CREATE INDEX; "pk" and "u" back constraints
+			if row[3] != "c" {
+				continue
+			}
+			var cols []string
+			for _, info := range c.schemaRows(fmt.Sprintf(`PRAGMA index_info("%s")`, strings.ReplaceAll(row[1], `"`, `""`))) {
+				cols = append(cols, info[2])
+			}
+			indexes = append(indexes, cols)
+		}
+		return indexes
+	case "oracle":
+		name, match = catalogTableName(table, "UPPER(%s)")
+		query = `SELECT ic.index_name, ic.column_name FROM user_ind_columns ic
+			WHERE ic.table_name = ` + fmt.Sprintf(match, ":1") + `
+			AND NOT EXISTS (SELECT 1 FROM user_constraints uc WHERE uc.index_name = ic.index_name AND uc.table_name = ic.table_name
+				AND uc.constraint_type IN ('P', 'U'))
+			ORDER BY ic.index_name, ic.column_position`
+	case "postgres", "postgresql":
+		name, match = catalogTableName(table, "LOWER(%s)")
+		query = `SELECT i.relname, a.attname FROM pg_index ix
+			JOIN pg_class t ON t.oid = ix.indrelid
+			JOIN pg_class i ON i.oid = ix.indexrelid
+			JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = ANY(ix.indkey)
+			WHERE t.relname = ` + fmt.Sprintf(match, "$1") + ` AND t.relnamespace = current_schema()::regnamespace
+			AND NOT EXISTS (SELECT 1 FROM pg_constraint con WHERE con.conindid = ix.indexrelid AND con.contype IN ('p', 'u', 'x'))
+			ORDER BY i.relname, array_position(ix.indkey::int2[], a.attnum)`
+	default:
+		// MySQL lists a CREATE UNIQUE INDEX as a UNIQUE constraint too, so only the primary
+		// key and the indexes it adds for foreign keys count as constraint-backed
+		name, _ = catalogTableName(table, "%s")
+		query = `SELECT s.index_name, s.column_name FROM information_schema.statistics s
+			WHERE s.table_schema = DATABASE() AND s.table_name = ? AND s.index_name <> 'PRIMARY'
+			AND NOT EXISTS (SELECT 1 FROM information_schema.table_constraints tc
+				WHERE tc.table_schema = s.table_schema AND tc.table_name = s.table_name
+				AND tc.constraint_name = s.index_name AND tc.constraint_type = 'FOREIGN KEY')
+			ORDER BY s.index_name, s.seq_in_index`
+	}
+	var indexes [][]string
+	last := ""
+	for _, row := range c.schemaRows(query, name) {
+		if len(indexes) == 0 || row[0] != last {
+			indexes = append(indexes, nil)
+			last = row[0]
+		}
+		indexes[len(indexes)-1] = append(indexes[len(indexes)-1], row[1])
+	}
+	return indexes
+}
+
+// schemaRows runs an introspection query and returns every row with its values as strings.
+func (c *DBClient) schemaRows(query string, args ...interface{}) [][]string {
+	rows, err := c.queryDB(query, args...)
+	if err != nil {
+		Fail("Failed to read schema: %v\nQuery: %s", err, query)
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		Fail("Failed to read schema: %v", err)
+	}
+	var out [][]string
+	for rows.Next() {
+		values := make([]interface{}, len(cols))
+		ptrs := make([]interface{}, len(cols))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			Fail("Failed to read schema: %v", err)
+		}
+		row := make([]string, len(cols))
+		for i, v := range values {
+			switch v := v.(type) {
+			case nil:
+			case []byte:
+				row[i] = string(v)
+			default:
+				row[i] = fmt.Sprintf("%v", v)
+			}
+		}
+		out = append(out, row)
+	}
+	if err := rows.Err(); err != nil {
+		Fail("Failed to read schema: %v", err)
+	}
+	return out
+}
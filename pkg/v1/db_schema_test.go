@@ -0,0 +1,92 @@
+package v1
+
+import (
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestExpectSchema(t *testing.T) {
+	db := Connect("sqlite3", ":memory:")
+	defer db.DB.Close()
+
+	fields := []Field{
+		{Name: "id", Type: "INTEGER PRIMARY KEY"},
+		{Name: "email", Type: "VARCHAR(120) NOT NULL UNIQUE"},
+		{Name: "score", Type: "INT"},
+		{Name: "created_at", Type: "TIMESTAMP", Default: DefaultNow},
+	}
+	indexes := []Index{{Columns: []string{"score"}}, {Columns: []string{"email", "score"}}}
+	db.SetupTable("schema_users", true, fields, indexes)
+
+	// Declared spellings and the introspected ones normalize to the same types
+	db.ExpectSchema("schema_users", []Field{
+		{Name: "ID", Type: "INTEGER"},
+		{Name: "email", Type: "varchar"},
+		{Name: "score", Type: "INTEGER"},
+		{Name: "created_at", Type: "TIMESTAMP"},
+	}, []Index{{Columns: []string{"email", "score"}}, {Columns: []string{"SCORE"}}})
+	db.ExpectSchema("schema_users", fields, indexes)
+
+	// Drift: an extra column, an extra index and a retyped column
+	db.TryExec("ALTER TABLE schema_users ADD COLUMN nickname TEXT")
+	db.TryExec("CREATE INDEX idx_schema_users_nick ON schema_users (nickname)")
+	db.TryExec("CREATE UNIQUE INDEX idx_schema_users_created ON schema_users (created_at)")
+	drifted := []Field{fields[0], fields[1], {Name: "score", Type: "TEXT"}, fields[3], {Name: "deleted_at", Type: "TIMESTAMP"}}
+
+	msg := schemaFailure(t, func() { db.ExpectSchema("schema_users", drifted, []Index{{Columns: []string{"score", "email"}}}) })
+	for _, want := range []string{
+		"column score: type TEXT != INTEGER",
+		"missing column deleted_at (TIMESTAMP)",
+		"unexpected column nickname (TEXT)",
+		"missing index (score, email)",
+		"unexpected index (email, score)",
+		"unexpected index (created_at)",
+		"unexpected index (nickname)",
+		"unexpected index (score)",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("Expected failure to mention %q, got:\n%s", want, msg)
+		}
+	}
+
+	// A CREATE UNIQUE INDEX counts as an index; the inline UNIQUE on email does not
+	db.ExpectSchema("schema_users", append(fields, Field{Name: "nickname", Type: "TEXT"}),
+		append(indexes, Index{Columns: []string{"nickname"}}, Index{Columns: []string{"created_at"}}))
+
+	if msg := schemaFailure(t, func() { db.ExpectSchema("schema_missing", fields, nil) }); !strings.Contains(msg, "does not exist") {
+		t.Errorf("Expected a missing-table failure, got %q", msg)
+	}
+}
+
+func schemaFailure(t *testing.T, f func()) (msg string) {
+	t.Helper()
+	defer func() {
+		r := recover()
+		te, ok := r.(TestError)
+		if !ok {
+			t.Fatalf("Expected a TestError, got %v", r)
+		}
+		msg = te.Message
+	}()
+	f()
+	return ""
+}
+
+func TestNormalizeColumnType(t *testing.T) {
+	cases := []struct{ driver, declared, want string }{
+		{"sqlite3", "integer primary key autoincrement", "INTEGER"},
+		{"sqlite3", "DECIMAL(10, 2) NOT NULL", "NUMERIC"},
+		{"postgres", "character varying", "VARCHAR"},
+		{"postgres", "timestamp without time zone", "TIMESTAMP"},
+		{"oracle", "VARCHAR2(50)", "VARCHAR"},
+		{"oracle", "INTEGER", "NUMERIC"},
+		{"mysql", "BOOLEAN", "TINYINT"},
+	}
+	for _, c := range cases {
+		if got := normalizeColumnType(c.driver, c.declared); got != c.want {
+			t.Errorf("normalizeColumnType(%s, %q) = %q, want %q", c.driver, c.declared, got, c.want)
+		}
+	}
+}
@@ -1,7 +1,11 @@
 package v1
 
 import (
+	"database/sql"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -12,9 +16,9 @@ func TestDBClient(t *testing.T) {
 
 	// Setup Table
 	fields := []Field{
-		{"id", "INTEGER PRIMARY KEY AUTOINCREMENT"},
-		{"name", "TEXT"},
-		{"age", "INTEGER"},
+		{Name: "id", Type: "INTEGER PRIMARY KEY AUTOINCREMENT"},
+		{Name: "name", Type: "TEXT"},
+		{Name: "age", Type: "INTEGER"},
 	}
 	db.SetupTable("users", true, fields, nil)
 
@@ -81,9 +85,9 @@ func TestRowResultExpectCond(t *testing.T) {
 	db := Connect("sqlite3", ":memory:")
 
 	fields := []Field{
-		{"id", "INTEGER PRIMARY KEY AUTOINCREMENT"},
-		{"name", "TEXT"},
-		{"age", "INTEGER"},
+		{Name: "id", Type: "INTEGER PRIMARY KEY AUTOINCREMENT"},
+		{Name: "name", Type: "TEXT"},
+		{Name: "age", Type: "INTEGER"},
 	}
 	db.SetupTable("users", true, fields, nil)
 
@@ -129,9 +133,9 @@ func TestInsertOne(t *testing.T) {
 	db := Connect("sqlite3", ":memory:")
 
 	fields := []Field{
-		{"id", "INTEGER PRIMARY KEY AUTOINCREMENT"},
-		{"name", "TEXT"},
-		{"age", "INTEGER"},
+		{Name: "id", Type: "INTEGER PRIMARY KEY AUTOINCREMENT"},
+		{Name: "name", Type: "TEXT"},
+		{Name: "age", Type: "INTEGER"},
 	}
 	db.SetupTable("users", true, fields, nil)
 
@@ -163,3 +167,604 @@ func TestInsertOne(t *testing.T) {
 	assertPanic("no fields", func() { db.InsertOne("users", []InsertField{}) })
 	assertPanic("bad field name", func() { db.InsertOne("users", []InsertField{{Key: "", Value: "Bob"}}) })
 }
+
+func TestTryInsertOneDuplicateKey(t *testing.T) {
+	db := Connect("sqlite3", ":memory:")
+	defer db.DB.Close()
+	db.SetupTable("users", true, []Field{
+		{Name: "id", Type: "INTEGER PRIMARY KEY"},
+		{Name: "name", Type: "TEXT"},
+	}, nil)
+
+	if err := db.TryInsertOne("users", []InsertField{{"id", 1}, {"name", "Alice"}}); err != nil {
+		t.Fatalf("First insert failed: %v", err)
+	}
+	err := db.TryInsertOne("users", []InsertField{{"id", 1}, {"name", "Bob"}})
+	ExpectErrorContains(err, "UNIQUE constraint failed")
+
+	err = db.TryExec("INSERT INTO users (id, name) VALUES (?, ?)", 1, "Carol")
+	ExpectErrorContains(err, "users.id")
+	if err := db.TryExec("UPDATE users SET name = ? WHERE id = ?", "Alicia", 1); err != nil {
+		t.Errorf("Expected TryExec to succeed, got %v", err)
+	}
+	db.Fetch("SELECT name FROM users WHERE id = 1").GetRow(0).Expect("name", "Alicia")
+
+	ExpectFailure(func() { ExpectErrorContains(nil, "constraint") })
+	ExpectFailure(func() { ExpectErrorContains(err, "no such text") })
+}
+
+func TestUpsert(t *testing.T) {
+	db := Connect("sqlite3", ":memory:")
+	defer db.DB.Close()
+	db.SetupTable("settings", true, []Field{
+		{Name: "tenant", Type: "TEXT"},
+		{Name: "name", Type: "TEXT"},
+		{Name: "value", Type: "TEXT"},
+	}, nil)
+	// ON CONFLICT needs a unique index on the key columns
+	if err := db.TryExec("CREATE UNIQUE INDEX settings_key ON settings (tenant, name)"); err != nil {
+		t.Fatalf("Failed to create unique index: %v", err)
+	}
+
+	db.Upsert("settings", map[string]interface{}{"tenant": "a", "name": "theme", "value": "light"}, []string{"tenant", "name"})
+	db.Upsert("settings", map[string]interface{}{"tenant": "b", "name": "theme", "value": "light"}, []string{"tenant", "name"})
+	db.Upsert("settings", map[string]interface{}{"tenant": "a", "name": "theme", "value": "dark"}, []string{"tenant", "name"})
+
+	db.Fetch("SELECT * FROM settings").ExpectCount(2)
+	db.Fetch("SELECT value FROM settings WHERE tenant = ? AND name = ?", "a", "theme").GetRow(0).Expect("value", "dark")
+	db.Fetch("SELECT value FROM settings WHERE tenant = ? AND name = ?", "b", "theme").GetRow(0).Expect("value", "light")
+
+	// Only key columns: existing row is left as is
+	db.Upsert("settings", map[string]interface{}{"tenant": "a", "name": "theme"}, []string{"tenant", "name"})
+	db.Fetch("SELECT value FROM settings WHERE tenant = ?", "a").GetRow(0).Expect("value", "dark")
+
+	ExpectFailure(func() {
+		db.Upsert("settings", map[string]interface{}{"name": "theme", "value": "x"}, []string{"tenant", "name"})
+	})
+	ExpectFailure(func() { db.Upsert("settings", map[string]interface{}{"name": "theme"}, nil) })
+}
+
+func TestWhereEq(t *testing.T) {
+	cols := map[string]interface{}{"name": "Alice", "age": 30, "city": "Paris", "deleted_at": nil}
+	for i := 0; i < 5; i++ {
+		clause, args := WhereEq(cols)
+		if clause != "age = ? AND city = ? AND deleted_at IS NULL AND name = ?" {
+			t.Fatalf("Unexpected clause: %s", clause)
+		}
+		if fmt.Sprint(args) != "[30 Paris Alice]" {
+			t.Fatalf("Args don't align with placeholders: %v", args)
+		}
+	}
+	if clause, args := WhereEq(nil); clause != "" || len(args) != 0 {
+		t.Errorf("Expected empty clause for no columns, got %q %v", clause, args)
+	}
+
+	db := Connect("sqlite3", ":memory:")
+	defer db.DB.Close()
+	db.SetupTable("users", true, []Field{{Name: "name", Type: "TEXT"}, {Name: "age", Type: "INTEGER"}, {Name: "deleted_at", Type: "TEXT"}}, nil)
+	db.ReplaceData("users", []interface{}{"Alice", 30, nil})
+	db.ReplaceData("users", []interface{}{"Alice", 31, nil})
+
+	where, args := WhereEq(map[string]interface{}{"name": "Alice", "age": 30, "deleted_at": nil})
+	db.Fetch("SELECT * FROM users WHERE "+where, args...).ExpectCount(1)
+	db.Update("users", map[string]interface{}{"age": 32}, where, args...)
+	db.ExpectExists("users", "age = ?", 32)
+	where, args = WhereEq(map[string]interface{}{"name": "Alice"})
+	db.DeleteWithLimit("users", where, 10, args...)
+	db.Fetch("SELECT * FROM users").ExpectCount(0)
+}
+
+func TestTablePrefix(t *testing.T) {
+	db := Connect("sqlite3", ":memory:")
+	defer db.DB.Close()
+	db.TablePrefix = "run123_"
+
+	db.SetupTable("users", true, []Field{{Name: "id", Type: "INTEGER PRIMARY KEY"}, {Name: "name", Type: "TEXT"}}, []Index{{Columns: []string{"name"}}})
+	db.InsertOne("users", []InsertField{{"id", 1}, {"name", "Alice"}})
+	db.ReplaceData("users", []interface{}{2, "Bob"})
+	db.Update("users", map[string]interface{}{"name": "Alicia"}, "id = ?", 1)
+	db.Upsert("users", map[string]interface{}{"id": 3, "name": "Carol"}, []string{"id"})
+	db.ExpectExists("users", "name = ?", "Alicia")
+	db.DeleteOne("users", "id = ?", 2)
+
+	if db.Table("users") != "run123_users" {
+		t.Errorf("Unexpected prefixed name: %s", db.Table("users"))
+	}
+	result := db.Fetch("SELECT id, name FROM " + db.Table("users") + " ORDER BY id")
+	result.ExpectCount(2)
+	result.GetRow(0).Expect("name", "Alicia")
+	result.GetRow(1).Expect("name", "Carol")
+
+	// Only the prefixed table and index exist
+	db.Fetch("SELECT name FROM sqlite_master WHERE name = 'users'").ExpectCount(0)
+	db.Fetch("SELECT name FROM sqlite_master WHERE name = 'idx_run123_users_0'").ExpectCount(1)
+
+	db.CleanTable("users")
+	db.Fetch("SELECT * FROM " + db.Table("users")).ExpectCount(0)
+	db.DropTable("users")
+	db.Fetch("SELECT name FROM sqlite_master WHERE name = 'run123_users'").ExpectCount(0)
+}
+
+func TestExpectExists(t *testing.T) {
+	db := Connect("sqlite3", ":memory:")
+	defer db.DB.Close()
+
+	db.SetupTable("users", true, []Field{
+		{Name: "id", Type: "INTEGER PRIMARY KEY AUTOINCREMENT"},
+		{Name: "name", Type: "TEXT"},
+		{Name: "age", Type: "INTEGER"},
+	}, nil)
+	db.InsertOne("users", []InsertField{{"name", "Alice"}, {"age", 30}})
+	db.InsertOne("users", []InsertField{{"name", "Bob"}, {"age", 30}})
+	db.InsertOne("users", []InsertField{{"name", "Carol"}, {"age", 40}})
+
+	db.ExpectExists("users", "name = ?", "Alice")
+	// WHERE matching multiple rows still counts as "exists"
+	db.ExpectExists("users", "age = ?", 30)
+	db.ExpectNotExists("users", "name = ?", "Dave")
+	db.ExpectNotExists("users", "age > ?", 100)
+
+	assertPanic := func(name string, f func()) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Errorf("%s expected to panic", name)
+			} else {
+				if _, ok := r.(TestError); !ok {
+					t.Errorf("%s panicked with unexpected type: %T", name, r)
+				}
+			}
+		}()
+		f()
+	}
+
+	assertPanic("ExpectExists no match", func() { db.ExpectExists("users", "name = ?", "Dave") })
+	assertPanic("ExpectNotExists single match", func() { db.ExpectNotExists("users", "name = ?", "Bob") })
+	assertPanic("ExpectNotExists multiple matches", func() { db.ExpectNotExists("users", "age = ?", 30) })
+}
+
+func TestFetchLogsResultTable(t *testing.T) {
+	db := Connect("sqlite3", ":memory:")
+	defer db.DB.Close()
+
+	db.SetupTable("users", true, []Field{
+		{Name: "id", Type: "INTEGER PRIMARY KEY AUTOINCREMENT"},
+		{Name: "name", Type: "TEXT"},
+		{Name: "age", Type: "INTEGER"},
+	}, nil)
+	for i := 0; i < fetchLogMaxRows+5; i++ {
+		db.InsertOne("users", []InsertField{{"name", fmt.Sprintf("user%d", i)}, {"age", 20 + i}})
+	}
+
+	var detail string
+	logHandlers = nil
+	defer func() { logHandlers = nil }()
+	RegisterLogHandler(func(e LogEntry) {
+		if e.Type == LogTypeDB && strings.HasPrefix(e.Summary, "Fetched") {
+			detail = e.Detail
+		}
+	})
+
+	result := db.Fetch("SELECT name, age FROM users ORDER BY id")
+	if len(result.Columns) != 2 || result.Columns[0] != "name" || result.Columns[1] != "age" {
+		t.Fatalf("Expected ordered columns [name age], got %v", result.Columns)
+	}
+
+	lines := strings.Split(detail, "\n")
+	if !strings.HasPrefix(lines[0], "name") || !strings.Contains(lines[0], "age") {
+		t.Errorf("Expected header line with column names, got %q", lines[0])
+	}
+	if !strings.Contains(detail, "user0") || !strings.Contains(detail, "20") {
+		t.Errorf("Expected sample values in log detail, got:\n%s", detail)
+	}
+	if strings.Contains(detail, fmt.Sprintf("user%d", fetchLogMaxRows)) {
+		t.Errorf("Expected rows beyond the cap to be omitted, got:\n%s", detail)
+	}
+	if !strings.Contains(detail, "... 5 more row(s)") {
+		t.Errorf("Expected truncation note, got:\n%s", detail)
+	}
+}
+
+func TestFetchNamed(t *testing.T) {
+	db := Connect("sqlite3", ":memory:")
+	defer db.DB.Close()
+
+	db.SetupTable("users", true, []Field{
+		{Name: "id", Type: "INTEGER PRIMARY KEY AUTOINCREMENT"},
+		{Name: "name", Type: "TEXT"},
+		{Name: "age", Type: "INTEGER"},
+	}, nil)
+	db.InsertOne("users", []InsertField{{"name", "Alice"}, {"age", 30}})
+	db.InsertOne("users", []InsertField{{"name", "Bob"}, {"age", 25}})
+	db.InsertOne("users", []InsertField{{"name", "Carol"}, {"age", 40}})
+
+	result := db.FetchNamed("SELECT name FROM users WHERE age >= :min AND age <= :max ORDER BY age", map[string]interface{}{
+		"max": 35,
+		"min": 20,
+	})
+	result.ExpectCount(2)
+	result.GetRow(0).Expect("name", "Bob")
+	result.GetRow(1).Expect("name", "Alice")
+
+	// Repeated names and quoted colons
+	result = db.FetchNamed("SELECT name, 'a:b' AS tag FROM users WHERE name = :name OR (age > :age AND name <> :name)", map[string]interface{}{
+		"name": "Alice",
+		"age":  35,
+	})
+	result.ExpectCount(2)
+	result.GetRow(0).Expect("tag", "a:b")
+
+	// Missing param fails
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("Expected missing parameter to panic")
+			} else if _, ok := r.(TestError); !ok {
+				t.Errorf("Unexpected panic type: %T", r)
+			}
+		}()
+		db.FetchNamed("SELECT * FROM users WHERE name = :name", map[string]interface{}{})
+	}()
+}
+
+func TestBindNamedParams(t *testing.T) {
+	query, args := bindNamedParams("postgres", "SELECT id::text FROM t WHERE a = :a AND b = :b AND c = :a", map[string]interface{}{"a": 1, "b": 2})
+	if query != "SELECT id::text FROM t WHERE a = $1 AND b = $2 AND c = $3" {
+		t.Errorf("Unexpected postgres query: %s", query)
+	}
+	if len(args) != 3 || args[0] != 1 || args[1] != 2 || args[2] != 1 {
+		t.Errorf("Unexpected args: %v", args)
+	}
+
+	query, _ = bindNamedParams("oracle", "SELECT * FROM t WHERE a = :a", map[string]interface{}{"a": 1})
+	if query != "SELECT * FROM t WHERE a = ?" {
+		t.Errorf("Unexpected oracle query: %s", query)
+	}
+}
+
+func TestExpectQueryResultsEqual(t *testing.T) {
+	src := Connect("sqlite3", ":memory:")
+	defer src.DB.Close()
+	dst := Connect("sqlite3", ":memory:")
+	defer dst.DB.Close()
+
+	src.SetupTable("orders", true, []Field{{Name: "id", Type: "INTEGER"}, {Name: "amount", Type: "INTEGER"}}, nil)
+	dst.SetupTable("orders_v2", true, []Field{{Name: "id", Type: "INTEGER"}, {Name: "amount", Type: "REAL"}}, nil)
+
+	src.ReplaceData("orders", []interface{}{1, 100})
+	src.ReplaceData("orders", []interface{}{2, 250})
+	// Inserted in a different order and with REAL amounts
+	dst.ReplaceData("orders_v2", []interface{}{2, 250.0})
+	dst.ReplaceData("orders_v2", []interface{}{1, 100.0})
+
+	ExpectQueryResultsEqual(src, "SELECT id, amount FROM orders", dst, "SELECT id, amount FROM orders_v2")
+	ExpectQueryResultsEqual(src, "SELECT id, amount FROM orders WHERE id = ?", dst, "SELECT id, amount FROM orders_v2 WHERE id = ?", 2)
+
+	assertPanic := func(name string, f func()) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Errorf("%s expected to panic", name)
+			} else if _, ok := r.(TestError); !ok {
+				t.Errorf("%s panicked with unexpected type: %T", name, r)
+			}
+		}()
+		f()
+	}
+
+	dst.Update("orders_v2", map[string]interface{}{"amount": 99.5}, "id = ?", 1)
+	assertPanic("different value", func() {
+		ExpectQueryResultsEqual(src, "SELECT id, amount FROM orders", dst, "SELECT id, amount FROM orders_v2")
+	})
+
+	dst.ReplaceData("orders_v2", []interface{}{3, 10.0})
+	assertPanic("different row count", func() {
+		ExpectQueryResultsEqual(src, "SELECT id FROM orders", dst, "SELECT id FROM orders_v2")
+	})
+}
+
+func TestExpectRows(t *testing.T) {
+	db := Connect("sqlite3", ":memory:")
+	defer db.DB.Close()
+
+	db.SetupTable("fixtures", true, []Field{{Name: "id", Type: "INTEGER"}, {Name: "name", Type: "TEXT"}, {Name: "score", Type: "REAL"}}, nil)
+	db.ReplaceData("fixtures", []interface{}{1, "alice", 9.5})
+	db.ReplaceData("fixtures", []interface{}{2, "bob", 7.0})
+
+	res := db.Fetch("SELECT id, name, score FROM fixtures")
+	res.ExpectRows([]map[string]interface{}{
+		{"id": 2, "name": "bob", "score": 7},
+		{"ID": 1, "Name": "alice", "score": 9.5},
+	})
+
+	failMessage := func(expected []map[string]interface{}) string {
+		var msg string
+		func() {
+			defer func() {
+				if te, ok := recover().(TestError); ok {
+					msg = te.Message
+				}
+			}()
+			res.ExpectRows(expected)
+		}()
+		return msg
+	}
+
+	msg := failMessage([]map[string]interface{}{
+		{"id": 1, "name": "alice", "score": 9.5},
+		{"id": 2, "name": "bob", "score": 7},
+		{"id": 3, "name": "carol", "score": 5},
+	})
+	if !strings.Contains(msg, "missing") || !strings.Contains(msg, "carol") || strings.Contains(msg, "extra") {
+		t.Errorf("Expected a missing row for carol, got %q", msg)
+	}
+
+	msg = failMessage([]map[string]interface{}{
+		{"id": 1, "name": "alice", "score": 9.5},
+	})
+	if !strings.Contains(msg, "extra") || !strings.Contains(msg, "bob") || strings.Contains(msg, "missing") {
+		t.Errorf("Expected an extra row for bob, got %q", msg)
+	}
+}
+
+func TestExpectNull(t *testing.T) {
+	db := Connect("sqlite3", ":memory:")
+	defer db.DB.Close()
+
+	db.SetupTable("nullable", true, []Field{{Name: "id", Type: "INTEGER"}, {Name: "note", Type: "TEXT"}, {Name: "score", Type: "INTEGER"}}, nil)
+	db.ReplaceData("nullable", []interface{}{1, nil, 0})
+	db.ReplaceData("nullable", []interface{}{2, "", nil})
+
+	first := db.Fetch("SELECT id, note, score FROM nullable WHERE id = 1").GetRow(0)
+	first.ExpectNull("note")
+	first.ExpectNotNull("score")
+
+	second := db.Fetch("SELECT id, note, score FROM nullable WHERE id = 2").GetRow(0)
+	second.ExpectNotNull("note")
+	second.ExpectNull("score")
+
+	assertFails := func(name string, f func()) {
+		defer func() {
+			if _, ok := recover().(TestError); !ok {
+				t.Errorf("%s expected to fail", name)
+			}
+		}()
+		f()
+	}
+	assertFails("ExpectNull on a value", func() { first.ExpectNull("score") })
+	assertFails("ExpectNotNull on NULL", func() { first.ExpectNotNull("note") })
+	assertFails("missing column", func() { first.ExpectNull("missing") })
+
+	if !isSQLNull(sql.NullString{}) || isSQLNull(sql.NullString{String: "x", Valid: true}) {
+		t.Errorf("Expected sql.NullString validity to decide NULL")
+	}
+	var nilPtr *string
+	if !isSQLNull(nilPtr) {
+		t.Errorf("Expected a nil pointer to be NULL")
+	}
+}
+
+func TestMixedCaseTableName(t *testing.T) {
+	db := Connect("sqlite3", ":memory:")
+	defer db.DB.Close()
+
+	// Helpers use the name unquoted, so (as with Oracle case folding) any spelling refers to the same table
+	db.SetupTable("UserAccounts", true, []Field{{Name: "id", Type: "INTEGER"}, {Name: "name", Type: "TEXT"}}, nil)
+	db.InsertOne("UserAccounts", []InsertField{{"id", 1}, {"name", "Alice"}})
+	db.ReplaceData("useraccounts", []interface{}{2, "Bob"})
+	db.ExpectExists("USERACCOUNTS", "name = ?", "Bob")
+
+	for _, name := range []string{"UserAccounts", "useraccounts", "USERACCOUNTS"} {
+		db.Fetch("SELECT id FROM " + db.Table(name)).ExpectCount(2)
+	}
+
+	// Quoted names stay quoted, with the prefix inside the quotes
+	if got := db.Table(`"Audit Log"`); got != `"Audit Log"` {
+		t.Errorf("Expected the quoted name unchanged, got %s", got)
+	}
+	db.SetupTable(`"Audit Log"`, true, []Field{{Name: "id", Type: "INTEGER"}}, []Index{{Columns: []string{"id"}}})
+	db.InsertOne(`"Audit Log"`, []InsertField{{"id", 1}})
+	db.ExpectExists(`"Audit Log"`, "id = ?", 1)
+	db.ExpectSchema(`"Audit Log"`, []Field{{Name: "id", Type: "INTEGER"}}, []Index{{Columns: []string{"id"}}})
+
+	db.TablePrefix = "run1_"
+	if got := db.Table(`"Audit Log"`); got != `"run1_Audit Log"` {
+		t.Errorf("Expected the prefix inside the quotes, got %s", got)
+	}
+	db.TablePrefix = ""
+
+	for _, name := range []string{`"UserAccounts`, `User"Accounts`, `"a"b"`} {
+		ExpectFailure(func() { db.Table(name) })
+	}
+}
+
+func TestFieldDefaultNow(t *testing.T) {
+	db := Connect("sqlite3", ":memory:")
+	defer db.DB.Close()
+
+	db.SetupTable("audit", true, []Field{
+		{Name: "id", Type: "INTEGER"},
+		{Name: "status", Type: "TEXT", Default: "'new'"},
+		{Name: "created_at", Type: "TIMESTAMP", Default: DefaultNow},
+	}, nil)
+	db.InsertOne("audit", []InsertField{{"id", 1}})
+
+	row := db.Fetch("SELECT status, created_at FROM audit WHERE id = 1").GetRow(0)
+	row.Expect("status", "new")
+	row.ExpectNotNull("created_at")
+	db.Fetch("SELECT id FROM audit WHERE created_at >= datetime('now', '-1 minute')").ExpectCount(1)
+
+	if got := (&DBClient{DriverName: "oracle"}).defaultExpr(DefaultNow); got != "SYSTIMESTAMP" {
+		t.Errorf("Expected SYSTIMESTAMP on Oracle, got %s", got)
+	}
+}
+
+// steppingClock advances by step on every Now call, so any timed operation appears to take step.
+type steppingClock struct {
+	FakeClock
+	step time.Duration
+}
+
+func (s *steppingClock) Now() time.Time {
+	s.Advance(s.step)
+	return s.FakeClock.Now()
+}
+
+func TestSlowQueryThreshold(t *testing.T) {
+	db := Connect("sqlite3", ":memory:")
+	defer db.DB.Close()
+	db.SetupTable("slow_items", true, []Field{{Name: "id", Type: "INTEGER"}}, nil)
+
+	SetClock(&steppingClock{step: 1200 * time.Millisecond})
+	defer SetClock(nil)
+
+	slowQueries := func() []string {
+		tester := NewTester()
+		tester.Stage("Slow", func() {
+			db.InsertOne("slow_items", []InsertField{{Key: "id", Value: 1}})
+			db.Fetch("SELECT id FROM slow_items")
+			tx := db.BeginTx()
+			tx.Exec("INSERT INTO slow_items (id) VALUES (?)", 2)
+			tx.Commit()
+		})
+		tester.RunStageByName("Slow")
+		var out []string
+		for _, e := range tester.StageLogs("Slow") {
+			if strings.HasPrefix(e.Summary, "SLOW QUERY") {
+				out = append(out, e.Summary)
+			}
+		}
+		return out
+	}
+
+	if got := slowQueries(); len(got) != 0 {
+		t.Errorf("Expected no warnings with the threshold off, got %v", got)
+	}
+
+	db.SlowQueryThreshold = time.Second
+	got := slowQueries()
+	if len(got) != 3 {
+		t.Fatalf("Expected a warning for the insert, the fetch and the transaction exec, got %v", got)
+	}
+	if want := "SLOW QUERY 1.2s: SELECT id FROM slow_items"; got[1] != want {
+		t.Errorf("Expected %q, got %q", want, got[1])
+	}
+	if want := "SLOW QUERY 1.2s: INSERT INTO slow_items (id) VALUES (?)"; got[2] != want {
+		t.Errorf("Expected %q, got %q", want, got[2])
+	}
+
+	db.SlowQueryThreshold = 2 * time.Second
+	if got := slowQueries(); len(got) != 0 {
+		t.Errorf("Expected no warnings under the threshold, got %v", got)
+	}
+}
+
+func TestExpectNoOrphans(t *testing.T) {
+	db := Connect("sqlite3", ":memory:")
+	defer db.DB.Close()
+
+	db.SetupTable("customers", true, []Field{{Name: "id", Type: "INTEGER PRIMARY KEY"}, {Name: "name", Type: "TEXT"}}, nil)
+	db.SetupTable("orders", true, []Field{{Name: "id", Type: "INTEGER PRIMARY KEY"}, {Name: "customer_id", Type: "INTEGER"}}, nil)
+	db.ReplaceData("customers", []interface{}{1, "Alice"})
+	db.ReplaceData("customers", []interface{}{2, "Bob"})
+	db.ReplaceData("orders", []interface{}{10, 1})
+	db.ReplaceData("orders", []interface{}{11, 2})
+	// A NULL foreign key is not an orphan
+	db.ReplaceData("orders", []interface{}{12, nil})
+
+	db.ExpectNoOrphans("orders", "customer_id", "customers", "id")
+
+	// Deleting a parent without cascading leaves orphans behind
+	db.DeleteOne("customers", "id = ?", 2)
+	db.ReplaceData("orders", []interface{}{13, 99})
+
+	var msg string
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				te, ok := r.(TestError)
+				if !ok {
+					t.Fatalf("Unexpected panic: %v", r)
+				}
+				msg = te.Message
+			}
+		}()
+		db.ExpectNoOrphans("orders", "customer_id", "customers", "id")
+	}()
+	if msg == "" {
+		t.Fatal("Expected ExpectNoOrphans to fail")
+	}
+	if !strings.Contains(msg, "Found 2 orphaned") || !strings.HasSuffix(msg, ": 2, 99") {
+		t.Errorf("Expected the orphaned keys in the failure, got %q", msg)
+	}
+}
+
+func TestUpdateMany(t *testing.T) {
+	db := Connect("sqlite3", ":memory:")
+	defer db.DB.Close()
+	db.SetupTable("many_users", true, []Field{
+		{Name: "id", Type: "INTEGER PRIMARY KEY"},
+		{Name: "name", Type: "TEXT"},
+		{Name: "tier", Type: "TEXT"},
+		{Name: "score", Type: "INTEGER"},
+	}, nil)
+	for i, name := range []string{"alice", "bob", "carol", "dave"} {
+		db.InsertOne("many_users", []InsertField{{Key: "id", Value: i + 1}, {Key: "name", Value: name}, {Key: "tier", Value: "basic"}, {Key: "score", Value: 0}})
+	}
+
+	db.UpdateMany("many_users", "id", map[interface{}]map[string]interface{}{
+		1: {"tier": "gold", "score": 90},
+		2: {"score": 40},
+		4: {"tier": "silver", "name": "david"},
+	})
+
+	want := []map[string]interface{}{
+		{"id": 1, "name": "alice", "tier": "gold", "score": 90},
+		{"id": 2, "name": "bob", "tier": "basic", "score": 40},
+		{"id": 3, "name": "carol", "tier": "basic", "score": 0},
+		{"id": 4, "name": "david", "tier": "silver", "score": 0},
+	}
+	db.Fetch("SELECT id, name, tier, score FROM many_users ORDER BY id").ExpectRows(want)
+
+	// A failing update rolls back the ones before it
+	ExpectFailure(func() {
+		db.UpdateMany("many_users", "id", map[interface{}]map[string]interface{}{
+			1: {"score": 100},
+			2: {"missing_column": 1},
+		})
+	})
+	db.Fetch("SELECT score FROM many_users WHERE id = 1").GetRow(0).Expect("score", 90)
+}
+
+func TestExpectAggregate(t *testing.T) {
+	db := Connect("sqlite3", ":memory:")
+	defer db.DB.Close()
+	db.SetupTable("agg_orders", true, []Field{
+		{Name: "id", Type: "INTEGER PRIMARY KEY"},
+		{Name: "status", Type: "TEXT"},
+		{Name: "qty", Type: "INTEGER"},
+		{Name: "price", Type: "REAL"},
+	}, nil)
+	rows := []struct {
+		status string
+		qty    int
+		price  float64
+	}{{"paid", 2, 0.1}, {"paid", 3, 0.2}, {"open", 7, 9.99}}
+	for i, r := range rows {
+		db.InsertOne("agg_orders", []InsertField{{Key: "id", Value: i + 1}, {Key: "status", Value: r.status}, {Key: "qty", Value: r.qty}, {Key: "price", Value: r.price}})
+	}
+
+	db.ExpectAggregate("SELECT SUM(qty) FROM agg_orders", 12)
+	db.ExpectAggregate("SELECT SUM(qty) FROM agg_orders WHERE status = ?", 5.0, "paid")
+	db.ExpectAggregate("SELECT AVG(qty) FROM agg_orders", 4)
+	db.ExpectAggregate("SELECT MAX(status) FROM agg_orders", "paid")
+	// 0.1 + 0.2 is 0.30000000000000004 in floating point
+	db.ExpectAggregate("SELECT SUM(price) FROM agg_orders WHERE status = ?", 0.3, "paid")
+	db.ExpectAggregate("SELECT AVG(price) FROM agg_orders WHERE status = ?", 0.15, "paid")
+	db.ExpectAggregate("SELECT SUM(qty) FROM agg_orders WHERE status = ?", nil, "missing")
+	db.ExpectAggregate("SELECT '12.50'", 12.5)
+
+	ExpectFailure(func() { db.ExpectAggregate("SELECT SUM(qty) FROM agg_orders", 13) })
+	ExpectFailure(func() { db.ExpectAggregate("SELECT AVG(price) FROM agg_orders WHERE status = ?", 0.1501, "paid") })
+	ExpectFailure(func() { db.ExpectAggregate("SELECT SUM(qty) FROM agg_orders WHERE status = ?", 0, "missing") })
+	ExpectFailure(func() { db.ExpectAggregate("SELECT qty FROM agg_orders", 2) })
+	ExpectFailure(func() { db.ExpectAggregate("SELECT SUM(qty), MAX(qty) FROM agg_orders", 12) })
+}
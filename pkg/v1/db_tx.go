@@ -0,0 +1,141 @@
+package v1
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DBTx wraps a database transaction started with BeginTx.
+type DBTx struct {
+	Tx         *sql.Tx
+	DriverName string
+	// client started the transaction; its SlowQueryThreshold applies to Exec
+	client *DBClient
+}
+
+// savepointNameRe restricts savepoint names to plain identifiers (they can't be bound as args).
+var savepointNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// BeginTx starts a transaction. Finish it with Commit or Rollback.
+func (c *DBClient) BeginTx() *DBTx {
+	RecordAction("DB BeginTx", func() { c.BeginTx() })
+	if IsDryRun() {
+		return &DBTx{DriverName: c.DriverName, client: c}
+	}
+	if c.DB == nil {
+		Fail("DBClient is not connected")
+	}
+	var tx *sql.Tx
+	_, err := observeDB(DBOpBegin, "", nil, func() error {
+		var err error
+		tx, err = c.DB.Begin()
+		return err
+	})
+	if err != nil {
+		Fail("Failed to begin transaction: %v", err)
+	}
+	Log(LogTypeDB, "Transaction started", "")
+	return &DBTx{Tx: tx, DriverName: c.DriverName, client: c}
+}
+
+// Exec runs a statement inside the transaction.
+func (tx *DBTx) Exec(query string, args ...interface{}) {
+	RecordAction("DB Tx Exec", func() { tx.Exec(query, args...) })
+	if IsDryRun() {
+		return
+	}
+	if tx.Tx == nil {
+		Fail("Transaction is not started")
+	}
+
+	finalQuery := query
+	if tx.DriverName == "oracle" {
+		// Replace ? with :n
+		argCounter := 1
+		count := strings.Count(query, "?")
+		for i := 0; i < count; i++ {
+			finalQuery = strings.Replace(finalQuery, "?", fmt.Sprintf(":%d", argCounter), 1)
+			argCounter++
+		}
+	}
+
+	Log(LogTypeDB, "Tx Exec", fmt.Sprintf("Query: %s\nArgs: %v", finalQuery, args))
+	elapsed, err := observeDB(DBOpTxExec, finalQuery, args, func() error {
+		_, err := tx.Tx.Exec(finalQuery, args...)
+		return err
+	})
+	if tx.client != nil {
+		tx.client.checkSlowQuery(finalQuery, elapsed)
+	}
+	if err != nil {
+		Fail("Failed to exec in transaction: %v", err)
+	}
+}
+
+// Commit commits the transaction.
+func (tx *DBTx) Commit() {
+	RecordAction("DB Tx Commit", func() { tx.Commit() })
+	if IsDryRun() {
+		return
+	}
+	if tx.Tx == nil {
+		Fail("Transaction is not started")
+	}
+	if _, err := observeDB(DBOpCommit, "", nil, tx.Tx.Commit); err != nil {
+		Fail("Failed to commit transaction: %v", err)
+	}
+	Log(LogTypeDB, "Transaction committed", "")
+}
+
+// Rollback rolls back the whole transaction.
+func (tx *DBTx) Rollback() {
+	RecordAction("DB Tx Rollback", func() { tx.Rollback() })
+	if IsDryRun() {
+		return
+	}
+	if tx.Tx == nil {
+		Fail("Transaction is not started")
+	}
+	if _, err := observeDB(DBOpRollback, "", nil, tx.Tx.Rollback); err != nil {
+		Fail("Failed to roll back transaction: %v", err)
+	}
+	Log(LogTypeDB, "Transaction rolled back", "")
+}
+
+// Savepoint marks a point inside the transaction that RollbackTo can return to.
+// The SAVEPOINT syntax is shared by Postgres, MySQL, Oracle and SQLite.
+func (tx *DBTx) Savepoint(name string) {
+	RecordAction(fmt.Sprintf("DB Tx Savepoint: %s", name), func() { tx.Savepoint(name) })
+	if IsDryRun() {
+		return
+	}
+	tx.execSavepoint(name, "SAVEPOINT "+name)
+}
+
+// RollbackTo undoes everything done since the named savepoint; the transaction stays open.
+func (tx *DBTx) RollbackTo(name string) {
+	RecordAction(fmt.Sprintf("DB Tx RollbackTo: %s", name), func() { tx.RollbackTo(name) })
+	if IsDryRun() {
+		return
+	}
+	tx.execSavepoint(name, "ROLLBACK TO SAVEPOINT "+name)
+}
+
+func (tx *DBTx) execSavepoint(name, query string) {
+	if tx.Tx == nil {
+		Fail("Transaction is not started")
+	}
+	if !savepointNameRe.MatchString(name) {
+		Fail("Invalid savepoint name %q", name)
+	}
+	Log(LogTypeDB, "Tx Savepoint", fmt.Sprintf("Query: %s", query))
+	_, err := observeDB(DBOpTxExec, query, nil, func() error {
+		_, err := tx.Tx.Exec(query)
+		return err
+	})
+	if err != nil {
+		Fail("Failed to run %q: %v", query, err)
+	}
+}
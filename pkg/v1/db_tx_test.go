@@ -0,0 +1,54 @@
+package v1
+
+import (
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestDBTxSavepoint(t *testing.T) {
+	db := Connect("sqlite3", ":memory:")
+	defer db.DB.Close()
+
+	db.SetupTable("accounts", true, []Field{
+		{Name: "id", Type: "INTEGER PRIMARY KEY"},
+		{Name: "balance", Type: "INTEGER"},
+	}, nil)
+
+	tx := db.BeginTx()
+	tx.Exec("INSERT INTO accounts (id, balance) VALUES (?, ?)", 1, 100)
+	tx.Savepoint("seeded")
+
+	tx.Exec("UPDATE accounts SET balance = ? WHERE id = ?", 0, 1)
+	tx.Exec("INSERT INTO accounts (id, balance) VALUES (?, ?)", 2, 50)
+	tx.RollbackTo("seeded")
+
+	tx.Exec("INSERT INTO accounts (id, balance) VALUES (?, ?)", 3, 75)
+	tx.Commit()
+
+	result := db.Fetch("SELECT id, balance FROM accounts ORDER BY id")
+	result.ExpectCount(2)
+	result.GetRow(0).Expect("id", int64(1))
+	result.GetRow(0).Expect("balance", int64(100))
+	result.GetRow(1).Expect("id", int64(3))
+
+	// Full rollback discards everything
+	tx = db.BeginTx()
+	tx.Exec("DELETE FROM accounts")
+	tx.Rollback()
+	db.Fetch("SELECT id FROM accounts").ExpectCount(2)
+
+	// Invalid savepoint names are rejected
+	tx = db.BeginTx()
+	defer tx.Rollback()
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("Expected invalid savepoint name to panic")
+			} else if _, ok := r.(TestError); !ok {
+				t.Errorf("Unexpected panic type: %T", r)
+			}
+		}()
+		tx.Savepoint("bad; DROP TABLE accounts")
+	}()
+}
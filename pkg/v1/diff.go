@@ -0,0 +1,126 @@
+package v1
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// diffMissing marks a map key or slice element present on only one side of a diff.
+const diffMissing = "<missing>"
+
+// diff deeply compares expected and actual and returns one line per difference,
+// each prefixed with the path to it (e.g. ".user.age: 30 != 31"). Map keys are
+// visited in sorted order so the output is stable; numbers of different Go types
+// compare by value. An empty result means the values are equal.
+func diff(expected, actual interface{}) []string {
+	var out []string
+	diffValues("", reflect.ValueOf(expected), reflect.ValueOf(actual), &out)
+	return out
+}
+
+func diffValues(path string, a, b reflect.Value, out *[]string) {
+	// Look through interfaces and pointers
+	for a.IsValid() && (a.Kind() == reflect.Interface || a.Kind() == reflect.Ptr) && !a.IsNil() {
+		a = a.Elem()
+	}
+	for b.IsValid() && (b.Kind() == reflect.Interface || b.Kind() == reflect.Ptr) && !b.IsNil() {
+		b = b.Elem()
+	}
+
+	p := path
+	if p == "" {
+		p = "."
+	}
+	report := func() {
+		*out = append(*out, fmt.Sprintf("%s: %s != %s", p, diffString(a), diffString(b)))
+	}
+
+	if !a.IsValid() || !b.IsValid() || isNilValue(a) || isNilValue(b) {
+		if a.IsValid() != b.IsValid() || isNilValue(a) != isNilValue(b) {
+			report()
+		}
+		return
+	}
+	if isNumber(a.Interface()) && isNumber(b.Interface()) {
+		if toFloat64(a.Interface()) != toFloat64(b.Interface()) {
+			report()
+		}
+		return
+	}
+	if a.Type() != b.Type() {
+		*out = append(*out, fmt.Sprintf("%s: %s (%s) != %s (%s)", p, diffString(a), a.Type(), diffString(b), b.Type()))
+		return
+	}
+
+	switch a.Kind() {
+	case reflect.Map:
+		keys := make(map[string]reflect.Value)
+		for _, k := range a.MapKeys() {
+			keys[fmt.Sprintf("%v", k.Interface())] = k
+		}
+		for _, k := range b.MapKeys() {
+			keys[fmt.Sprintf("%v", k.Interface())] = k
+		}
+		names := make([]string, 0, len(keys))
+		for name := range keys {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			k := keys[name]
+			childPath := fmt.Sprintf("%s[%v]", path, name)
+			if k.Kind() == reflect.String {
+				childPath = path + "." + name
+			}
+			diffValues(childPath, a.MapIndex(k), b.MapIndex(k), out)
+		}
+	case reflect.Slice, reflect.Array:
+		n := a.Len()
+		if b.Len() > n {
+			n = b.Len()
+		}
+		for i := 0; i < n; i++ {
+			var ea, eb reflect.Value
+			if i < a.Len() {
+				ea = a.Index(i)
+			}
+			if i < b.Len() {
+				eb = b.Index(i)
+			}
+			diffValues(fmt.Sprintf("%s[%d]", path, i), ea, eb, out)
+		}
+	case reflect.Struct:
+		for i := 0; i < a.NumField(); i++ {
+			if !a.Type().Field(i).IsExported() {
+				continue
+			}
+			diffValues(path+"."+a.Type().Field(i).Name, a.Field(i), b.Field(i), out)
+		}
+	default:
+		if !reflect.DeepEqual(a.Interface(), b.Interface()) {
+			report()
+		}
+	}
+}
+
+func isNilValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Map, reflect.Slice, reflect.Ptr, reflect.Interface, reflect.Func, reflect.Chan:
+		return v.IsNil()
+	}
+	return false
+}
+
+func diffString(v reflect.Value) string {
+	if !v.IsValid() {
+		return diffMissing
+	}
+	if isNilValue(v) {
+		return "nil"
+	}
+	if v.Kind() == reflect.String {
+		return fmt.Sprintf("%q", v.String())
+	}
+	return fmt.Sprintf("%v", v.Interface())
+}
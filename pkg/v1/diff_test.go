@@ -0,0 +1,61 @@
+package v1
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	type user struct {
+		Name string
+		Age  int
+		Tags []string
+	}
+
+	tests := []struct {
+		name     string
+		expected interface{}
+		actual   interface{}
+		want     []string
+	}{
+		{"equal maps", map[string]interface{}{"a": 1}, map[string]interface{}{"a": 1}, nil},
+		{"numbers across types", map[string]interface{}{"age": 30}, map[string]interface{}{"age": float64(30)}, nil},
+		{
+			"nested field",
+			map[string]interface{}{"user": map[string]interface{}{"name": "alice", "age": 30}},
+			map[string]interface{}{"user": map[string]interface{}{"name": "alice", "age": 31}},
+			[]string{".user.age: 30 != 31"},
+		},
+		{
+			"missing and extra keys",
+			map[string]interface{}{"a": 1, "b": 2},
+			map[string]interface{}{"a": 1, "c": 3},
+			[]string{".b: 2 != <missing>", ".c: <missing> != 3"},
+		},
+		{
+			"slice elements",
+			[]interface{}{"x", "y"},
+			[]interface{}{"x", "z", "w"},
+			[]string{`[1]: "y" != "z"`, `[2]: <missing> != "w"`},
+		},
+		{
+			"struct fields",
+			user{Name: "bob", Age: 20, Tags: []string{"a"}},
+			&user{Name: "bob", Age: 21, Tags: []string{"b"}},
+			[]string{".Age: 20 != 21", `.Tags[0]: "a" != "b"`},
+		},
+		{"type mismatch", map[string]interface{}{"id": "1"}, map[string]interface{}{"id": 1}, []string{`.id: "1" (string) != 1 (int)`}},
+		{"nil vs value", map[string]interface{}{"v": nil}, map[string]interface{}{"v": "x"}, []string{`.v: nil != "x"`}},
+		{"root scalar", "a", "b", []string{`.: "a" != "b"`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := diff(tt.expected, tt.actual)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diff() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package v1
 
 import (
 	"fmt"
+	"strings"
 
 	dm "github.com/XWinterVarit/integrate_tester/pkg/dynamic-mock-server"
 )
@@ -28,6 +29,66 @@ const (
 	ConditionLessThanOrEqual    = dm.ConditionLessThanOrEqual
 )
 
+// Port modes for SetPortMode
+const (
+	PortModeNormal      = dm.PortModeNormal
+	PortModeMaintenance = dm.PortModeMaintenance
+)
+
+// RecordedRequest is a request received on a mock port, as listed by GlobalInteractionLog.
+type RecordedRequest = dm.RecordedRequest
+
+// Interaction describes a call expected by ExpectInteractionOrder. Zero fields match any value.
+type Interaction struct {
+	Port   int
+	Method string
+	Path   string
+}
+
+func (i Interaction) matches(r RecordedRequest) bool {
+	return (i.Port == 0 || i.Port == r.Port) &&
+		(i.Method == "" || strings.EqualFold(i.Method, r.Method)) &&
+		(i.Path == "" || i.Path == r.Path)
+}
+
+func (i Interaction) String() string {
+	port, method, path := "*", "*", "*"
+	if i.Port != 0 {
+		port = fmt.Sprintf("%d", i.Port)
+	}
+	if i.Method != "" {
+		method = i.Method
+	}
+	if i.Path != "" {
+		path = i.Path
+	}
+	return fmt.Sprintf(":%s %s %s", port, method, path)
+}
+
+// Condition is a single check combined by IfAllSetCase / IfAnySetCase.
+type Condition = dm.Condition
+
+// Sources for Condition.Source
+const (
+	SourceHeader          = dm.SourceHeader
+	SourceQuery           = dm.SourceQuery
+	SourceJsonBody        = dm.SourceJsonBody
+	SourceXmlBody         = dm.SourceXmlBody
+	SourcePath            = dm.SourcePath
+	SourceDynamicVariable = dm.SourceDynamicVariable
+	SourceBodySize        = dm.SourceBodySize
+)
+
+// Kinds for GenerateFake
+const (
+	FakeEmail = dm.FakeEmail
+	FakeName  = dm.FakeName
+	FakePhone = dm.FakePhone
+	FakeUUID  = dm.FakeUUID
+	FakeIPv4  = dm.FakeIPv4
+	FakeWord  = dm.FakeWord
+)
+
 // NewDynamicMockClient creates a new client for an existing dynamic mock server.
 // controlURL is the base URL of the mock controller (e.g., "http://localhost:8888").
 func NewDynamicMockClient(controlURL string) *DynamicMockClient {
@@ -51,6 +112,31 @@ func (c *DynamicMockClient) RegisterRoute(port int, method string, path string,
 	return c.Client.RegisterRoute(port, method, path, responseFuncs)
 }
 
+// RegisterRouteMethods registers the same steps for path under each method. No-op in dry-run.
+func (c *DynamicMockClient) RegisterRouteMethods(port int, methods []string, path string, responseFuncs []ResponseFuncConfig) error {
+	RecordAction(fmt.Sprintf("Mock RegisterRouteMethods: %s %s", strings.Join(methods, ","), path), func() { c.RegisterRouteMethods(port, methods, path, responseFuncs) })
+	if IsDryRun() {
+		return nil
+	}
+	if c == nil || c.Client == nil {
+		return fmt.Errorf("mock client is not initialized")
+	}
+	return c.Client.RegisterRouteMethods(port, methods, path, responseFuncs)
+}
+
+// RegisterFromOpenAPI registers a route per operation of an OpenAPI 3 spec, serving its
+// example response. No-op in dry-run.
+func (c *DynamicMockClient) RegisterFromOpenAPI(port int, specPath string) error {
+	RecordAction(fmt.Sprintf("Mock RegisterFromOpenAPI: %d %s", port, specPath), func() { c.RegisterFromOpenAPI(port, specPath) })
+	if IsDryRun() {
+		return nil
+	}
+	if c == nil || c.Client == nil {
+		return fmt.Errorf("mock client is not initialized")
+	}
+	return c.Client.RegisterFromOpenAPI(port, specPath)
+}
+
 // ResetPort resets routes for a port. No-op in dry-run.
 func (c *DynamicMockClient) ResetPort(port int) error {
 	RecordAction(fmt.Sprintf("Mock ResetPort: %d", port), func() { c.ResetPort(port) })
@@ -75,6 +161,102 @@ func (c *DynamicMockClient) ResetAll() error {
 	return c.Client.ResetAll()
 }
 
+// SetNotFoundResponse customizes the response for unmatched requests on a port. No-op in dry-run.
+func (c *DynamicMockClient) SetNotFoundResponse(port int, statusCode int, body string) error {
+	RecordAction(fmt.Sprintf("Mock SetNotFoundResponse: %d", port), func() { c.SetNotFoundResponse(port, statusCode, body) })
+	if IsDryRun() {
+		return nil
+	}
+	if c == nil || c.Client == nil {
+		return fmt.Errorf("mock client is not initialized")
+	}
+	return c.Client.SetNotFoundResponse(port, statusCode, body)
+}
+
+// SetPortMode switches a port to PortModeMaintenance (every request gets 503) or back to
+// PortModeNormal, keeping its routes. No-op in dry-run.
+func (c *DynamicMockClient) SetPortMode(port int, mode string) error {
+	RecordAction(fmt.Sprintf("Mock SetPortMode: %d %s", port, mode), func() { c.SetPortMode(port, mode) })
+	if IsDryRun() {
+		return nil
+	}
+	if c == nil || c.Client == nil {
+		return fmt.Errorf("mock client is not initialized")
+	}
+	return c.Client.SetPortMode(port, mode)
+}
+
+// DescribeRoute returns the steps registered for a route. Returns nil in dry-run.
+func (c *DynamicMockClient) DescribeRoute(port int, method string, path string) ([]ResponseFuncConfig, error) {
+	RecordAction(fmt.Sprintf("Mock DescribeRoute: %s %s", method, path), func() { c.DescribeRoute(port, method, path) })
+	if IsDryRun() {
+		return nil, nil
+	}
+	if c == nil || c.Client == nil {
+		return nil, fmt.Errorf("mock client is not initialized")
+	}
+	return c.Client.DescribeRoute(port, method, path)
+}
+
+// GlobalInteractionLog returns the requests received on all mock ports since the last reset,
+// oldest first, with their timestamps. Returns nil in dry-run.
+func (c *DynamicMockClient) GlobalInteractionLog() ([]RecordedRequest, error) {
+	RecordAction("Mock GlobalInteractionLog", func() { c.GlobalInteractionLog() })
+	if IsDryRun() {
+		return nil, nil
+	}
+	if c == nil || c.Client == nil {
+		return nil, fmt.Errorf("mock client is not initialized")
+	}
+	return c.Client.Interactions()
+}
+
+// ResetInteractions clears the interaction log, e.g. at the start of a flow. No-op in dry-run.
+func (c *DynamicMockClient) ResetInteractions() error {
+	RecordAction("Mock ResetInteractions", func() { c.ResetInteractions() })
+	if IsDryRun() {
+		return nil
+	}
+	if c == nil || c.Client == nil {
+		return fmt.Errorf("mock client is not initialized")
+	}
+	return c.Client.ResetInteractions()
+}
+
+// ExpectInteractionOrder asserts that the expected calls reached the mocks in this order,
+// across ports. Other calls may happen in between; each expected call must come after the
+// previous one.
+func (c *DynamicMockClient) ExpectInteractionOrder(expected []Interaction) {
+	RecordAction(fmt.Sprintf("Mock ExpectInteractionOrder: %d call(s)", len(expected)), func() { c.ExpectInteractionOrder(expected) })
+	if IsDryRun() {
+		return
+	}
+	if c == nil || c.Client == nil {
+		Fail("mock client is not initialized")
+		return
+	}
+	calls, err := c.Client.Interactions()
+	if err != nil {
+		Fail("ExpectInteractionOrder failed: could not read the interaction log: %v", err)
+	}
+
+	actual := make([]string, len(calls))
+	for i, r := range calls {
+		actual[i] = fmt.Sprintf("%s :%d %s %s", r.Timestamp.Format("15:04:05.000"), r.Port, r.Method, r.Path)
+	}
+	next := 0
+	for _, want := range expected {
+		for next < len(calls) && !want.matches(calls[next]) {
+			next++
+		}
+		if next == len(calls) {
+			Fail("ExpectInteractionOrder failed: %s not found after the previous expected call\nActual calls:\n%s", want, strings.Join(actual, "\n"))
+		}
+		next++
+	}
+	Log(LogTypeExpect, fmt.Sprintf("Interaction order of %d call(s) - PASSED", len(expected)), strings.Join(actual, "\n"))
+}
+
 // Generator and Condition Functions Aliases
 
 var (
@@ -91,6 +273,13 @@ var (
 
 	IfDynamicVariable        = dm.IfDynamicVariable
 	IfDynamicVariableSetCase = dm.IfDynamicVariableSetCase
+	IfRemoteAddrSetCase      = dm.IfRemoteAddrSetCase
+	IfRequestBodySizeSetCase = dm.IfRequestBodySizeSetCase
+	SetCaseByTimeWindow      = dm.SetCaseByTimeWindow
+	IfAllSetCase             = dm.IfAllSetCase
+	IfAnySetCase             = dm.IfAnySetCase
+	IfAcceptSetCase          = dm.IfAcceptSetCase
+	RequireJsonFields        = dm.RequireJsonFields
 
 	IfRequestJsonArrayLength         = dm.IfRequestJsonArrayLength
 	IfRequestJsonArrayLengthSetCase  = dm.IfRequestJsonArrayLengthSetCase
@@ -99,16 +288,23 @@ var (
 	IfRequestJsonType                = dm.IfRequestJsonType
 	IfRequestJsonTypeSetCase         = dm.IfRequestJsonTypeSetCase
 
-	ExtractRequestHeader   = dm.ExtractRequestHeader
-	ExtractRequestJsonBody = dm.ExtractRequestJsonBody
-	ExtractRequestXmlBody  = dm.ExtractRequestXmlBody
-	ExtractRequestPath     = dm.ExtractRequestPath
-	ExtractRequestQuery    = dm.ExtractRequestQuery
+	ExtractRequestHeader     = dm.ExtractRequestHeader
+	ExtractRequestJsonBody   = dm.ExtractRequestJsonBody
+	ExtractRequestXmlBody    = dm.ExtractRequestXmlBody
+	ExtractRequestPath       = dm.ExtractRequestPath
+	ExtractRequestQuery      = dm.ExtractRequestQuery
+	ExtractRequestRemoteAddr = dm.ExtractRequestRemoteAddr
+	EchoRequestHeaders       = dm.EchoRequestHeaders
+	RequireBasicAuth         = dm.RequireBasicAuth
+	RequireBearer            = dm.RequireBearer
 
 	GenerateRandomString       = dm.GenerateRandomString
 	GenerateRandomInt          = dm.GenerateRandomInt
 	GenerateRandomIntFixLength = dm.GenerateRandomIntFixLength
 	GenerateRandomDecimal      = dm.GenerateRandomDecimal
+	GenerateSequence           = dm.GenerateSequence
+	GenerateFake               = dm.GenerateFake
+	SeedRandomFromRequest      = dm.SeedRandomFromRequest
 	HashedString               = dm.HashedString
 
 	ConvertToString     = dm.ConvertToString
@@ -119,10 +315,23 @@ var (
 
 	SetJsonBody           = dm.SetJsonBody
 	SetXmlBody            = dm.SetXmlBody
+	SetStreamBody         = dm.SetStreamBody
+	SetResponseByBodyHash = dm.SetResponseByBodyHash
 	SetStatusCode         = dm.SetStatusCode
+	SetStatusCodeTemplate = dm.SetStatusCodeTemplate
+	SetRedirect           = dm.SetRedirect
+	SetProxyPassthrough   = dm.SetProxyPassthrough
 	SetWait               = dm.SetWait
 	SetRandomWait         = dm.SetRandomWait
+	SetLatencyProfile     = dm.SetLatencyProfile
 	SetMethod             = dm.SetMethod
 	SetHeader             = dm.SetHeader
+	SetHeaders            = dm.SetHeaders
+	SetGzip               = dm.SetGzip
+	SetNoContentLength    = dm.SetNoContentLength
+	SetConnectionClose    = dm.SetConnectionClose
 	CopyHeaderFromRequest = dm.CopyHeaderFromRequest
+	CopyHeadersMatching   = dm.CopyHeadersMatching
+
+	HashRequestBody = dm.HashRequestBody
 )
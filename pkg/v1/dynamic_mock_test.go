@@ -1,9 +1,13 @@
 package v1
 
 import (
+	"fmt"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"testing"
+	"time"
 
 	dm "github.com/XWinterVarit/integrate_tester/pkg/dynamic-mock-server"
 )
@@ -56,3 +60,95 @@ func TestDynamicMockClient_HTTPSInsecureSkipVerify(t *testing.T) {
 		t.Fatalf("ResetAll over HTTPS failed: %v", err)
 	}
 }
+
+// startMockController runs a dynamic mock controller in-process and returns a client for it.
+func startMockController(t *testing.T) *DynamicMockClient {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	logger, err := dm.NewLogger(filepath.Join(t.TempDir(), "mock-server.log"))
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	t.Cleanup(logger.Close)
+	controller := dm.NewMockController(port, logger)
+	go controller.Start()
+
+	client := NewDynamicMockClient(fmt.Sprintf("http://localhost:%d", port))
+	for i := 0; i < 50; i++ {
+		if _, err := client.Interactions(); err == nil {
+			return client
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("Mock controller not up on port %d", port)
+	return nil
+}
+
+func TestExpectInteractionOrder(t *testing.T) {
+	client := startMockController(t)
+	t.Cleanup(func() { client.ResetAll() })
+
+	ports := make([]int, 2)
+	for i := range ports {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("listen: %v", err)
+		}
+		ports[i] = ln.Addr().(*net.TCPAddr).Port
+		ln.Close()
+	}
+	mockA, mockB := ports[0], ports[1]
+	client.RegisterRoute(mockA, http.MethodPost, "/orders", []ResponseFuncConfig{SetStatusCode("", 201)})
+	client.RegisterRoute(mockB, http.MethodPost, "/payments", []ResponseFuncConfig{SetStatusCode("", 200)})
+	client.RegisterRoute(mockB, http.MethodGet, "/health", []ResponseFuncConfig{SetStatusCode("", 200)})
+
+	call := func(method string, port int, path string) {
+		url := fmt.Sprintf("http://localhost:%d%s", port, path)
+		for i := 0; i < 50; i++ {
+			req, _ := http.NewRequest(method, url, nil)
+			if resp, err := http.DefaultClient.Do(req); err == nil {
+				resp.Body.Close()
+				return
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		t.Fatalf("%s %s failed", method, url)
+	}
+	// Make sure both mock ports are serving before the flow starts
+	call(http.MethodGet, mockB, "/health")
+	call(http.MethodGet, mockA, "/warmup")
+	if err := client.ResetInteractions(); err != nil {
+		t.Fatalf("ResetInteractions failed: %v", err)
+	}
+
+	// app -> mockA, then mockA's work -> mockB, with an unrelated call in between
+	call(http.MethodPost, mockA, "/orders")
+	call(http.MethodGet, mockB, "/health")
+	call(http.MethodPost, mockB, "/payments")
+
+	log, err := client.GlobalInteractionLog()
+	if err != nil || len(log) != 3 {
+		t.Fatalf("Expected 3 interactions, got %d (%v)", len(log), err)
+	}
+	if log[0].Timestamp.After(log[2].Timestamp) {
+		t.Errorf("Expected interactions oldest first, got %v then %v", log[0].Timestamp, log[2].Timestamp)
+	}
+
+	client.ExpectInteractionOrder([]Interaction{
+		{Port: mockA, Method: http.MethodPost, Path: "/orders"},
+		{Port: mockB, Method: http.MethodPost, Path: "/payments"},
+	})
+	client.ExpectInteractionOrder([]Interaction{{Path: "/orders"}, {Path: "/health"}, {Path: "/payments"}})
+	ExpectFailure(func() {
+		client.ExpectInteractionOrder([]Interaction{{Path: "/payments"}, {Path: "/orders"}})
+	})
+	ExpectFailure(func() {
+		client.ExpectInteractionOrder([]Interaction{{Port: mockB, Path: "/orders"}})
+	})
+}
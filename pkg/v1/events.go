@@ -0,0 +1,69 @@
+package v1
+
+import "time"
+
+// TestEvent types emitted to subscribers.
+const (
+	EventStageStarted = "stage-started"
+	EventStagePassed  = "stage-passed"
+	EventStageFailed  = "stage-failed"
+	EventLog          = "log"
+	EventArtifact     = "artifact"
+)
+
+// eventBufferSize is how many undelivered events a subscriber channel holds.
+const eventBufferSize = 256
+
+// TestEvent is a structured notification about a running tester, suitable for
+// encoding as JSON and forwarding to an external dashboard.
+type TestEvent struct {
+	Type  string    `json:"type"`
+	Stage string    `json:"stage"`
+	Time  time.Time `json:"time"`
+	// Log is set for EventLog events.
+	Log *LogEntry `json:"log,omitempty"`
+	// Error is set for EventStageFailed events.
+	Error string `json:"error,omitempty"`
+	// Artifact is set for EventArtifact events; its content encodes as base64.
+	Artifact *Artifact `json:"artifact,omitempty"`
+}
+
+// Subscribe returns a channel receiving the tester's events as they happen: stage start
+// and outcome, and every log entry written while one of its stages runs. Events are never
+// waited on: when a subscriber falls more than eventBufferSize events behind, newer
+// events are dropped for it. Call Unsubscribe to stop delivery and close the channel.
+func (t *Tester) Subscribe() <-chan TestEvent {
+	ch := make(chan TestEvent, eventBufferSize)
+	t.mu.Lock()
+	t.subscribers = append(t.subscribers, ch)
+	t.mu.Unlock()
+	return ch
+}
+
+// Unsubscribe stops delivering events to ch and closes it.
+func (t *Tester) Unsubscribe(ch <-chan TestEvent) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, sub := range t.subscribers {
+		if sub == ch {
+			t.subscribers = append(t.subscribers[:i], t.subscribers[i+1:]...)
+			close(sub)
+			return
+		}
+	}
+}
+
+// emit delivers ev to every subscriber without blocking the running stage.
+func (t *Tester) emit(ev TestEvent) {
+	if ev.Time.IsZero() {
+		ev.Time = clockNow()
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, sub := range t.subscribers {
+		select {
+		case sub <- ev:
+		default:
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package v1
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSubscribeEvents(t *testing.T) {
+	tester := NewTester()
+	tester.Stage("EventsPass", func() { Log(LogTypeInfo, "working", "") })
+	tester.Stage("EventsFail", func() { Fail("boom") })
+
+	events := tester.Subscribe()
+	tester.RunStageByName("EventsPass")
+	tester.RunStageByName("EventsFail")
+	tester.Unsubscribe(events)
+
+	var got []string
+	var failErr string
+	for ev := range events {
+		desc := ev.Type + " " + ev.Stage
+		if ev.Type == EventLog {
+			desc += " " + ev.Log.Summary
+		}
+		if ev.Type == EventStageFailed {
+			failErr = ev.Error
+		}
+		if ev.Time.IsZero() {
+			t.Errorf("Event %q has no time", desc)
+		}
+		got = append(got, desc)
+	}
+
+	want := []string{
+		"stage-started EventsPass",
+		"log EventsPass Running Stage: EventsPass",
+		"log EventsPass working",
+		"log EventsPass Stage EventsPass PASSED",
+		"stage-passed EventsPass",
+		"stage-started EventsFail",
+		"log EventsFail Running Stage: EventsFail",
+		"log EventsFail Assertion FAILED",
+		"log EventsFail Stage EventsFail FAILED",
+		"stage-failed EventsFail",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unexpected event sequence:\n got: %q\nwant: %q", got, want)
+	}
+	if !strings.Contains(failErr, "boom") {
+		t.Errorf("Expected the failure event to carry the error, got %q", failErr)
+	}
+
+	// Unsubscribed channels receive nothing further
+	tester.RunStageByName("EventsPass")
+}
@@ -0,0 +1,160 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// goldenIgnored replaces the values at ignored paths in golden files and compared bodies.
+const goldenIgnored = "<ignored>"
+
+// UpdateGoldenEnv is the environment variable that, set to "1" or "true", makes
+// ExpectMatchesGolden rewrite golden files unless SetUpdateGolden decides otherwise.
+const UpdateGoldenEnv = "UPDATE_GOLDEN"
+
+// UpdateGoldenFlag is the command-line flag that also enables rewriting. The package does
+// not register it, since a library defining global flags clashes with its importers; a
+// test package opts in with
+//
+//	var _ = flag.Bool(v1.UpdateGoldenFlag, false, "rewrite golden files")
+//
+// and then runs "go test -update-golden".
+const UpdateGoldenFlag = "update-golden"
+
+var (
+	updateGoldenMu  sync.Mutex
+	updateGoldenSet *bool
+)
+
+// SetUpdateGolden overrides the -update-golden flag and the UPDATE_GOLDEN environment
+// variable: when true, ExpectMatchesGolden writes the actual response to the golden file
+// instead of comparing.
+func SetUpdateGolden(update bool) {
+	updateGoldenMu.Lock()
+	defer updateGoldenMu.Unlock()
+	updateGoldenSet = &update
+}
+
+func updateGolden() bool {
+	updateGoldenMu.Lock()
+	defer updateGoldenMu.Unlock()
+	if updateGoldenSet != nil {
+		return *updateGoldenSet
+	}
+	if f := flag.Lookup(UpdateGoldenFlag); f != nil {
+		if on, err := strconv.ParseBool(f.Value.String()); err == nil && on {
+			return true
+		}
+	}
+	switch strings.ToLower(os.Getenv(UpdateGoldenEnv)) {
+	case "1", "true":
+		return true
+	}
+	return false
+}
+
+// GoldenOption configures ExpectMatchesGolden.
+type GoldenOption func(*goldenConfig)
+
+type goldenConfig struct {
+	ignorePaths []string
+}
+
+// WithGoldenIgnorePaths masks volatile JSON fields (ids, timestamps, ...) before comparing
+// and writing, using the paths of ExpectJsonBodyField ("[*]" included). Paths absent from
+// the body are skipped.
+func WithGoldenIgnorePaths(paths ...string) GoldenOption {
+	return func(c *goldenConfig) {
+		c.ignorePaths = append(c.ignorePaths, paths...)
+	}
+}
+
+// ExpectMatchesGolden compares the response body with the golden file at goldenPath. JSON
+// bodies are normalized (sorted keys, indented) so formatting and key order don't matter;
+// other bodies compare as exact text. Run with -update-golden (see UpdateGoldenFlag),
+// UPDATE_GOLDEN=1 or SetUpdateGolden(true) to write the actual body to the file instead, creating it if needed.
+func ExpectMatchesGolden(resp Response, goldenPath string, opts ...GoldenOption) {
+	if IsDryRun() {
+		return
+	}
+	var cfg goldenConfig
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&cfg)
+		}
+	}
+
+	actual, actualJSON, isJSON := normalizeGolden(resp.Body, cfg.ignorePaths)
+
+	if updateGolden() {
+		if err := os.MkdirAll(filepath.Dir(goldenPath), 0o755); err != nil {
+			Fail("ExpectMatchesGolden failed to create directory for %s: %v", goldenPath, err)
+		}
+		if err := os.WriteFile(goldenPath, []byte(actual), 0o644); err != nil {
+			Fail("ExpectMatchesGolden failed to write %s: %v", goldenPath, err)
+		}
+		Log(LogTypeExpect, fmt.Sprintf("Golden file %s updated", goldenPath), actual)
+		return
+	}
+
+	data, err := os.ReadFile(goldenPath)
+	if os.IsNotExist(err) {
+		Fail("ExpectMatchesGolden failed: golden file %s does not exist (run with UPDATE_GOLDEN=1 to create it)", goldenPath)
+	}
+	if err != nil {
+		Fail("ExpectMatchesGolden failed to read %s: %v", goldenPath, err)
+	}
+
+	if isJSON {
+		var golden interface{}
+		if err := json.Unmarshal(data, &golden); err != nil {
+			Fail("ExpectMatchesGolden failed: golden file %s is not valid JSON but the response is: %v", goldenPath, err)
+		}
+		maskGoldenPaths(golden, cfg.ignorePaths)
+		if d := diff(golden, actualJSON); len(d) > 0 {
+			Fail("ExpectMatchesGolden failed for %s (golden != actual):\n%s", goldenPath, strings.Join(d, "\n"))
+		}
+	} else if string(data) != actual {
+		Fail("ExpectMatchesGolden failed for %s:\nExpected: %s\nGot:      %s", goldenPath, data, actual)
+	}
+
+	Logf(LogTypeExpect, "Response matches golden file %s - PASSED", goldenPath)
+}
+
+// normalizeGolden returns the text stored in a golden file for body. For JSON bodies it
+// also returns the decoded, masked value.
+func normalizeGolden(body string, ignorePaths []string) (string, interface{}, bool) {
+	var v interface{}
+	if err := json.Unmarshal([]byte(body), &v); err != nil {
+		return body, nil, false
+	}
+	maskGoldenPaths(v, ignorePaths)
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return body, nil, false
+	}
+	return buf.String(), v, true
+}
+
+// maskGoldenPaths sets every value at the given paths to goldenIgnored.
+func maskGoldenPaths(data interface{}, paths []string) {
+	for _, path := range paths {
+		_, concrete, err := getValuesByPath(data, path)
+		if err != nil {
+			continue
+		}
+		for _, p := range concrete {
+			setValueByPath(data, p, goldenIgnored)
+		}
+	}
+}
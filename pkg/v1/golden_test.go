@@ -0,0 +1,79 @@
+package v1
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Registered the way a test package opts in to "go test -update-golden"
+var _ = flag.Bool(UpdateGoldenFlag, false, "rewrite golden files")
+
+func TestExpectMatchesGolden(t *testing.T) {
+	defer SetUpdateGolden(false)
+	SetUpdateGolden(false)
+
+	ignore := WithGoldenIgnorePaths("id", "createdAt", "sessions[*].token")
+	resp := Response{Body: `{"name":"alice","id":"u-91f3","createdAt":"2026-10-16T10:00:00Z",` +
+		`"roles":["admin","viewer"],"sessions":[{"token":"abc","device":"laptop"}]}`}
+	ExpectMatchesGolden(resp, "testdata/user.golden.json", ignore)
+
+	// Volatile fields differ, key order and formatting differ: still matches
+	other := Response{Body: `{"sessions":[{"device":"laptop","token":"zzz"}],"roles":["admin","viewer"],
+		"createdAt":"2030-01-01T00:00:00Z","id":"u-0000","name":"alice"}`}
+	ExpectMatchesGolden(other, "testdata/user.golden.json", ignore)
+
+	drifted := Response{Body: `{"name":"alice","id":"u-1","createdAt":"x","roles":["admin"],"sessions":[{"token":"abc","device":"laptop"}]}`}
+	ExpectFailure(func() { ExpectMatchesGolden(drifted, "testdata/user.golden.json", ignore) })
+	ExpectFailure(func() { ExpectMatchesGolden(resp, "testdata/user.golden.json") })
+	ExpectFailure(func() { ExpectMatchesGolden(resp, filepath.Join(t.TempDir(), "none.json")) })
+
+	// Update run writes the normalized body, which then matches
+	path := filepath.Join(t.TempDir(), "golden", "user.json")
+	SetUpdateGolden(true)
+	ExpectMatchesGolden(drifted, path, ignore)
+	SetUpdateGolden(false)
+	ExpectMatchesGolden(drifted, path, ignore)
+	ExpectFailure(func() { ExpectMatchesGolden(resp, path, ignore) })
+
+	written, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("golden file not written: %v", err)
+	}
+	want := "{\n  \"createdAt\": \"<ignored>\",\n  \"id\": \"<ignored>\",\n  \"name\": \"alice\",\n  \"roles\": [\n    \"admin\"\n  ],\n" +
+		"  \"sessions\": [\n    {\n      \"device\": \"laptop\",\n      \"token\": \"<ignored>\"\n    }\n  ]\n}\n"
+	if string(written) != want {
+		t.Errorf("unexpected golden file content:\n%s", written)
+	}
+
+	// Non-JSON bodies compare as text
+	textPath := filepath.Join(t.TempDir(), "hello.txt")
+	SetUpdateGolden(true)
+	ExpectMatchesGolden(Response{Body: "hello"}, textPath)
+	SetUpdateGolden(false)
+	ExpectMatchesGolden(Response{Body: "hello"}, textPath)
+	ExpectFailure(func() { ExpectMatchesGolden(Response{Body: "hello!"}, textPath) })
+
+	// Without SetUpdateGolden the UPDATE_GOLDEN environment variable decides
+	updateGoldenMu.Lock()
+	updateGoldenSet = nil
+	updateGoldenMu.Unlock()
+	envPath := filepath.Join(t.TempDir(), "env.txt")
+	t.Setenv(UpdateGoldenEnv, "1")
+	ExpectMatchesGolden(Response{Body: "from env"}, envPath)
+	t.Setenv(UpdateGoldenEnv, "")
+	ExpectMatchesGolden(Response{Body: "from env"}, envPath)
+	ExpectFailure(func() { ExpectMatchesGolden(Response{Body: "changed"}, envPath) })
+
+	// The -update-golden flag, when the test binary registers it, also enables rewriting
+	flagPath := filepath.Join(t.TempDir(), "flag.txt")
+	if err := flag.Set(UpdateGoldenFlag, "true"); err != nil {
+		t.Fatalf("flag.Set: %v", err)
+	}
+	defer flag.Set(UpdateGoldenFlag, "false")
+	ExpectMatchesGolden(Response{Body: "from flag"}, flagPath)
+	flag.Set(UpdateGoldenFlag, "false")
+	ExpectMatchesGolden(Response{Body: "from flag"}, flagPath)
+	ExpectFailure(func() { ExpectMatchesGolden(Response{Body: "changed"}, flagPath) })
+}
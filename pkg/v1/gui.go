@@ -19,6 +19,19 @@ import (
 	"fyne.io/fyne/v2/widget"
 )
 
+// setStatusText shows a stage or action status, green when passed and red when failed.
+func setStatusText(statusText *canvas.Text, st string) {
+	statusText.Text = st
+	if st == StageStatusPassed {
+		statusText.Color = color.NRGBA{R: 0, G: 180, B: 0, A: 255}
+	} else if strings.HasPrefix(st, StageStatusFailed) {
+		statusText.Color = color.NRGBA{R: 200, G: 0, B: 0, A: 255}
+	} else {
+		statusText.Color = theme.ForegroundColor()
+	}
+	statusText.Refresh()
+}
+
 // RunGUI starts the local desktop GUI.
 func RunGUI(t *Tester) {
 	myApp := app.New()
@@ -28,18 +41,18 @@ func RunGUI(t *Tester) {
 	var (
 		logs   []LogEntry
 		logsMu sync.Mutex
-
-		// Map StageName -> Status String
-		stageStatus = make(map[string]string)
-		statusMu    sync.Mutex
 	)
 
-	// Initialize status
-	for _, s := range t.Stages {
-		stageStatus[s.Name] = "Not Run"
-	}
-
 	// --- Left Pane: Stage & Action Tree ---
+	// Stage names may contain ":", so a node is a stage when its ID names one
+	isStage := func(uid string) bool {
+		for _, s := range t.Stages {
+			if s.Name == uid {
+				return true
+			}
+		}
+		return false
+	}
 	var leftTree *widget.Tree
 	leftTree = widget.NewTree(
 		func(uid widget.TreeNodeID) []widget.TreeNodeID {
@@ -57,7 +70,7 @@ func RunGUI(t *Tester) {
 			if len(actions) > 0 {
 				ids := make([]string, len(actions))
 				for i := range actions {
-					ids[i] = fmt.Sprintf("%s:%d", uid, i)
+					ids[i] = ActionUID(uid, i)
 				}
 				return ids
 			}
@@ -68,17 +81,14 @@ func RunGUI(t *Tester) {
 			if uid == "" {
 				return true
 			}
-			if strings.Contains(uid, ":") {
-				return false
-			} // Action
-			return true // Stage
+			return isStage(uid) // Stage, otherwise Action
 		},
 		func(branch bool) fyne.CanvasObject {
 			// Template for Node
 			return container.NewHBox(
 				widget.NewLabel("Template"), // Name/Summary
 				layout.NewSpacer(),
-				canvas.NewText("Status", theme.ForegroundColor()), // Status (Stage only)
+				canvas.NewText("Status", theme.ForegroundColor()), // Status
 				widget.NewButton("Run", func() {}),                // Run Button
 			)
 		},
@@ -89,43 +99,25 @@ func RunGUI(t *Tester) {
 			btn := box.Objects[3].(*widget.Button)
 
 			// Determine if Stage or Action
-			if strings.Contains(uid, ":") {
+			if stageName, idx, ok := ParseActionUID(uid); ok && !isStage(uid) {
 				// Action: "StageName:Index"
-				parts := strings.SplitN(uid, ":", 2)
-				stageName := parts[0]
-				idx, _ := strconv.Atoi(parts[1])
-
 				actions := GetStageActions(stageName)
 				if idx < len(actions) {
 					action := actions[idx]
 					label.SetText("  " + action.Summary) // Indent
 					label.TextStyle = fyne.TextStyle{Italic: true}
-					statusText.Text = "" // No status for actions
-					statusText.Refresh()
+					setStatusText(statusText, t.ActionStatus(uid))
 
 					btn.SetText("Run")
 					btn.OnTapped = func() {
 						go func() {
-							defer func() {
-								if r := recover(); r != nil {
-									var errMsg string
-									if te, ok := r.(TestError); ok {
-										errMsg = te.Message
-									} else {
-										errMsg = fmt.Sprintf("%v", r)
-									}
-									Log(LogTypeInfo, "Manual Run FAILED: "+action.Summary, errMsg)
-									fyne.Do(func() {
-										dialog.ShowError(fmt.Errorf("Execution Failed: %s", errMsg), myWindow)
-									})
+							err := t.RunAction(uid)
+							fyne.Do(func() {
+								leftTree.RefreshItem(uid)
+								if err != nil {
+									dialog.ShowError(fmt.Errorf("Execution Failed: %s", err), myWindow)
 								}
-							}()
-
-							// Run individual action
-							// We don't update stage status for individual run
-							Log(LogTypeInfo, "Manual Run: "+action.Summary, "")
-							action.Func()
-							Log(LogTypeInfo, "Manual Run PASSED: "+action.Summary, "")
+							})
 						}()
 					}
 					btn.Show()
@@ -136,36 +128,14 @@ func RunGUI(t *Tester) {
 				label.SetText(stageName)
 				label.TextStyle = fyne.TextStyle{Bold: true}
 
-				statusMu.Lock()
-				st := stageStatus[stageName]
-				statusMu.Unlock()
-
-				statusText.Text = st
-				if st == "PASSED" {
-					statusText.Color = color.NRGBA{R: 0, G: 180, B: 0, A: 255}
-				} else if strings.HasPrefix(st, "FAILED") {
-					statusText.Color = color.NRGBA{R: 200, G: 0, B: 0, A: 255}
-				} else {
-					statusText.Color = theme.ForegroundColor()
-				}
-				statusText.Refresh()
+				setStatusText(statusText, t.StageStatus(stageName))
 
 				btn.SetText("Run Stage")
 				btn.OnTapped = func() {
-					statusMu.Lock()
-					stageStatus[stageName] = "Running..."
-					statusMu.Unlock()
-					leftTree.RefreshItem(uid) // Refresh to show "Running..."
-
 					go func() {
-						err := t.RunStageByName(stageName)
-						statusMu.Lock()
-						if err != nil {
-							stageStatus[stageName] = "FAILED"
-						} else {
-							stageStatus[stageName] = "PASSED"
-						}
-						statusMu.Unlock()
+						// RunStageByName records "Running..." then the outcome on the Tester;
+						// the action update handler refreshes the tree when the stage starts.
+						t.RunStageByName(stageName)
 						// Refresh GUI
 						// Use fyne.Do or just refresh safely
 						// RefreshItem is thread-safe? Documentation says "must be called from main thread" usually.
@@ -319,7 +289,7 @@ func RunGUI(t *Tester) {
 	var lastClickTime time.Time
 
 	rightTree.OnSelected = func(uid widget.TreeNodeID) {
-		// Double Click Logic
+		// Double Click Logic: the interval is wall-clock UI behavior, so it ignores SetClock
 		now := time.Now()
 		if uid != lastClickID || now.Sub(lastClickTime) > 500*time.Millisecond {
 			lastClickID = uid
@@ -0,0 +1,528 @@
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// supportedSchemaKeywords are the JSON Schema keywords validateJSONSchema enforces.
+var supportedSchemaKeywords = map[string]bool{
+	"type": true, "enum": true, "const": true, "format": true,
+	"$ref": true, "$defs": true, "definitions": true,
+	"properties": true, "patternProperties": true, "additionalProperties": true, "propertyNames": true,
+	"required": true, "minProperties": true, "maxProperties": true,
+	"dependentRequired": true, "dependentSchemas": true, "dependencies": true,
+	"items": true, "prefixItems": true, "additionalItems": true, "minItems": true, "maxItems": true,
+	"uniqueItems": true, "contains": true, "minContains": true, "maxContains": true,
+	"minLength": true, "maxLength": true, "pattern": true,
+	"minimum": true, "maximum": true, "exclusiveMinimum": true, "exclusiveMaximum": true, "multipleOf": true,
+	"allOf": true, "anyOf": true, "oneOf": true, "not": true, "if": true, "then": true, "else": true,
+}
+
+// annotationSchemaKeywords carry no constraint, so they are accepted and ignored.
+var annotationSchemaKeywords = map[string]bool{
+	"$schema": true, "$id": true, "$comment": true, "title": true, "description": true,
+	"default": true, "examples": true, "readOnly": true, "writeOnly": true, "deprecated": true,
+}
+
+// subschemaKeywords hold a single subschema.
+var subschemaKeywords = map[string]bool{
+	"additionalProperties": true, "propertyNames": true, "additionalItems": true, "contains": true,
+	"not": true, "if": true, "then": true, "else": true,
+}
+
+// schemaMapKeywords hold an object whose values are subschemas.
+var schemaMapKeywords = map[string]bool{
+	"properties": true, "patternProperties": true, "$defs": true, "definitions": true,
+	"dependentSchemas": true, "dependencies": true,
+}
+
+// schemaListKeywords hold an array of subschemas.
+var schemaListKeywords = map[string]bool{
+	"allOf": true, "anyOf": true, "oneOf": true, "prefixItems": true,
+}
+
+// schemaFormats are the "format" values validateJSONSchema checks; other formats are unsupported.
+var schemaFormats = map[string]func(string) bool{
+	"date-time": func(s string) bool { _, err := time.Parse(time.RFC3339, s); return err == nil },
+	"date":      func(s string) bool { _, err := time.Parse("2006-01-02", s); return err == nil },
+	"time":      func(s string) bool { _, err := time.Parse("15:04:05Z07:00", s); return err == nil },
+	"email": func(s string) bool {
+		addr, err := mail.ParseAddress(s)
+		return err == nil && addr.Address == s
+	},
+	"uuid": regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`).MatchString,
+	"uri": func(s string) bool {
+		u, err := url.Parse(s)
+		return err == nil && u.IsAbs()
+	},
+	"hostname": regexp.MustCompile(`^(?i:[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?)(\.(?i:[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?))*$`).MatchString,
+	"ipv4": func(s string) bool {
+		ip := net.ParseIP(s)
+		return ip != nil && ip.To4() != nil && !strings.Contains(s, ":")
+	},
+	"ipv6": func(s string) bool { return net.ParseIP(s) != nil && strings.Contains(s, ":") },
+}
+
+// unsupportedSchemaKeywords lists the keywords of schema and its subschemas that
+// validateJSONSchema does not enforce (unevaluatedProperties, remote $ref, unknown
+// formats, ...), with their schema path. A schema using any of them could pass values
+// it is meant to reject.
+func unsupportedSchemaKeywords(schema interface{}, path string) []string {
+	s, ok := schema.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	var out []string
+	for _, k := range sortedKeys(s) {
+		v := s[k]
+		switch {
+		case annotationSchemaKeywords[k]:
+		case !supportedSchemaKeywords[k]:
+			out = append(out, fmt.Sprintf("%s/%s", path, k))
+		case k == "$ref":
+			if ref, _ := v.(string); !strings.HasPrefix(ref, "#") {
+				out = append(out, fmt.Sprintf("%s/$ref (only local \"#/...\" references are supported)", path))
+			}
+		case k == "format":
+			if _, known := schemaFormats[fmt.Sprintf("%v", v)]; !known {
+				out = append(out, fmt.Sprintf("%s/format (unknown format %q)", path, fmt.Sprintf("%v", v)))
+			}
+		case k == "items":
+			if tuple, isTuple := v.([]interface{}); isTuple {
+				for i, sub := range tuple {
+					out = append(out, unsupportedSchemaKeywords(sub, fmt.Sprintf("%s/items/%d", path, i))...)
+				}
+			} else {
+				out = append(out, unsupportedSchemaKeywords(v, path+"/items")...)
+			}
+		case subschemaKeywords[k]:
+			out = append(out, unsupportedSchemaKeywords(v, path+"/"+k)...)
+		case schemaMapKeywords[k]:
+			subs, _ := v.(map[string]interface{})
+			for _, name := range sortedKeys(subs) {
+				out = append(out, unsupportedSchemaKeywords(subs[name], path+"/"+k+"/"+name)...)
+			}
+		case schemaListKeywords[k]:
+			subs, _ := v.([]interface{})
+			for i, sub := range subs {
+				out = append(out, unsupportedSchemaKeywords(sub, fmt.Sprintf("%s/%s/%d", path, k, i))...)
+			}
+		}
+	}
+	return out
+}
+
+// validateJSONSchema checks value against a JSON Schema (decoded with encoding/json) and
+// returns one message per violation, prefixed with the JSON path of the offending value.
+// It enforces supportedSchemaKeywords only; callers reject other keywords first with
+// unsupportedSchemaKeywords. $ref resolves JSON pointers into schema itself and, as in
+// draft 2020-12, applies alongside its sibling keywords.
+func validateJSONSchema(schema interface{}, value interface{}, path string) []string {
+	v := &schemaValidator{root: schema, activeRefs: make(map[string]bool)}
+	return v.validate(schema, value, path)
+}
+
+// schemaValidator carries the root schema for $ref resolution and the references being
+// expanded, so a reference cycle that never descends into the value is reported
+// instead of recursing forever.
+type schemaValidator struct {
+	root       interface{}
+	activeRefs map[string]bool
+}
+
+func (sv *schemaValidator) validate(schema interface{}, value interface{}, path string) []string {
+	switch s := schema.(type) {
+	case bool:
+		if !s {
+			return []string{fmt.Sprintf("%s: not allowed by schema", path)}
+		}
+		return nil
+	case map[string]interface{}:
+		return sv.validateObject(s, value, path)
+	default:
+		return []string{fmt.Sprintf("%s: invalid schema %v", path, schema)}
+	}
+}
+
+func (sv *schemaValidator) validateObject(s map[string]interface{}, value interface{}, path string) []string {
+	var errs []string
+
+	if ref, ok := s["$ref"].(string); ok {
+		errs = append(errs, sv.validateRef(ref, value, path)...)
+	}
+
+	if t, ok := s["type"]; ok {
+		var types []string
+		switch tv := t.(type) {
+		case string:
+			types = []string{tv}
+		case []interface{}:
+			for _, x := range tv {
+				types = append(types, fmt.Sprintf("%v", x))
+			}
+		}
+		matched := false
+		for _, typ := range types {
+			if jsonTypeMatches(typ, value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			// Further keywords would only report noise for a value of the wrong type
+			return append(errs, fmt.Sprintf("%s: expected type %s, got %s", path, strings.Join(types, " or "), jsonTypeName(value)))
+		}
+	}
+
+	if enum, ok := s["enum"].([]interface{}); ok {
+		found := false
+		for _, e := range enum {
+			if reflect.DeepEqual(e, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			errs = append(errs, fmt.Sprintf("%s: value %v is not one of %v", path, value, enum))
+		}
+	}
+	if c, ok := s["const"]; ok && !reflect.DeepEqual(c, value) {
+		errs = append(errs, fmt.Sprintf("%s: expected constant %v, got %v", path, c, value))
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		errs = append(errs, sv.validateProperties(s, v, path)...)
+	case []interface{}:
+		errs = append(errs, sv.validateItems(s, v, path)...)
+	case string:
+		length := len([]rune(v))
+		if n, ok := s["minLength"].(float64); ok && float64(length) < n {
+			errs = append(errs, fmt.Sprintf("%s: expected length >= %v, got %d", path, n, length))
+		}
+		if n, ok := s["maxLength"].(float64); ok && float64(length) > n {
+			errs = append(errs, fmt.Sprintf("%s: expected length <= %v, got %d", path, n, length))
+		}
+		if p, ok := s["pattern"].(string); ok {
+			re, err := regexp.Compile(p)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: invalid pattern %q: %v", path, p, err))
+			} else if !re.MatchString(v) {
+				errs = append(errs, fmt.Sprintf("%s: %q does not match pattern %q", path, v, p))
+			}
+		}
+		if f, ok := s["format"].(string); ok {
+			if check, known := schemaFormats[f]; known && !check(v) {
+				errs = append(errs, fmt.Sprintf("%s: %q is not a valid %s", path, v, f))
+			}
+		}
+	case float64:
+		if n, ok := s["minimum"].(float64); ok && v < n {
+			errs = append(errs, fmt.Sprintf("%s: expected >= %v, got %v", path, n, v))
+		}
+		if n, ok := s["maximum"].(float64); ok && v > n {
+			errs = append(errs, fmt.Sprintf("%s: expected <= %v, got %v", path, n, v))
+		}
+		if n, ok := s["exclusiveMinimum"].(float64); ok && v <= n {
+			errs = append(errs, fmt.Sprintf("%s: expected > %v, got %v", path, n, v))
+		}
+		if n, ok := s["exclusiveMaximum"].(float64); ok && v >= n {
+			errs = append(errs, fmt.Sprintf("%s: expected < %v, got %v", path, n, v))
+		}
+		if n, ok := s["multipleOf"].(float64); ok && n > 0 {
+			// Tolerate float rounding, e.g. 0.3 is a multiple of 0.1
+			if q := v / n; math.Abs(q-math.Round(q)) > 1e-9 {
+				errs = append(errs, fmt.Sprintf("%s: expected a multiple of %v, got %v", path, n, v))
+			}
+		}
+	}
+
+	if all, ok := s["allOf"].([]interface{}); ok {
+		for _, sub := range all {
+			errs = append(errs, sv.validate(sub, value, path)...)
+		}
+	}
+	if anyOf, ok := s["anyOf"].([]interface{}); ok {
+		if sv.countMatches(anyOf, value, path) == 0 {
+			errs = append(errs, fmt.Sprintf("%s: does not match any schema in anyOf", path))
+		}
+	}
+	if oneOf, ok := s["oneOf"].([]interface{}); ok {
+		if n := sv.countMatches(oneOf, value, path); n != 1 {
+			errs = append(errs, fmt.Sprintf("%s: expected to match exactly one schema in oneOf, matched %d", path, n))
+		}
+	}
+	if not, ok := s["not"]; ok && len(sv.validate(not, value, path)) == 0 {
+		errs = append(errs, fmt.Sprintf("%s: must not match the schema in not", path))
+	}
+	if cond, ok := s["if"]; ok {
+		branch := "else"
+		if len(sv.validate(cond, value, path)) == 0 {
+			branch = "then"
+		}
+		if sub, ok := s[branch]; ok {
+			errs = append(errs, sv.validate(sub, value, path)...)
+		}
+	}
+
+	return errs
+}
+
+// validateRef validates value against the subschema the local reference ref points to.
+func (sv *schemaValidator) validateRef(ref string, value interface{}, path string) []string {
+	target, err := resolveSchemaPointer(sv.root, ref)
+	if err != nil {
+		return []string{fmt.Sprintf("%s: %v", path, err)}
+	}
+	key := ref + "\x00" + path
+	if sv.activeRefs[key] {
+		return []string{fmt.Sprintf("%s: $ref %q refers back to itself without descending into the value", path, ref)}
+	}
+	sv.activeRefs[key] = true
+	defer delete(sv.activeRefs, key)
+	return sv.validate(target, value, path)
+}
+
+func (sv *schemaValidator) validateProperties(s map[string]interface{}, obj map[string]interface{}, path string) []string {
+	var errs []string
+	if required, ok := s["required"].([]interface{}); ok {
+		for _, r := range required {
+			name := fmt.Sprintf("%v", r)
+			if _, ok := obj[name]; !ok {
+				errs = append(errs, fmt.Sprintf("%s: missing required property %q", path, name))
+			}
+		}
+	}
+	if n, ok := s["minProperties"].(float64); ok && float64(len(obj)) < n {
+		errs = append(errs, fmt.Sprintf("%s: expected at least %v properties, got %d", path, n, len(obj)))
+	}
+	if n, ok := s["maxProperties"].(float64); ok && float64(len(obj)) > n {
+		errs = append(errs, fmt.Sprintf("%s: expected at most %v properties, got %d", path, n, len(obj)))
+	}
+
+	// dependencies is the draft-07 spelling of both dependentRequired and dependentSchemas
+	dependencies := map[string]interface{}{}
+	for _, k := range []string{"dependencies", "dependentRequired", "dependentSchemas"} {
+		if deps, ok := s[k].(map[string]interface{}); ok {
+			for name, dep := range deps {
+				dependencies[name] = dep
+			}
+		}
+	}
+	for _, name := range sortedKeys(dependencies) {
+		if _, present := obj[name]; !present {
+			continue
+		}
+		if required, isList := dependencies[name].([]interface{}); isList {
+			for _, r := range required {
+				if _, ok := obj[fmt.Sprintf("%v", r)]; !ok {
+					errs = append(errs, fmt.Sprintf("%s: property %q requires property \"%v\"", path, name, r))
+				}
+			}
+		} else {
+			errs = append(errs, sv.validate(dependencies[name], obj, path)...)
+		}
+	}
+
+	props, _ := s["properties"].(map[string]interface{})
+	patternProps, _ := s["patternProperties"].(map[string]interface{})
+	for _, k := range sortedKeys(obj) {
+		childPath := path + "." + k
+		if names, ok := s["propertyNames"]; ok {
+			errs = append(errs, sv.validate(names, k, childPath+" (property name)")...)
+		}
+		matched := false
+		if sub, ok := props[k]; ok {
+			matched = true
+			errs = append(errs, sv.validate(sub, obj[k], childPath)...)
+		}
+		for _, p := range sortedKeys(patternProps) {
+			re, err := regexp.Compile(p)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: invalid patternProperties pattern %q: %v", path, p, err))
+				continue
+			}
+			if re.MatchString(k) {
+				matched = true
+				errs = append(errs, sv.validate(patternProps[p], obj[k], childPath)...)
+			}
+		}
+		if matched {
+			continue
+		}
+		if additional, ok := s["additionalProperties"]; ok {
+			if b, isBool := additional.(bool); isBool && !b {
+				errs = append(errs, fmt.Sprintf("%s: additional property %q is not allowed", path, k))
+			} else {
+				errs = append(errs, sv.validate(additional, obj[k], childPath)...)
+			}
+		}
+	}
+	return errs
+}
+
+// validateItems applies the array keywords. The leading items are checked against
+// prefixItems (or the draft-07 array form of items), the rest against items (or
+// additionalItems).
+func (sv *schemaValidator) validateItems(s map[string]interface{}, arr []interface{}, path string) []string {
+	var errs []string
+	if n, ok := s["minItems"].(float64); ok && float64(len(arr)) < n {
+		errs = append(errs, fmt.Sprintf("%s: expected at least %v items, got %d", path, n, len(arr)))
+	}
+	if n, ok := s["maxItems"].(float64); ok && float64(len(arr)) > n {
+		errs = append(errs, fmt.Sprintf("%s: expected at most %v items, got %d", path, n, len(arr)))
+	}
+
+	prefix, _ := s["prefixItems"].([]interface{})
+	rest, hasRest := s["items"]
+	if tuple, isTuple := rest.([]interface{}); isTuple {
+		prefix = tuple
+		rest, hasRest = s["additionalItems"]
+	}
+	for i, item := range arr {
+		itemPath := fmt.Sprintf("%s[%d]", path, i)
+		switch {
+		case i < len(prefix):
+			errs = append(errs, sv.validate(prefix[i], item, itemPath)...)
+		case hasRest:
+			if b, isBool := rest.(bool); isBool && !b {
+				errs = append(errs, fmt.Sprintf("%s: expected at most %d items, got %d", path, len(prefix), len(arr)))
+				hasRest = false
+			} else {
+				errs = append(errs, sv.validate(rest, item, itemPath)...)
+			}
+		}
+	}
+
+	if unique, _ := s["uniqueItems"].(bool); unique {
+		for i := range arr {
+			for j := i + 1; j < len(arr); j++ {
+				if reflect.DeepEqual(arr[i], arr[j]) {
+					errs = append(errs, fmt.Sprintf("%s: items %d and %d are equal, expected unique items", path, i, j))
+				}
+			}
+		}
+	}
+
+	if contains, ok := s["contains"]; ok {
+		n := 0
+		for i, item := range arr {
+			if len(sv.validate(contains, item, fmt.Sprintf("%s[%d]", path, i))) == 0 {
+				n++
+			}
+		}
+		minContains := 1.0
+		if m, ok := s["minContains"].(float64); ok {
+			minContains = m
+		}
+		if float64(n) < minContains {
+			errs = append(errs, fmt.Sprintf("%s: expected at least %v items matching contains, got %d", path, minContains, n))
+		}
+		if m, ok := s["maxContains"].(float64); ok && float64(n) > m {
+			errs = append(errs, fmt.Sprintf("%s: expected at most %v items matching contains, got %d", path, m, n))
+		}
+	}
+	return errs
+}
+
+func (sv *schemaValidator) countMatches(schemas []interface{}, value interface{}, path string) int {
+	n := 0
+	for _, sub := range schemas {
+		if len(sv.validate(sub, value, path)) == 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// resolveSchemaPointer follows a local reference such as "#/$defs/address" into root.
+func resolveSchemaPointer(root interface{}, ref string) (interface{}, error) {
+	if !strings.HasPrefix(ref, "#") {
+		return nil, fmt.Errorf("$ref %q is not a local reference", ref)
+	}
+	pointer, err := url.PathUnescape(strings.TrimPrefix(ref, "#"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid $ref %q: %v", ref, err)
+	}
+	current := root
+	if pointer == "" {
+		return current, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("$ref %q is not a JSON pointer", ref)
+	}
+	for _, token := range strings.Split(pointer[1:], "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		switch node := current.(type) {
+		case map[string]interface{}:
+			next, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("$ref %q does not resolve", ref)
+			}
+			current = next
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, fmt.Errorf("$ref %q does not resolve", ref)
+			}
+			current = node[i]
+		default:
+			return nil, fmt.Errorf("$ref %q does not resolve", ref)
+		}
+	}
+	return current, nil
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// jsonTypeMatches reports whether a decoded JSON value has the given JSON Schema type.
+func jsonTypeMatches(typ string, value interface{}) bool {
+	switch typ {
+	case "integer":
+		f, ok := value.(float64)
+		return ok && f == float64(int64(f))
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	default:
+		return jsonTypeName(value) == typ
+	}
+}
+
+func jsonTypeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64, json.Number:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
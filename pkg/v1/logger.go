@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"sync"
+	"time"
 )
 
 // LogType defines the category of the log.
@@ -26,6 +27,10 @@ type LogEntry struct {
 	Type    LogType
 	Summary string
 	Detail  string
+	// Stage is the name of the stage that was running when the entry was logged.
+	Stage string
+	// Time is when the entry was logged, from the package Clock.
+	Time time.Time
 }
 
 // LogHandler is a function that handles log entries (e.g., UI updater).
@@ -43,22 +48,47 @@ func RegisterLogHandler(h LogHandler) {
 	logHandlers = append(logHandlers, h)
 }
 
-// Log records a log entry and notifies handlers.
+// Log records a log entry and notifies handlers. While a stage runs, the entry goes to
+// the running Tester (its stage logs, and its Logger when set); entries from a stage that
+// timed out but is still running go to that stage.
 func Log(t LogType, summary string, detail string) {
-	// 1. Print to standard console for debugging/history
-	if detail != "" {
-		log.Printf("[%s] %s - %s", t, summary, detail)
-	} else {
-		log.Printf("[%s] %s", t, summary)
-	}
+	actionMu.Lock()
+	running, _ := runningStageLocked()
+	actionMu.Unlock()
 
-	// 2. Notify handlers (UI)
-	entry := LogEntry{
+	dispatchLog(running.tester, LogEntry{
 		Type:    t,
 		Summary: summary,
 		Detail:  detail,
+		Stage:   running.stage,
+		Time:    clockNow(),
+	})
+}
+
+// dispatchLog buffers entry for tester (may be nil) and sends it to the tester's Logger,
+// or to the console and the global handlers when the tester has none.
+func dispatchLog(tester *Tester, entry LogEntry) {
+	// 1. Buffer per stage for the running tester (reporting)
+	if tester != nil && entry.Stage != "" {
+		tester.appendStageLog(entry)
+	}
+
+	// 2. An injected logger replaces the global destinations; like them it is called under logMu
+	if tester != nil && tester.Logger != nil {
+		logMu.Lock()
+		defer logMu.Unlock()
+		tester.Logger(entry)
+		return
+	}
+
+	// 3. Print to standard console for debugging/history
+	if entry.Detail != "" {
+		log.Printf("[%s] %s - %s", entry.Type, entry.Summary, entry.Detail)
+	} else {
+		log.Printf("[%s] %s", entry.Type, entry.Summary)
 	}
 
+	// 4. Notify handlers (UI)
 	logMu.Lock()
 	defer logMu.Unlock()
 	for _, h := range logHandlers {
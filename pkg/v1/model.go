@@ -18,6 +18,22 @@ type Response struct {
 	StatusCode int
 	Body       string
 	Header     map[string]string
+	// Cookies are the cookies set by the response (Set-Cookie headers)
+	Cookies []*http.Cookie
+	// ContentLength is the Content-Length the server sent, or -1 when it sent none (e.g. chunked)
+	ContentLength int64
+	// TransferEncoding lists the transfer encodings the server used, e.g. ["chunked"]
+	TransferEncoding []string
+}
+
+// Cookie returns the response cookie with the given name, or nil if none was set.
+func (r Response) Cookie(name string) *http.Cookie {
+	for _, c := range r.Cookies {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
 }
 
 // NewRequestWrapper creates a wrapper from http.Request.
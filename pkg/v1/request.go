@@ -2,17 +2,55 @@ package v1
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
+	"math"
+	"net"
 	"net/http"
+	neturl "net/url"
+	"os"
 	"reflect"
+	"regexp"
 	"strconv"
 	"strings"
+	"syscall"
+	"time"
 )
 
+// Transport failure classes reported by SendRESTRequest.
+const (
+	RequestErrorDNS               = "DNS lookup failed"
+	RequestErrorConnectionRefused = "connection refused"
+	RequestErrorConnectionReset   = "connection reset"
+	RequestErrorTimeout           = "timeout"
+	RequestErrorOther             = "transport error"
+)
+
+// classifyRequestError tells DNS failures, refused or reset connections and timeouts apart,
+// so a failed request says why it failed rather than only that it did.
+func classifyRequestError(err error) string {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return RequestErrorDNS
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return RequestErrorConnectionRefused
+	}
+	if errors.Is(err, syscall.ECONNRESET) {
+		return RequestErrorConnectionReset
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return RequestErrorTimeout
+	}
+	return RequestErrorOther
+}
+
 // SendRESTRequest sends an HTTP request with flexible options.
 // Common usage:
 //
@@ -32,42 +70,45 @@ func SendRESTRequest(url string, opts ...RESTRequestOption) Response {
 			opt(&cfg)
 		}
 	}
-
-	RecordAction(fmt.Sprintf("Request: %s %s", cfg.method, url), func() {
-		SendRESTRequest(url, opts...)
-	})
-	if IsDryRun() {
-		return Response{}
-	}
-
-	var bodyReader io.Reader
-	if len(cfg.body) > 0 {
-		bodyReader = bytes.NewReader(cfg.body)
+	if ua := defaultUserAgentValue(); ua != "" && !hasHeader(cfg.headers, "User-Agent") {
+		cfg.headers["User-Agent"] = ua
 	}
 
-	req, err := http.NewRequest(cfg.method, url, bodyReader)
-	if err != nil {
-		Fail("Request build failed: %v", err)
+	if !cfg.noRecord && requestRecordingEnabled() {
+		RecordAction(fmt.Sprintf("Request: %s %s", cfg.method, url), func() {
+			SendRESTRequest(url, opts...)
+		})
 	}
-
-	for k, v := range cfg.headers {
-		req.Header.Set(k, v)
+	if IsDryRun() {
+		return Response{}
 	}
 
-	client := &http.Client{}
-	ignoreSSL := false
-	if cfg.ignoreServerSSL != nil {
-		ignoreSSL = *cfg.ignoreServerSSL
-	} else if strings.HasPrefix(strings.ToLower(url), "https://") {
-		ignoreSSL = true
+	// newRequest builds a fresh request per attempt so a buffered body can be re-sent
+	newRequest := func() *http.Request {
+		var bodyReader io.Reader
+		if cfg.bodyReader != nil {
+			bodyReader = cfg.bodyReader
+		} else if len(cfg.body) > 0 {
+			bodyReader = bytes.NewReader(cfg.body)
+		}
+		req, err := http.NewRequest(cfg.method, url, bodyReader)
+		if err != nil {
+			Fail("Request build failed: %v", err)
+		}
+		for k, v := range cfg.headers {
+			req.Header.Set(k, v)
+		}
+		return req
 	}
+	req := newRequest()
 
-	if ignoreSSL {
-		client.Transport = &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
-	}
+	client := newHTTPClient(&cfg, url)
 
 	requestBody := string(cfg.body)
 	requestPrettyBody := requestBody
+	if cfg.bodyReader != nil {
+		requestPrettyBody = "<streamed body>"
+	}
 	if len(cfg.body) > 0 {
 		var jsonObj interface{}
 		if json.Unmarshal(cfg.body, &jsonObj) == nil {
@@ -89,15 +130,65 @@ func SendRESTRequest(url string, opts ...RESTRequestOption) Response {
 	}
 
 	Log(LogTypeRequest, fmt.Sprintf("Sending %s request to: %s", cfg.method, url), fmt.Sprintf("Body:\n%s\nHeaders:\n%s", requestPrettyBody, strings.Join(reqHeaderLines, "\n")))
-	resp, err := client.Do(req)
+	attempts := 1
+	if cfg.retryAttempts > 1 {
+		switch {
+		case cfg.bodyReader != nil:
+			Logf(LogTypeInfo, "Not retrying %s %s: a streamed body cannot be re-sent", cfg.method, url)
+		case !cfg.retryUnsafe && !idempotentMethods[cfg.method]:
+			Logf(LogTypeInfo, "Not retrying %s %s: method is not idempotent (use WithRetryUnsafe to allow)", cfg.method, url)
+		default:
+			attempts = cfg.retryAttempts
+		}
+	}
+
+	var resp *http.Response
+	var err error
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 {
+			req = newRequest()
+		}
+		resp, err = client.Do(req)
+		if attempt >= attempts || (err == nil && resp.StatusCode < 500) {
+			break
+		}
+		reason := fmt.Sprintf("%v", err)
+		if err == nil {
+			reason = fmt.Sprintf("status %d", resp.StatusCode)
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		Logf(LogTypeRequest, "Attempt %d/%d of %s %s failed (%s); retrying in %v", attempt, attempts, cfg.method, url, reason, cfg.retryDelay)
+		clockSleep(cfg.retryDelay)
+	}
 	if err != nil {
-		Fail("Request failed: %v", err)
+		Fail("Request %s %s failed (%s): %v", cfg.method, url, classifyRequestError(err), err)
 	}
 	defer resp.Body.Close()
 
-	respBody, _ := io.ReadAll(resp.Body)
-
-	prettyBody := string(respBody)
+	var respReader io.Reader = resp.Body
+	if cfg.maxResponseBytes > 0 {
+		// Read one extra byte so we can tell "exactly at the limit" from "over the limit"
+		respReader = io.LimitReader(resp.Body, cfg.maxResponseBytes+1)
+	}
+	var respBody []byte
+	var prettyBody string
+	if cfg.downloadTo != "" {
+		n, err := downloadBody(respReader, cfg.downloadTo)
+		if err != nil {
+			Fail("Failed to save response body from %s to %s: %v", url, cfg.downloadTo, err)
+		}
+		if cfg.maxResponseBytes > 0 && n > cfg.maxResponseBytes {
+			Fail("Response body from %s exceeds the limit of %d bytes", url, cfg.maxResponseBytes)
+		}
+		prettyBody = fmt.Sprintf("<%d bytes saved to %s>", n, cfg.downloadTo)
+	} else {
+		respBody, _ = io.ReadAll(respReader)
+		if cfg.maxResponseBytes > 0 && int64(len(respBody)) > cfg.maxResponseBytes {
+			Fail("Response body from %s exceeds the limit of %d bytes", url, cfg.maxResponseBytes)
+		}
+		prettyBody = string(respBody)
+	}
 	if len(respBody) > 0 {
 		var jsonObj interface{}
 		if json.Unmarshal(respBody, &jsonObj) == nil {
@@ -132,6 +223,10 @@ func SendRESTRequest(url string, opts ...RESTRequestOption) Response {
 		StatusCode: resp.StatusCode,
 		Body:       string(respBody),
 		Header:     header,
+		Cookies:    resp.Cookies(),
+
+		ContentLength:    resp.ContentLength,
+		TransferEncoding: resp.TransferEncoding,
 	}
 }
 
@@ -144,10 +239,76 @@ func SendRequest(url string) Response {
 type RESTRequestOption func(*restRequestConfig)
 
 type restRequestConfig struct {
-	method          string
-	headers         map[string]string
-	body            []byte
-	ignoreServerSSL *bool
+	method            string
+	headers           map[string]string
+	body              []byte
+	ignoreServerSSL   *bool
+	maxResponseBytes  int64
+	noRecord          bool
+	bodyReader        io.Reader
+	noFollowRedirects bool
+	retryAttempts     int
+	retryDelay        time.Duration
+	retryUnsafe       bool
+	downloadTo        string
+	proxyURL          string
+	localAddr         string
+}
+
+// idempotentMethods are the methods WithRetry retries by default: repeating them has the
+// same effect as sending them once, so a retry cannot create duplicates.
+var idempotentMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPut:     true,
+	http.MethodDelete:  true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+}
+
+// recordRequests is the package default for recording requests as actions (guarded by actionMu).
+var recordRequests = true
+
+// SetRequestRecording toggles whether SendRESTRequest records requests as stage actions by default.
+// Useful to keep polling/utility requests out of the action list; WithNoRecord does the same per request.
+func SetRequestRecording(enabled bool) {
+	actionMu.Lock()
+	defer actionMu.Unlock()
+	recordRequests = enabled
+}
+
+func requestRecordingEnabled() bool {
+	actionMu.Lock()
+	defer actionMu.Unlock()
+	return recordRequests
+}
+
+// defaultUserAgent is the User-Agent sent when a request sets none (guarded by actionMu).
+var defaultUserAgent string
+
+// SetDefaultUserAgent sets the User-Agent header sent by SendRESTRequest when the request
+// doesn't set one (WithUserAgent or WithHeader), so all test traffic is identifiable.
+// An empty ua restores Go's default.
+func SetDefaultUserAgent(ua string) {
+	actionMu.Lock()
+	defer actionMu.Unlock()
+	defaultUserAgent = ua
+}
+
+func defaultUserAgentValue() string {
+	actionMu.Lock()
+	defer actionMu.Unlock()
+	return defaultUserAgent
+}
+
+// hasHeader reports whether headers sets key, compared case-insensitively.
+func hasHeader(headers map[string]string, key string) bool {
+	for k := range headers {
+		if strings.EqualFold(k, key) {
+			return true
+		}
+	}
+	return false
 }
 
 // WithMethod sets HTTP method (GET by default).
@@ -181,6 +342,18 @@ func WithHeaders(headers map[string]string) RESTRequestOption {
 	}
 }
 
+// WithUserAgent sets the User-Agent header, overriding SetDefaultUserAgent.
+func WithUserAgent(ua string) RESTRequestOption {
+	return WithHeader("User-Agent", ua)
+}
+
+// setBody sets a buffered body, replacing any body set by an earlier option (including
+// WithBodyReader), so the last body option wins.
+func (c *restRequestConfig) setBody(body []byte) {
+	c.body = body
+	c.bodyReader = nil
+}
+
 // WithJSONBody marshals the given value as JSON and sets it as body.
 // It also sets Content-Type to application/json if not already provided.
 func WithJSONBody(v interface{}) RESTRequestOption {
@@ -192,7 +365,7 @@ func WithJSONBody(v interface{}) RESTRequestOption {
 		if err != nil {
 			Fail("Failed to marshal JSON body: %v", err)
 		}
-		c.body = data
+		c.setBody(data)
 		if _, ok := c.headers["Content-Type"]; !ok {
 			c.headers["Content-Type"] = "application/json"
 		}
@@ -210,7 +383,7 @@ func WithXMLBody(v any) RESTRequestOption {
 		if err != nil {
 			Fail("Failed to marshal XML body: %v", err)
 		}
-		c.body = data
+		c.setBody(data)
 		if _, ok := c.headers["Content-Type"]; !ok {
 			c.headers["Content-Type"] = "application/xml"
 		}
@@ -220,14 +393,27 @@ func WithXMLBody(v any) RESTRequestOption {
 // WithBody sets raw bytes as body (caller can set headers accordingly).
 func WithBody(body []byte) RESTRequestOption {
 	return func(c *restRequestConfig) {
-		c.body = body
+		c.setBody(body)
 	}
 }
 
 // WithBodyString sets body from string.
 func WithBodyString(body string) RESTRequestOption {
 	return func(c *restRequestConfig) {
-		c.body = []byte(body)
+		c.setBody([]byte(body))
+	}
+}
+
+// WithBodyReader streams r as the request body instead of buffering it in memory.
+// The reader is consumed once, so a request using it can't be re-sent (e.g. retried or
+// re-run from the recorded action); use WithBody for bodies that need to be replayable.
+func WithBodyReader(r io.Reader, contentType string) RESTRequestOption {
+	return func(c *restRequestConfig) {
+		c.bodyReader = r
+		c.body = nil
+		if contentType != "" {
+			c.headers["Content-Type"] = contentType
+		}
 	}
 }
 
@@ -238,6 +424,149 @@ func WithIgnoreServerSSL(ignore bool) RESTRequestOption {
 	}
 }
 
+// WithMaxResponseBytes caps how much of the response body is read.
+// The request fails if the body is larger than n bytes. Default is unlimited (n <= 0).
+func WithMaxResponseBytes(n int64) RESTRequestOption {
+	return func(c *restRequestConfig) {
+		c.maxResponseBytes = n
+	}
+}
+
+// WithNoFollowRedirects returns 3xx responses as-is instead of following the Location header.
+func WithNoFollowRedirects() RESTRequestOption {
+	return func(c *restRequestConfig) {
+		c.noFollowRedirects = true
+	}
+}
+
+// WithNoRecord keeps this request out of the recorded stage actions (e.g. polling loops).
+func WithNoRecord() RESTRequestOption {
+	return func(c *restRequestConfig) {
+		c.noRecord = true
+	}
+}
+
+// WithRetry re-sends the request up to attempts times in total, waiting delay between tries,
+// while it fails to connect or returns a 5xx status. Only idempotent methods (GET, HEAD, PUT,
+// DELETE, OPTIONS, TRACE) are retried: a POST or PATCH that failed with a 5xx may still have
+// been applied by the server, and repeating it could create duplicates. Use WithRetryUnsafe
+// to retry those anyway. Requests with a streamed body (WithBodyReader) are never retried.
+func WithRetry(attempts int, delay time.Duration) RESTRequestOption {
+	return func(c *restRequestConfig) {
+		c.retryAttempts = attempts
+		c.retryDelay = delay
+	}
+}
+
+// WithRetryUnsafe lets WithRetry also retry non-idempotent methods such as POST and PATCH.
+// Only use it when the endpoint is known to tolerate duplicates (e.g. it dedupes on an idempotency key).
+func WithRetryUnsafe() RESTRequestOption {
+	return func(c *restRequestConfig) {
+		c.retryUnsafe = true
+	}
+}
+
+// WithProxy sends the request through the proxy at proxyURL, e.g. "http://127.0.0.1:3128"
+// or "socks5://127.0.0.1:1080".
+func WithProxy(proxyURL string) RESTRequestOption {
+	return func(c *restRequestConfig) {
+		c.proxyURL = proxyURL
+	}
+}
+
+// WithLocalAddr makes the request originate from the local address addr ("10.0.0.5" or
+// "10.0.0.5:0"), for services bound to a specific interface on multi-homed runners.
+func WithLocalAddr(addr string) RESTRequestOption {
+	return func(c *restRequestConfig) {
+		c.localAddr = addr
+	}
+}
+
+// resolveLocalAddr parses an IP or IP:port into a TCP address; a missing port means any port.
+func resolveLocalAddr(addr string) *net.TCPAddr {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "0")
+	}
+	tcpAddr, err := net.ResolveTCPAddr("tcp", addr)
+	if err != nil {
+		Fail("Invalid local address %q: %v", addr, err)
+	}
+	return tcpAddr
+}
+
+// newHTTPClient builds the client for a request to url from the TLS, proxy, local address
+// and redirect options of cfg. SSL verification is skipped for https URLs unless
+// WithIgnoreServerSSL(false) is set.
+func newHTTPClient(cfg *restRequestConfig, url string) *http.Client {
+	client := &http.Client{}
+	ignoreSSL := false
+	if cfg.ignoreServerSSL != nil {
+		ignoreSSL = *cfg.ignoreServerSSL
+	} else if strings.HasPrefix(strings.ToLower(url), "https://") {
+		ignoreSSL = true
+	}
+
+	if ignoreSSL || cfg.proxyURL != "" || cfg.localAddr != "" {
+		transport := &http.Transport{}
+		if ignoreSSL {
+			transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		}
+		if cfg.proxyURL != "" {
+			proxy, err := neturl.Parse(cfg.proxyURL)
+			if err != nil {
+				Fail("Invalid proxy URL %q: %v", cfg.proxyURL, err)
+			}
+			transport.Proxy = http.ProxyURL(proxy)
+		}
+		if cfg.localAddr != "" {
+			transport.DialContext = (&net.Dialer{LocalAddr: resolveLocalAddr(cfg.localAddr)}).DialContext
+		}
+		client.Transport = transport
+	}
+	if cfg.noFollowRedirects {
+		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
+	return client
+}
+
+// WithDownloadTo streams the response body straight into the file at path instead of
+// buffering it, for large files and reports; the returned Response has an empty Body.
+// The file is created or truncated. WithMaxResponseBytes still applies.
+func WithDownloadTo(path string) RESTRequestOption {
+	return func(c *restRequestConfig) {
+		c.downloadTo = path
+	}
+}
+
+// downloadBody copies r into a new file at path and returns the number of bytes written.
+func downloadBody(r io.Reader, path string) (int64, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return n, err
+}
+
+// SaveResponseBody writes the response body to the file at path (created or truncated),
+// e.g. to keep a downloaded report for later assertions.
+func SaveResponseBody(resp Response, path string) error {
+	RecordAction(fmt.Sprintf("Save response body: %s", path), func() { SaveResponseBody(resp, path) })
+	if IsDryRun() {
+		return nil
+	}
+	if err := os.WriteFile(path, []byte(resp.Body), 0o644); err != nil {
+		return err
+	}
+	Logf(LogTypeInfo, "Saved %d bytes of response body to %s", len(resp.Body), path)
+	return nil
+}
+
 // ExpectStatusCode asserts that the response status code matches the expected code.
 func ExpectStatusCode(resp Response, expected int) {
 	if IsDryRun() {
@@ -261,6 +590,151 @@ func ExpectHeader(resp Response, key, value string) {
 	Logf(LogTypeExpect, "Header '%s' == '%s' - PASSED", key, value)
 }
 
+// ExpectCookie asserts that the response set the named cookie with the expected value.
+func ExpectCookie(resp Response, name, value string) {
+	if IsDryRun() {
+		return
+	}
+	c := resp.Cookie(name)
+	if c == nil {
+		Fail("ExpectCookie failed: no cookie %s in response", name)
+		return
+	}
+	if c.Value != value {
+		Fail("ExpectCookie failed: expected %s=%s, got %s", name, value, c.Value)
+	}
+	Logf(LogTypeExpect, "Cookie '%s' == '%s' - PASSED", name, value)
+}
+
+// CookieAttrs lists cookie attributes for ExpectCookieAttributes. Only the attributes
+// that are set are checked: true flags must be present, non-empty strings must match,
+// a non-zero SameSite must match, and a non-zero MaxAge must match (negative meaning
+// "Max-Age=0", as in http.Cookie).
+type CookieAttrs struct {
+	HttpOnly bool
+	Secure   bool
+	SameSite http.SameSite
+	Path     string
+	Domain   string
+	MaxAge   int
+}
+
+// ExpectCookieAttributes asserts that the response set the named cookie with the given
+// attributes, reporting every mismatch at once.
+func ExpectCookieAttributes(resp Response, name string, attrs CookieAttrs) {
+	if IsDryRun() {
+		return
+	}
+	c := resp.Cookie(name)
+	if c == nil {
+		Fail("ExpectCookieAttributes failed: no cookie %s in response", name)
+		return
+	}
+	var problems []string
+	if attrs.HttpOnly && !c.HttpOnly {
+		problems = append(problems, "missing HttpOnly")
+	}
+	if attrs.Secure && !c.Secure {
+		problems = append(problems, "missing Secure")
+	}
+	if attrs.SameSite != 0 && c.SameSite != attrs.SameSite {
+		problems = append(problems, fmt.Sprintf("SameSite: expected %s, got %s", sameSiteName(attrs.SameSite), sameSiteName(c.SameSite)))
+	}
+	if attrs.Path != "" && c.Path != attrs.Path {
+		problems = append(problems, fmt.Sprintf("Path: expected %q, got %q", attrs.Path, c.Path))
+	}
+	if attrs.Domain != "" && !strings.EqualFold(strings.TrimPrefix(c.Domain, "."), strings.TrimPrefix(attrs.Domain, ".")) {
+		problems = append(problems, fmt.Sprintf("Domain: expected %q, got %q", attrs.Domain, c.Domain))
+	}
+	if attrs.MaxAge != 0 && c.MaxAge != attrs.MaxAge {
+		problems = append(problems, fmt.Sprintf("Max-Age: expected %d, got %d", attrs.MaxAge, c.MaxAge))
+	}
+	if len(problems) > 0 {
+		Fail("ExpectCookieAttributes failed for cookie %s:\n%s", name, strings.Join(problems, "\n"))
+	}
+	Logf(LogTypeExpect, "Cookie '%s' attributes match - PASSED", name)
+}
+
+func sameSiteName(s http.SameSite) string {
+	switch s {
+	case http.SameSiteDefaultMode:
+		return "Default"
+	case http.SameSiteLaxMode:
+		return "Lax"
+	case http.SameSiteStrictMode:
+		return "Strict"
+	case http.SameSiteNoneMode:
+		return "None"
+	default:
+		return "unset"
+	}
+}
+
+// ExpectContentLength asserts that the server sent a Content-Length of exactly n bytes.
+func ExpectContentLength(resp Response, n int64) {
+	if IsDryRun() {
+		return
+	}
+	if resp.ContentLength != n {
+		if resp.ContentLength < 0 {
+			Fail("Expected Content-Length %d, but none was sent (Transfer-Encoding: %v)", n, resp.TransferEncoding)
+		}
+		Fail("Expected Content-Length %d, got %d", n, resp.ContentLength)
+	}
+	Logf(LogTypeExpect, "Content-Length %d - PASSED", n)
+}
+
+// ExpectChunked asserts that the response body was sent with chunked transfer encoding.
+func ExpectChunked(resp Response) {
+	if IsDryRun() {
+		return
+	}
+	for _, te := range resp.TransferEncoding {
+		if strings.EqualFold(te, "chunked") {
+			Logf(LogTypeExpect, "Transfer-Encoding chunked - PASSED")
+			return
+		}
+	}
+	Fail("Expected chunked Transfer-Encoding, got %v (Content-Length %d)", resp.TransferEncoding, resp.ContentLength)
+}
+
+// ExpectHeaderAbsent asserts that the response does not carry the header (case-insensitive).
+func ExpectHeaderAbsent(resp Response, key string) {
+	if IsDryRun() {
+		return
+	}
+	for k, v := range resp.Header {
+		if strings.EqualFold(k, key) {
+			Fail("ExpectHeaderAbsent failed: expected no %s header, got %s=%s", key, k, v)
+		}
+	}
+	Logf(LogTypeExpect, "Header '%s' absent - PASSED", key)
+}
+
+// ExpectRedirectTo asserts that the response is a 3xx redirect whose Location matches expectedLocation.
+// "*" in expectedLocation matches any run of characters (e.g. "https://idp.example.com/authorize?*").
+// Send the request with WithNoFollowRedirects so the redirect itself is returned.
+func ExpectRedirectTo(resp Response, expectedLocation string) {
+	if IsDryRun() {
+		return
+	}
+	if resp.StatusCode < 300 || resp.StatusCode > 399 {
+		Fail("ExpectRedirectTo failed: expected a 3xx status, got %d. Body: %s", resp.StatusCode, resp.Body)
+	}
+	location := ""
+	for k, v := range resp.Header {
+		if strings.EqualFold(k, "Location") {
+			location = v
+			break
+		}
+	}
+	pattern := "^" + strings.ReplaceAll(regexp.QuoteMeta(expectedLocation), `\*`, ".*") + "$"
+	if !regexp.MustCompile(pattern).MatchString(location) {
+		Fail("ExpectRedirectTo failed: expected Location %s, got %q", expectedLocation, location)
+	}
+	Logf(LogTypeExpect, "Redirect %d to '%s' - PASSED", resp.StatusCode, location)
+}
+
 // ExpectJsonBody asserts that the response body matches the expected JSON.
 // This is a simple implementation that compares unmarshaled objects.
 func ExpectJsonBody(resp Response, expectedJson interface{}) {
@@ -269,7 +743,7 @@ func ExpectJsonBody(resp Response, expectedJson interface{}) {
 	}
 	var got interface{}
 	if err := json.Unmarshal([]byte(resp.Body), &got); err != nil {
-		Fail("ExpectJsonBody failed: response body is not valid JSON: %v. Body: %s", err, resp.Body)
+		failInvalidJSON("ExpectJsonBody", resp, err)
 	}
 
 	// If expectedJson is string, unmarshal it too
@@ -283,13 +757,95 @@ func ExpectJsonBody(resp Response, expectedJson interface{}) {
 	}
 
 	if !reflect.DeepEqual(got, expected) {
+		if d := diff(expected, got); len(d) > 0 {
+			Fail("ExpectJsonBody failed (expected != got):\n%s", strings.Join(d, "\n"))
+		}
 		Fail("ExpectJsonBody failed:\nExpected: %v\nGot:      %v", expected, got)
 	}
 	Log(LogTypeExpect, "JSON body matches expected value - PASSED", "")
 }
 
+// failInvalidJSON fails with the response Content-Type included, so e.g. an HTML error page
+// is reported as such instead of as a bare JSON syntax error.
+func failInvalidJSON(fn string, resp Response, err error) {
+	contentType := responseContentType(resp)
+	if contentType == "" {
+		Fail("%s failed: response body is not valid JSON (no Content-Type): %v. Body: %s", fn, err, resp.Body)
+	}
+	if !strings.Contains(strings.ToLower(contentType), "json") {
+		Fail("%s failed: response Content-Type is %s, not JSON: %v. Body: %s", fn, contentType, err, resp.Body)
+	}
+	Fail("%s failed: response body is not valid JSON (Content-Type: %s): %v. Body: %s", fn, contentType, err, resp.Body)
+}
+
+// responseContentType returns the response Content-Type header (case-insensitive lookup), or "".
+func responseContentType(resp Response) string {
+	for k, v := range resp.Header {
+		if strings.EqualFold(k, "Content-Type") {
+			return v
+		}
+	}
+	return ""
+}
+
+// ExpectBody asserts the response body by dispatching on its Content-Type:
+// JSON types (application/json, *+json) compare like ExpectJsonBody, XML types
+// (application/xml, text/xml, *+xml) like ExpectXmlBody, anything else by exact string match.
+// For XML and plain text, expected must be a string.
+func ExpectBody(resp Response, expected interface{}) {
+	if IsDryRun() {
+		return
+	}
+	mediaType := strings.ToLower(strings.TrimSpace(strings.SplitN(responseContentType(resp), ";", 2)[0]))
+	if strings.HasSuffix(mediaType, "/json") || strings.HasSuffix(mediaType, "+json") {
+		ExpectJsonBody(resp, expected)
+		return
+	}
+
+	s, ok := expected.(string)
+	if !ok {
+		Fail("ExpectBody failed: expected value for Content-Type %q must be a string, got %T", mediaType, expected)
+		return
+	}
+	if strings.HasSuffix(mediaType, "/xml") || strings.HasSuffix(mediaType, "+xml") {
+		ExpectXmlBody(resp, s)
+		return
+	}
+	if resp.Body != s {
+		Fail("ExpectBody failed:\nExpected: %s\nGot:      %s", s, resp.Body)
+	}
+	Log(LogTypeExpect, "Body matches expected value - PASSED", "")
+}
+
+// ExpectJsonSchema asserts that the response body conforms to the JSON Schema schemaJSON.
+// All violations are reported at once, each prefixed with its JSON path (e.g. "$.user.id").
+// It uses a built-in validator for a subset of JSON Schema (see validateJSONSchema and the
+// README); schemas using other keywords fail rather than pass unchecked.
+func ExpectJsonSchema(resp Response, schemaJSON string) {
+	if IsDryRun() {
+		return
+	}
+	var schema interface{}
+	if err := json.Unmarshal([]byte(schemaJSON), &schema); err != nil {
+		Fail("ExpectJsonSchema failed: schema is not valid JSON: %v", err)
+	}
+	if unsupported := unsupportedSchemaKeywords(schema, "#"); len(unsupported) > 0 {
+		Fail("ExpectJsonSchema failed: schema uses keywords that are not supported and would not be checked:\n%s", strings.Join(unsupported, "\n"))
+	}
+	var body interface{}
+	if err := json.Unmarshal([]byte(resp.Body), &body); err != nil {
+		failInvalidJSON("ExpectJsonSchema", resp, err)
+	}
+
+	if errs := validateJSONSchema(schema, body, "$"); len(errs) > 0 {
+		Fail("ExpectJsonSchema failed:\n%s", strings.Join(errs, "\n"))
+	}
+	Log(LogTypeExpect, "JSON body matches schema - PASSED", "")
+}
+
 // ExpectJsonBodyField asserts that a specific field in the JSON response body matches the expected value.
-// field supports dot notation and array index (e.g. "data.users[0].name")
+// field supports dot notation and array index (e.g. "data.users[0].name"); "[*]" checks every
+// element of an array (e.g. "items[*].status"), failing on the first element that differs.
 func ExpectJsonBodyField(resp Response, field string, expectedValue interface{}) {
 	if IsDryRun() {
 		return
@@ -297,27 +853,29 @@ func ExpectJsonBodyField(resp Response, field string, expectedValue interface{})
 
 	var body interface{}
 	if err := json.Unmarshal([]byte(resp.Body), &body); err != nil {
-		Fail("ExpectJsonBodyField failed: response body is not valid JSON: %v. Body: %s", err, resp.Body)
+		failInvalidJSON("ExpectJsonBodyField", resp, err)
 	}
 
-	gotValue, err := getValueByPath(body, field)
+	gotValues, paths, err := getValuesByPath(body, field)
 	if err != nil {
 		Fail("ExpectJsonBodyField failed to get field '%s': %v. Body: %s", field, err, resp.Body)
 	}
 
-	match := false
-	if isNumber(gotValue) && isNumber(expectedValue) {
-		if toFloat64(gotValue) == toFloat64(expectedValue) {
-			match = true
-		}
-	} else {
-		if reflect.DeepEqual(gotValue, expectedValue) {
-			match = true
+	for i, gotValue := range gotValues {
+		match := false
+		if isNumber(gotValue) && isNumber(expectedValue) {
+			if toFloat64(gotValue) == toFloat64(expectedValue) {
+				match = true
+			}
+		} else {
+			if reflect.DeepEqual(gotValue, expectedValue) {
+				match = true
+			}
 		}
-	}
 
-	if !match {
-		Fail("ExpectJsonBodyField failed for field '%s':\nExpected: %v (%T)\nGot:      %v (%T)", field, expectedValue, expectedValue, gotValue, gotValue)
+		if !match {
+			Fail("ExpectJsonBodyField failed for field '%s':\nExpected: %v (%T)\nGot:      %v (%T)", paths[i], expectedValue, expectedValue, gotValue, gotValue)
+		}
 	}
 	Logf(LogTypeExpect, "JSON Field '%s' == %v - PASSED", field, expectedValue)
 }
@@ -326,6 +884,7 @@ func ExpectJsonBodyField(resp Response, field string, expectedValue interface{})
 // satisfies the provided condition against the expected value.
 // Supported conditions are the same as dynamic mock server conditions (e.g., Equal, NotEqual, GreaterThan).
 // It also supports checking for JSON null by passing expectedValue as nil with ConditionEqual/ConditionNotEqual.
+// As with ExpectJsonBodyField, "[*]" in field applies the condition to every array element.
 func ExpectJsonBodyFieldCond(resp Response, field string, condition string, expectedValue interface{}) {
 	if IsDryRun() {
 		return
@@ -333,21 +892,121 @@ func ExpectJsonBodyFieldCond(resp Response, field string, condition string, expe
 
 	var body interface{}
 	if err := json.Unmarshal([]byte(resp.Body), &body); err != nil {
-		Fail("ExpectJsonBodyFieldCond failed: response body is not valid JSON: %v. Body: %s", err, resp.Body)
+		failInvalidJSON("ExpectJsonBodyFieldCond", resp, err)
 	}
 
-	gotValue, err := getValueByPath(body, field)
+	gotValues, paths, err := getValuesByPath(body, field)
 	if err != nil {
 		Fail("ExpectJsonBodyFieldCond failed to get field '%s': %v. Body: %s", field, err, resp.Body)
 	}
 
-	if !evaluateCondition(gotValue, condition, expectedValue) {
-		Fail("ExpectJsonBodyFieldCond failed for field '%s' with condition '%s':\nExpected: %v (%T)\nGot:      %v (%T)", field, condition, expectedValue, expectedValue, gotValue, gotValue)
+	for i, gotValue := range gotValues {
+		if !evaluateCondition(gotValue, condition, expectedValue) {
+			Fail("ExpectJsonBodyFieldCond failed for field '%s' with condition '%s':\nExpected: %v (%T)\nGot:      %v (%T)", paths[i], condition, expectedValue, expectedValue, gotValue, gotValue)
+		}
 	}
 
 	Logf(LogTypeExpect, "JSON Field '%s' %s %v - PASSED", field, condition, expectedValue)
 }
 
+// ExpectJsonBodyFieldApprox asserts that a numeric field in the JSON response body is
+// within epsilon of expected (|got-expected| <= epsilon), for floats such as prices or
+// coordinates that rarely compare exactly. "[*]" in field checks every array element.
+func ExpectJsonBodyFieldApprox(resp Response, field string, expected float64, epsilon float64) {
+	if IsDryRun() {
+		return
+	}
+
+	var body interface{}
+	if err := json.Unmarshal([]byte(resp.Body), &body); err != nil {
+		failInvalidJSON("ExpectJsonBodyFieldApprox", resp, err)
+	}
+
+	gotValues, paths, err := getValuesByPath(body, field)
+	if err != nil {
+		Fail("ExpectJsonBodyFieldApprox failed to get field '%s': %v. Body: %s", field, err, resp.Body)
+	}
+
+	for i, gotValue := range gotValues {
+		if !isNumber(gotValue) {
+			Fail("ExpectJsonBodyFieldApprox failed for field '%s': expected a number, got %v (%T)", paths[i], gotValue, gotValue)
+		}
+		if got := toFloat64(gotValue); math.Abs(got-expected) > epsilon {
+			Fail("ExpectJsonBodyFieldApprox failed for field '%s':\nExpected: %v ± %v\nGot:      %v", paths[i], expected, epsilon, got)
+		}
+	}
+
+	Logf(LogTypeExpect, "JSON Field '%s' ≈ %v (±%v) - PASSED", field, expected, epsilon)
+}
+
+// ExpectJsonBodyFieldAbsent asserts that field (a path as for ExpectJsonBodyField, without
+// "[*]") is not present in the JSON response body, e.g. that a password is never returned.
+// A field present with a null value fails; use ExpectJsonBodyFieldAbsentOrNull to allow it.
+func ExpectJsonBodyFieldAbsent(resp Response, field string) {
+	expectJsonFieldAbsent("ExpectJsonBodyFieldAbsent", resp, field, false)
+}
+
+// ExpectJsonBodyFieldAbsentOrNull is like ExpectJsonBodyFieldAbsent but also passes when the
+// field is present with a null value.
+func ExpectJsonBodyFieldAbsentOrNull(resp Response, field string) {
+	expectJsonFieldAbsent("ExpectJsonBodyFieldAbsentOrNull", resp, field, true)
+}
+
+func expectJsonFieldAbsent(fn string, resp Response, field string, allowNull bool) {
+	if IsDryRun() {
+		return
+	}
+
+	var body interface{}
+	if err := json.Unmarshal([]byte(resp.Body), &body); err != nil {
+		failInvalidJSON(fn, resp, err)
+	}
+
+	got, err := getValueByPath(body, field)
+	if err != nil {
+		if !errors.Is(err, errJSONKeyNotFound) {
+			Fail("%s failed to resolve field '%s': %v. Body: %s", fn, field, err, resp.Body)
+		}
+		Logf(LogTypeExpect, "JSON Field '%s' absent - PASSED", field)
+		return
+	}
+	if got == nil {
+		if !allowNull {
+			Fail("%s failed: field '%s' is present (null)", fn, field)
+		}
+		Logf(LogTypeExpect, "JSON Field '%s' is null - PASSED", field)
+		return
+	}
+	Fail("%s failed: field '%s' is present with value %v (%T)", fn, field, got, got)
+}
+
+// ExpectJsonRootArrayLength asserts that the JSON response body is an array (e.g. `[...]`)
+// with exactly n elements. Elements of a root array are addressed in field paths with a
+// leading index, e.g. "[0].id" or "[*].id".
+func ExpectJsonRootArrayLength(resp Response, n int) {
+	if IsDryRun() {
+		return
+	}
+
+	var body interface{}
+	if err := json.Unmarshal([]byte(resp.Body), &body); err != nil {
+		failInvalidJSON("ExpectJsonRootArrayLength", resp, err)
+	}
+
+	arr, ok := body.([]interface{})
+	if !ok {
+		Fail("ExpectJsonRootArrayLength failed: expected a JSON array at the root, got %T. Body: %s", body, resp.Body)
+	}
+	if len(arr) != n {
+		Fail("ExpectJsonRootArrayLength failed:\nExpected: %d element(s)\nGot:      %d", n, len(arr))
+	}
+
+	Logf(LogTypeExpect, "JSON root array length %d - PASSED", n)
+}
+
+// errJSONKeyNotFound is wrapped by getValueByPath when an object along the path lacks the key.
+var errJSONKeyNotFound = errors.New("not found")
+
 func getValueByPath(data interface{}, path string) (interface{}, error) {
 	parts := strings.Split(path, ".")
 	current := data
@@ -379,7 +1038,7 @@ func getValueByPath(data interface{}, path string) (interface{}, error) {
 			}
 			val, exists := m[key]
 			if !exists {
-				return nil, fmt.Errorf("key '%s' not found", key)
+				return nil, fmt.Errorf("key '%s' %w", key, errJSONKeyNotFound)
 			}
 			current = val
 		}
@@ -398,6 +1057,61 @@ func getValueByPath(data interface{}, path string) (interface{}, error) {
 	return current, nil
 }
 
+// getValuesByPath is like getValueByPath but also accepts "[*]" segments, which fan out over
+// every element of an array. It returns the matched values with their concrete paths
+// (e.g. "items[2].status"); an empty array under "[*]" is an error.
+func getValuesByPath(data interface{}, path string) ([]interface{}, []string, error) {
+	star := strings.Index(path, "[*]")
+	if star < 0 {
+		v, err := getValueByPath(data, path)
+		if err != nil {
+			return nil, nil, err
+		}
+		return []interface{}{v}, []string{path}, nil
+	}
+
+	prefix, rest := path[:star], strings.TrimPrefix(path[star+len("[*]"):], ".")
+	container := data
+	if prefix != "" {
+		v, err := getValueByPath(data, prefix)
+		if err != nil {
+			return nil, nil, err
+		}
+		container = v
+	}
+	arr, ok := container.([]interface{})
+	if !ok {
+		return nil, nil, fmt.Errorf("expected array for [*] at '%s' but got %T (value: %v)", prefix, container, container)
+	}
+	if len(arr) == 0 {
+		return nil, nil, fmt.Errorf("no elements to match for [*] at '%s' (empty array)", prefix)
+	}
+
+	var values []interface{}
+	var paths []string
+	for i, elem := range arr {
+		elemPath := fmt.Sprintf("%s[%d]", prefix, i)
+		if rest == "" {
+			values = append(values, elem)
+			paths = append(paths, elemPath)
+			continue
+		}
+		vs, ps, err := getValuesByPath(elem, rest)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %v", elemPath, err)
+		}
+		values = append(values, vs...)
+		for _, p := range ps {
+			if strings.HasPrefix(p, "[") {
+				paths = append(paths, elemPath+p)
+			} else {
+				paths = append(paths, elemPath+"."+p)
+			}
+		}
+	}
+	return values, paths, nil
+}
+
 // setValueByPath sets a value in a nested map/slice structure at the given dot+bracket path.
 // It supports dot notation and array indices (e.g. "a.b[0].c").
 func setValueByPath(data interface{}, path string, value interface{}) error {
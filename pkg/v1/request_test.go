@@ -5,10 +5,16 @@ import (
 	"encoding/xml"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestSetValueByPath(t *testing.T) {
@@ -478,3 +484,770 @@ func TestSendRESTRequestIgnoreSSL(t *testing.T) {
 		t.Fatalf("expected body 'secure', got %s", resp.Body)
 	}
 }
+
+func TestSendRESTRequestMaxResponseBytes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, strings.Repeat("x", 2048))
+	}))
+	defer server.Close()
+
+	// Within the limit (exactly at it) succeeds
+	resp := SendRESTRequest(server.URL, WithMaxResponseBytes(2048))
+	if len(resp.Body) != 2048 {
+		t.Fatalf("expected 2048 bytes, got %d", len(resp.Body))
+	}
+
+	// Over the limit fails
+	func() {
+		defer func() {
+			r := recover()
+			if r == nil {
+				t.Fatal("expected panic for oversized response")
+			}
+			if _, ok := r.(TestError); !ok {
+				t.Fatalf("unexpected panic type: %T", r)
+			}
+		}()
+		SendRESTRequest(server.URL, WithMaxResponseBytes(1024))
+	}()
+}
+
+func TestExpectHeaderAbsent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			w.Header().Set("Set-Cookie", "session=abc")
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	// Header not present passes
+	ExpectHeaderAbsent(SendRESTRequest(server.URL+"/public"), "Set-Cookie")
+
+	// Header present fails, regardless of key case
+	resp := SendRESTRequest(server.URL + "/login")
+	for _, key := range []string{"Set-Cookie", "set-cookie"} {
+		func() {
+			defer func() {
+				r := recover()
+				if r == nil {
+					t.Errorf("expected ExpectHeaderAbsent(%q) to panic", key)
+				}
+				if _, ok := r.(TestError); r != nil && !ok {
+					t.Errorf("unexpected panic type: %T", r)
+				}
+			}()
+			ExpectHeaderAbsent(resp, key)
+		}()
+	}
+}
+
+func TestExpectJsonBodyReportsContentType(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusBadGateway)
+		fmt.Fprint(w, "<html><body>Bad Gateway</body></html>")
+	}))
+	defer server.Close()
+
+	resp := SendRESTRequest(server.URL)
+
+	expectFailMentions := func(name string, f func(), want string) {
+		defer func() {
+			r := recover()
+			te, ok := r.(TestError)
+			if !ok {
+				t.Fatalf("%s: expected TestError panic, got %T", name, r)
+			}
+			if !strings.Contains(te.Message, want) {
+				t.Errorf("%s: expected message to mention %q, got: %s", name, want, te.Message)
+			}
+		}()
+		f()
+	}
+
+	expectFailMentions("ExpectJsonBody", func() { ExpectJsonBody(resp, `{"a": 1}`) }, "text/html")
+	expectFailMentions("ExpectJsonBodyField", func() { ExpectJsonBodyField(resp, "a", 1) }, "text/html")
+	expectFailMentions("ExpectJsonBodyFieldCond", func() { ExpectJsonBodyFieldCond(resp, "a", ConditionEqual, 1) }, "text/html")
+}
+
+func TestSendRESTRequestNoRecord(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	tester := NewTester()
+	tester.Stage("Polling", func() {
+		SendRESTRequest(server.URL)
+		for i := 0; i < 5; i++ {
+			SendRESTRequest(server.URL, WithNoRecord())
+		}
+
+		SetRequestRecording(false)
+		defer SetRequestRecording(true)
+		SendRESTRequest(server.URL)
+	})
+
+	if err := tester.RunStageByName("Polling"); err != nil {
+		t.Fatalf("Stage failed: %v", err)
+	}
+	if got := len(GetStageActions("Polling")); got != 1 {
+		t.Errorf("Expected 1 recorded action, got %d", got)
+	}
+}
+
+func TestSendRESTRequestWithBodyReader(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, _ := io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, "%d %s", n, r.Header.Get("Content-Type"))
+	}))
+	defer server.Close()
+
+	const size = 8 << 20 // 8 MiB
+	body := io.LimitReader(zeroReader{}, size)
+
+	resp := SendRESTRequest(server.URL,
+		WithMethod(http.MethodPut),
+		WithBodyReader(body, "application/octet-stream"),
+	)
+	if want := fmt.Sprintf("%d application/octet-stream", size); resp.Body != want {
+		t.Errorf("Expected server to receive %q, got %q", want, resp.Body)
+	}
+
+	// The last body option wins, in either order
+	resp = SendRESTRequest(server.URL,
+		WithMethod(http.MethodPut),
+		WithBodyReader(strings.NewReader("streamed"), "text/plain"),
+		WithBodyString("buffered!"),
+	)
+	if want := "9 text/plain"; resp.Body != want {
+		t.Errorf("Expected WithBodyString to replace the reader (%q), got %q", want, resp.Body)
+	}
+	resp = SendRESTRequest(server.URL,
+		WithMethod(http.MethodPut),
+		WithBodyString("buffered!"),
+		WithBodyReader(strings.NewReader("streamed"), "text/plain"),
+	)
+	if want := "8 text/plain"; resp.Body != want {
+		t.Errorf("Expected WithBodyReader to replace the body (%q), got %q", want, resp.Body)
+	}
+}
+
+// zeroReader yields an endless stream of zero bytes without allocating.
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+func TestExpectRedirectTo(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			http.Redirect(w, r, "/authorize?client_id=abc&state=xyz", http.StatusFound)
+		case "/authorize":
+			fmt.Fprint(w, "authorize page")
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer server.Close()
+
+	resp := SendRESTRequest(server.URL+"/login", WithNoFollowRedirects())
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("Expected the 302 to be returned, got %d", resp.StatusCode)
+	}
+	ExpectRedirectTo(resp, "/authorize?client_id=abc&state=xyz")
+	ExpectRedirectTo(resp, "/authorize?client_id=abc*")
+
+	assertPanic := func(name string, f func()) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Errorf("%s expected to panic", name)
+			} else if _, ok := r.(TestError); !ok {
+				t.Errorf("%s panicked with unexpected type: %T", name, r)
+			}
+		}()
+		f()
+	}
+	assertPanic("wrong location", func() { ExpectRedirectTo(resp, "/elsewhere") })
+
+	// Redirects are followed by default
+	followed := SendRESTRequest(server.URL + "/login")
+	if followed.Body != "authorize page" {
+		t.Errorf("Expected redirect to be followed by default, got %q", followed.Body)
+	}
+	assertPanic("not a redirect", func() { ExpectRedirectTo(followed, "/authorize*") })
+}
+
+func TestExpectJsonSchema(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"required": ["id", "name"],
+		"properties": {
+			"id": {"type": "integer", "minimum": 1},
+			"name": {"type": "string", "minLength": 1},
+			"tags": {"type": "array", "items": {"type": "string"}}
+		},
+		"additionalProperties": false
+	}`
+
+	ExpectJsonSchema(Response{Body: `{"id": 7, "name": "alice", "tags": ["a", "b"]}`}, schema)
+
+	failMessage := func(body string) string {
+		var msg string
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					te, ok := r.(TestError)
+					if !ok {
+						t.Fatalf("Unexpected panic type: %T", r)
+					}
+					msg = te.Message
+				}
+			}()
+			ExpectJsonSchema(Response{Body: body}, schema)
+		}()
+		return msg
+	}
+
+	msg := failMessage(`{"id": "7", "name": "alice", "tags": ["a", 2], "extra": true}`)
+	for _, want := range []string{
+		`$.id: expected type integer, got string`,
+		`$.tags[1]: expected type string, got number`,
+		`$: additional property "extra" is not allowed`,
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("Expected failure to mention %q, got: %s", want, msg)
+		}
+	}
+
+	if msg := failMessage(`{"id": 1.5}`); !strings.Contains(msg, `missing required property "name"`) || !strings.Contains(msg, "$.id: expected type integer") {
+		t.Errorf("Unexpected failure message: %s", msg)
+	}
+
+	// Keywords that are not enforced fail instead of passing every value
+	schema = `{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"title": "User",
+		"type": "object",
+		"properties": {
+			"email": {"type": "string", "format": "idn-email"},
+			"address": {"$ref": "https://example.com/address.json"}
+		},
+		"unevaluatedProperties": false
+	}`
+	msg = failMessage(`{"email": "alice@example.com"}`)
+	for _, want := range []string{"#/unevaluatedProperties", "#/properties/address/$ref", "#/properties/email/format"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("Expected the unsupported keyword %s to be reported, got: %s", want, msg)
+		}
+	}
+	if strings.Contains(msg, "$schema") || strings.Contains(msg, "title") {
+		t.Errorf("Annotation keywords must be accepted, got: %s", msg)
+	}
+}
+
+func TestExpectJsonSchemaKeywords(t *testing.T) {
+	schema := `{
+		"$defs": {
+			"node": {
+				"type": "object",
+				"required": ["name"],
+				"properties": {"name": {"type": "string"}, "children": {"type": "array", "items": {"$ref": "#/$defs/node"}}}
+			}
+		},
+		"type": "object",
+		"properties": {
+			"id": {"type": "string", "format": "uuid"},
+			"createdAt": {"type": "string", "format": "date-time"},
+			"email": {"type": "string", "format": "email"},
+			"price": {"type": "number", "multipleOf": 0.01},
+			"tags": {"type": "array", "uniqueItems": true, "contains": {"const": "main"}},
+			"point": {"type": "array", "prefixItems": [{"type": "number"}, {"type": "number"}], "items": false},
+			"tree": {"$ref": "#/$defs/node"},
+			"labels": {
+				"type": "object",
+				"propertyNames": {"pattern": "^[a-z]+$"},
+				"patternProperties": {"^x": {"type": "integer"}},
+				"additionalProperties": {"type": "string"},
+				"maxProperties": 3
+			}
+		},
+		"dependentRequired": {"email": ["id"]},
+		"if": {"properties": {"tags": {"contains": {"const": "paid"}}}},
+		"then": {"required": ["price"]}
+	}`
+
+	ExpectJsonSchema(Response{Body: `{
+		"id": "0b8f3c2e-6d1a-4f7e-9c3b-2a5d8e1f4c6b",
+		"createdAt": "2024-05-01T10:00:00.5Z",
+		"email": "alice@example.com",
+		"price": 19.99,
+		"tags": ["main", "paid"],
+		"point": [1, 2],
+		"tree": {"name": "root", "children": [{"name": "leaf"}]},
+		"labels": {"xa": 1, "env": "prod"}
+	}`}, schema)
+
+	var msg string
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				te, ok := r.(TestError)
+				if !ok {
+					t.Fatalf("Unexpected panic type: %T", r)
+				}
+				msg = te.Message
+			}
+		}()
+		ExpectJsonSchema(Response{Body: `{
+			"id": "not-a-uuid",
+			"createdAt": "yesterday",
+			"email": "Alice <alice@example.com>",
+			"tags": ["main", "paid", "main"],
+			"point": [1, 2, 3],
+			"tree": {"children": [{"name": 5}]},
+			"labels": {"xa": "one", "Env": "prod", "a": "1", "b": "2"}
+		}`}, schema)
+	}()
+	for _, want := range []string{
+		`$.id: "not-a-uuid" is not a valid uuid`,
+		`$.createdAt: "yesterday" is not a valid date-time`,
+		`$.email: "Alice <alice@example.com>" is not a valid email`,
+		`$.tags: items 0 and 2 are equal`,
+		`$.point: expected at most 2 items, got 3`,
+		`$.tree: missing required property "name"`,
+		`$.tree.children[0].name: expected type string, got number`,
+		`$.labels: expected at most 3 properties, got 4`,
+		`$.labels.Env (property name): "Env" does not match pattern`,
+		`$.labels.xa: expected type integer, got string`,
+		`$: missing required property "price"`,
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("Expected failure to mention %q, got: %s", want, msg)
+		}
+	}
+
+	ExpectFailure(func() {
+		ExpectJsonSchema(Response{Body: `{"email": "alice@example.com", "price": 1.005, "tags": ["other"]}`}, schema)
+	})
+
+	// A reference cycle that never reaches the value is reported, not followed forever
+	ExpectFailure(func() {
+		ExpectJsonSchema(Response{Body: `{}`}, `{"$defs": {"a": {"$ref": "#/$defs/b"}, "b": {"$ref": "#/$defs/a"}}, "$ref": "#/$defs/a"}`)
+	})
+	ExpectFailure(func() {
+		ExpectJsonSchema(Response{Body: `{}`}, `{"$ref": "#/$defs/missing"}`)
+	})
+}
+
+func TestExpectBody(t *testing.T) {
+	assertPanic := func(name string, f func()) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Errorf("%s expected to panic", name)
+			} else if _, ok := r.(TestError); !ok {
+				t.Errorf("%s panicked with unexpected type: %T", name, r)
+			}
+		}()
+		f()
+	}
+
+	// JSON: key order and whitespace don't matter
+	jsonResp := Response{Body: `{"b": 2, "a": 1}`, Header: map[string]string{"Content-Type": "application/json; charset=utf-8"}}
+	ExpectBody(jsonResp, `{"a":1,"b":2}`)
+	ExpectBody(Response{Body: `{"a":1}`, Header: map[string]string{"content-type": "application/problem+json"}}, `{"a": 1}`)
+	assertPanic("json mismatch", func() { ExpectBody(jsonResp, `{"a":1,"b":3}`) })
+
+	// XML: compared in canonical form
+	xmlResp := Response{Body: `<root><item id="1">x</item></root>`, Header: map[string]string{"Content-Type": "text/xml"}}
+	ExpectBody(xmlResp, "<root>\n  <item id=\"1\">x</item>\n</root>")
+	assertPanic("xml mismatch", func() { ExpectBody(xmlResp, `<root><item id="2">x</item></root>`) })
+
+	// Anything else: exact string match
+	textResp := Response{Body: `{"a": 1}`, Header: map[string]string{"Content-Type": "text/plain"}}
+	ExpectBody(textResp, `{"a": 1}`)
+	assertPanic("text mismatch", func() { ExpectBody(textResp, `{"a":1}`) })
+	assertPanic("text non-string", func() { ExpectBody(textResp, map[string]interface{}{"a": 1}) })
+}
+
+func TestResponseCookies(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc123", Path: "/", HttpOnly: true})
+		http.SetCookie(w, &http.Cookie{Name: "theme", Value: "dark"})
+		fmt.Fprint(w, "logged in")
+	}))
+	defer server.Close()
+
+	resp := SendRESTRequest(server.URL+"/login", WithMethod(http.MethodPost))
+	if len(resp.Cookies) != 2 {
+		t.Fatalf("Expected 2 cookies, got %d", len(resp.Cookies))
+	}
+	session := resp.Cookie("session")
+	if session == nil || session.Value != "abc123" || !session.HttpOnly || session.Path != "/" {
+		t.Errorf("Unexpected session cookie: %+v", session)
+	}
+	if resp.Cookie("missing") != nil {
+		t.Error("Expected nil for a cookie that was not set")
+	}
+
+	ExpectCookie(resp, "session", "abc123")
+	ExpectCookie(resp, "theme", "dark")
+	ExpectFailure(func() { ExpectCookie(resp, "session", "other") })
+	ExpectFailure(func() { ExpectCookie(resp, "missing", "") })
+}
+
+func TestExpectJsonBodyFieldWildcard(t *testing.T) {
+	resp := Response{Body: `{"items":[
+		{"id":1,"status":"active","tags":[{"n":"a"}]},
+		{"id":2,"status":"active","tags":[{"n":"a"},{"n":"a"}]},
+		{"id":3,"status":"active","tags":[{"n":"a"}]}
+	]}`}
+
+	ExpectJsonBodyField(resp, "items[*].status", "active")
+	ExpectJsonBodyField(resp, "items[*].tags[*].n", "a")
+	ExpectJsonBodyFieldCond(resp, "items[*].id", ConditionGreaterThan, 0)
+
+	values, paths, err := getValuesByPath(map[string]interface{}{"xs": []interface{}{"p", "q"}}, "xs[*]")
+	if err != nil || fmt.Sprint(values) != "[p q]" || fmt.Sprint(paths) != "[xs[0] xs[1]]" {
+		t.Errorf("Unexpected getValuesByPath result: %v %v %v", values, paths, err)
+	}
+
+	failMessage := func(f func()) string {
+		var msg string
+		func() {
+			defer func() {
+				if te, ok := recover().(TestError); ok {
+					msg = te.Message
+				}
+			}()
+			f()
+		}()
+		return msg
+	}
+
+	mixed := Response{Body: `{"items":[{"status":"active"},{"status":"inactive"},{"status":"active"}]}`}
+	if msg := failMessage(func() { ExpectJsonBodyField(mixed, "items[*].status", "active") }); !strings.Contains(msg, "items[1].status") {
+		t.Errorf("Expected failure pinpointing items[1].status, got %q", msg)
+	}
+	if msg := failMessage(func() { ExpectJsonBodyFieldCond(resp, "items[*].id", ConditionLessThan, 3) }); !strings.Contains(msg, "items[2].id") {
+		t.Errorf("Expected failure pinpointing items[2].id, got %q", msg)
+	}
+	if msg := failMessage(func() { ExpectJsonBodyField(Response{Body: `{"items":[]}`}, "items[*].status", "active") }); !strings.Contains(msg, "empty array") {
+		t.Errorf("Expected an empty array to fail, got %q", msg)
+	}
+}
+
+func TestExpectJsonBodyFieldApprox(t *testing.T) {
+	resp := Response{Body: `{"price":19.99,"loc":{"lat":13.7563},"points":[{"x":1.001},{"x":0.999}],"name":"a"}`}
+
+	ExpectJsonBodyFieldApprox(resp, "price", 20, 0.011)
+	ExpectJsonBodyFieldApprox(resp, "loc.lat", 13.75, 0.01)
+	ExpectJsonBodyFieldApprox(resp, "points[*].x", 1, 0.002)
+
+	ExpectFailure(func() { ExpectJsonBodyFieldApprox(resp, "price", 20, 0.009) })
+	ExpectFailure(func() { ExpectJsonBodyFieldApprox(resp, "points[*].x", 1, 0.0005) })
+	ExpectFailure(func() { ExpectJsonBodyFieldApprox(resp, "name", 0, 1) })
+	ExpectFailure(func() { ExpectJsonBodyFieldApprox(resp, "missing", 0, 1) })
+}
+
+func TestJsonRootArray(t *testing.T) {
+	resp := Response{Body: `[{"id":1,"tags":["a","b"]},{"id":2,"tags":[]},{"id":3,"tags":["c"]}]`}
+
+	var body interface{}
+	if err := json.Unmarshal([]byte(resp.Body), &body); err != nil {
+		t.Fatal(err)
+	}
+	paths := map[string]interface{}{
+		"[0].id":      float64(1),
+		"[2].id":      float64(3),
+		"[0].tags[1]": "b",
+		"[2].tags[0]": "c",
+	}
+	for path, want := range paths {
+		got, err := getValueByPath(body, path)
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("getValueByPath(%q) = %v, %v; want %v", path, got, err, want)
+		}
+	}
+	if got, err := getValueByPath(body, "[1]"); err != nil || got.(map[string]interface{})["id"] != float64(2) {
+		t.Errorf("getValueByPath(\"[1]\") = %v, %v", got, err)
+	}
+	for _, path := range []string{"[3].id", "[0].missing", "id", "[x]"} {
+		if _, err := getValueByPath(body, path); err == nil {
+			t.Errorf("getValueByPath(%q): expected error", path)
+		}
+	}
+	if _, err := getValueByPath(map[string]interface{}{"id": 1}, "[0]"); err == nil {
+		t.Error("getValueByPath on an object root with [0]: expected error")
+	}
+
+	ExpectJsonRootArrayLength(resp, 3)
+	ExpectJsonRootArrayLength(Response{Body: `[]`}, 0)
+	ExpectJsonBodyField(resp, "[1].id", 2)
+	ExpectJsonBodyFieldCond(resp, "[*].id", ConditionGreaterThan, 0)
+
+	ExpectFailure(func() { ExpectJsonRootArrayLength(resp, 2) })
+	ExpectFailure(func() { ExpectJsonRootArrayLength(Response{Body: `{"items":[]}`}, 0) })
+	ExpectFailure(func() { ExpectJsonRootArrayLength(Response{Body: `[1,`}, 1) })
+	ExpectFailure(func() { ExpectJsonBodyField(resp, "[0].id", 2) })
+}
+
+func TestUserAgent(t *testing.T) {
+	var got []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.UserAgent())
+	}))
+	defer server.Close()
+	defer SetDefaultUserAgent("")
+
+	SendRESTRequest(server.URL, WithNoRecord())
+	SendRESTRequest(server.URL, WithNoRecord(), WithUserAgent("custom-agent/2.0"))
+	SetDefaultUserAgent("integrate-tester/1.0")
+	SendRESTRequest(server.URL, WithNoRecord())
+	SendRESTRequest(server.URL, WithNoRecord(), WithUserAgent("custom-agent/2.0"))
+	SendRESTRequest(server.URL, WithNoRecord(), WithHeader("user-agent", "lower/1.0"))
+
+	if len(got) != 5 {
+		t.Fatalf("Expected 5 requests, got %d", len(got))
+	}
+	if !strings.HasPrefix(got[0], "Go-http-client/") {
+		t.Errorf("Expected Go's User-Agent without a default, got %q", got[0])
+	}
+	want := []string{"custom-agent/2.0", "integrate-tester/1.0", "custom-agent/2.0", "lower/1.0"}
+	if !reflect.DeepEqual(got[1:], want) {
+		t.Errorf("Expected User-Agents %v, got %v", want, got[1:])
+	}
+}
+
+func TestWithRetryIdempotentOnly(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	send := func(opts ...RESTRequestOption) (Response, int32) {
+		atomic.StoreInt32(&calls, 0)
+		resp := SendRESTRequest(server.URL, append([]RESTRequestOption{WithNoRecord()}, opts...)...)
+		return resp, atomic.LoadInt32(&calls)
+	}
+
+	if resp, n := send(WithRetry(3, time.Millisecond)); resp.StatusCode != http.StatusOK || n != 3 {
+		t.Errorf("GET: expected 200 after 3 calls, got %d after %d", resp.StatusCode, n)
+	}
+	if resp, n := send(WithMethod(http.MethodPost), WithBodyString(`{"a":1}`), WithRetry(3, time.Millisecond)); resp.StatusCode != http.StatusServiceUnavailable || n != 1 {
+		t.Errorf("POST: expected a single 503 call by default, got %d after %d", resp.StatusCode, n)
+	}
+	if resp, n := send(WithMethod(http.MethodPost), WithBodyString(`{"a":1}`), WithRetry(3, time.Millisecond), WithRetryUnsafe()); resp.StatusCode != http.StatusOK || n != 3 {
+		t.Errorf("POST with WithRetryUnsafe: expected 200 after 3 calls, got %d after %d", resp.StatusCode, n)
+	}
+	if resp, n := send(WithRetry(2, time.Millisecond)); resp.StatusCode != http.StatusServiceUnavailable || n != 2 {
+		t.Errorf("GET: expected to give up after 2 attempts, got %d after %d", resp.StatusCode, n)
+	}
+}
+
+func TestDownloadResponseBody(t *testing.T) {
+	report := strings.Repeat("id,amount\n1,100\n", 1000)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/csv")
+		io.WriteString(w, report)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+
+	streamed := filepath.Join(dir, "streamed.csv")
+	resp := SendRESTRequest(server.URL, WithNoRecord(), WithDownloadTo(streamed))
+	ExpectStatusCode(resp, http.StatusOK)
+	if resp.Body != "" {
+		t.Errorf("Expected an empty Body when downloading to a file, got %d bytes", len(resp.Body))
+	}
+	if got, err := os.ReadFile(streamed); err != nil || string(got) != report {
+		t.Errorf("Downloaded file mismatch (err=%v, %d bytes)", err, len(got))
+	}
+
+	saved := filepath.Join(dir, "saved.csv")
+	resp = SendRESTRequest(server.URL, WithNoRecord())
+	if err := SaveResponseBody(resp, saved); err != nil {
+		t.Fatalf("SaveResponseBody failed: %v", err)
+	}
+	if got, err := os.ReadFile(saved); err != nil || string(got) != report {
+		t.Errorf("Saved file mismatch (err=%v, %d bytes)", err, len(got))
+	}
+
+	if err := SaveResponseBody(resp, filepath.Join(dir, "missing-dir", "x.csv")); err == nil {
+		t.Errorf("Expected an error saving into a missing directory")
+	}
+}
+
+func TestWithProxyAndLocalAddr(t *testing.T) {
+	var proxiedHost, proxiedPath string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A forward proxy receives the absolute target URL
+		proxiedHost, proxiedPath = r.URL.Host, r.URL.Path
+		io.WriteString(w, "via proxy")
+	}))
+	defer proxy.Close()
+
+	resp := SendRESTRequest("http://backend.invalid/orders", WithNoRecord(), WithProxy(proxy.URL))
+	ExpectStatusCode(resp, http.StatusOK)
+	if resp.Body != "via proxy" || proxiedHost != "backend.invalid" || proxiedPath != "/orders" {
+		t.Errorf("Expected the request to arrive at the proxy, got body=%q host=%q path=%q", resp.Body, proxiedHost, proxiedPath)
+	}
+
+	var remote string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		remote = r.RemoteAddr
+	}))
+	defer server.Close()
+
+	SendRESTRequest(server.URL, WithNoRecord(), WithLocalAddr("127.0.0.1"))
+	if host, _, _ := strings.Cut(remote, ":"); host != "127.0.0.1" {
+		t.Errorf("Expected the request from 127.0.0.1, got %q", remote)
+	}
+
+	defer func() {
+		if _, ok := recover().(TestError); !ok {
+			t.Errorf("Expected an invalid local address to fail")
+		}
+	}()
+	SendRESTRequest(server.URL, WithNoRecord(), WithLocalAddr("not-an-ip:x"))
+}
+
+func TestExpectResponseFraming(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/chunked" {
+			io.WriteString(w, "part one,")
+			w.(http.Flusher).Flush()
+			io.WriteString(w, "part two")
+			return
+		}
+		w.Header().Set("Content-Length", "5")
+		io.WriteString(w, "hello")
+	}))
+	defer server.Close()
+
+	fixed := SendRESTRequest(server.URL+"/fixed", WithNoRecord())
+	ExpectContentLength(fixed, 5)
+
+	chunked := SendRESTRequest(server.URL+"/chunked", WithNoRecord())
+	ExpectChunked(chunked)
+	if chunked.Body != "part one,part two" {
+		t.Errorf("Unexpected chunked body %q", chunked.Body)
+	}
+
+	ExpectFailure(func() { ExpectContentLength(fixed, 4) })
+	ExpectFailure(func() { ExpectContentLength(chunked, 17) })
+	ExpectFailure(func() { ExpectChunked(fixed) })
+}
+
+func TestExpectCookieAttributes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{
+			Name: "session", Value: "abc123", Path: "/app", Domain: "example.com",
+			MaxAge: 3600, HttpOnly: true, Secure: true, SameSite: http.SameSiteStrictMode,
+		})
+		http.SetCookie(w, &http.Cookie{Name: "theme", Value: "dark"})
+	}))
+	defer server.Close()
+
+	resp := SendRESTRequest(server.URL + "/login")
+	ExpectCookieAttributes(resp, "session", CookieAttrs{
+		HttpOnly: true, Secure: true, SameSite: http.SameSiteStrictMode,
+		Path: "/app", Domain: "example.com", MaxAge: 3600,
+	})
+	ExpectCookieAttributes(resp, "theme", CookieAttrs{})
+
+	ExpectFailure(func() { ExpectCookieAttributes(resp, "theme", CookieAttrs{HttpOnly: true}) })
+	ExpectFailure(func() { ExpectCookieAttributes(resp, "theme", CookieAttrs{Secure: true}) })
+	ExpectFailure(func() { ExpectCookieAttributes(resp, "session", CookieAttrs{SameSite: http.SameSiteLaxMode}) })
+	ExpectFailure(func() { ExpectCookieAttributes(resp, "session", CookieAttrs{Path: "/"}) })
+	ExpectFailure(func() { ExpectCookieAttributes(resp, "session", CookieAttrs{Domain: "other.com"}) })
+	ExpectFailure(func() { ExpectCookieAttributes(resp, "session", CookieAttrs{MaxAge: 60}) })
+	ExpectFailure(func() { ExpectCookieAttributes(resp, "missing", CookieAttrs{}) })
+}
+
+func TestRequestErrorClassification(t *testing.T) {
+	failure := func(url string) string {
+		var msg string
+		func() {
+			defer func() {
+				r := recover()
+				te, ok := r.(TestError)
+				if !ok {
+					t.Errorf("Expected a TestError from %s, got %v", url, r)
+					return
+				}
+				msg = te.Message
+			}()
+			SendRESTRequest(url)
+		}()
+		return msg
+	}
+
+	// A port that was just released refuses connections
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	closedURL := "http://" + ln.Addr().String() + "/"
+	ln.Close()
+	var errorLogs atomic.Int32
+	RegisterLogHandler(func(e LogEntry) {
+		if e.Type == LogTypeError && strings.Contains(e.Summary+e.Detail, closedURL) {
+			errorLogs.Add(1)
+		}
+	})
+	if msg := failure(closedURL); !strings.Contains(msg, "("+RequestErrorConnectionRefused+")") {
+		t.Errorf("Expected a connection refused failure, got %q", msg)
+	}
+	if n := errorLogs.Load(); n != 1 {
+		t.Errorf("Expected the failed request to be logged once, got %d error entries", n)
+	}
+
+	if msg := failure("http://integrate-tester.invalid/"); !strings.Contains(msg, "("+RequestErrorDNS+")") {
+		t.Errorf("Expected a DNS failure, got %q", msg)
+	}
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer slow.Close()
+	_, err = (&http.Client{Timeout: 20 * time.Millisecond}).Get(slow.URL)
+	if got := classifyRequestError(err); got != RequestErrorTimeout {
+		t.Errorf("Expected %q for a client timeout, got %q (%v)", RequestErrorTimeout, got, err)
+	}
+
+	if got := classifyRequestError(fmt.Errorf("something else")); got != RequestErrorOther {
+		t.Errorf("Expected %q for an unknown error, got %q", RequestErrorOther, got)
+	}
+}
+
+func TestExpectJsonBodyFieldAbsent(t *testing.T) {
+	resp := Response{Body: `{"user":{"name":"alice","password":"secret","token":null},"items":[{"id":1}]}`}
+
+	ExpectJsonBodyFieldAbsent(resp, "user.ssn")
+	ExpectJsonBodyFieldAbsent(resp, "profile.password")
+	ExpectJsonBodyFieldAbsent(resp, "items[0].secret")
+	ExpectJsonBodyFieldAbsentOrNull(resp, "user.ssn")
+	ExpectJsonBodyFieldAbsentOrNull(resp, "user.token")
+
+	ExpectFailure(func() { ExpectJsonBodyFieldAbsent(resp, "user.password") })
+	ExpectFailure(func() { ExpectJsonBodyFieldAbsentOrNull(resp, "user.password") })
+	ExpectFailure(func() { ExpectJsonBodyFieldAbsent(resp, "user.token") })
+	ExpectFailure(func() { ExpectJsonBodyFieldAbsent(resp, "user.name.first") })
+	ExpectFailure(func() { ExpectJsonBodyFieldAbsent(resp, "items[5].id") })
+	ExpectFailure(func() { ExpectJsonBodyFieldAbsent(Response{Body: `{`}, "password") })
+}
@@ -13,5 +13,5 @@ func Sleep(d time.Duration) {
 		return
 	}
 	Log(LogTypeInfo, "Sleep", fmt.Sprintf("Duration: %s", d))
-	time.Sleep(d)
+	clockSleep(d)
 }
@@ -0,0 +1,283 @@
+package v1
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SSEEvent is one Server-Sent Event read from a text/event-stream response.
+type SSEEvent struct {
+	ID    string
+	Event string
+	// Data joins the event's data lines with "\n".
+	Data  string
+	Retry string
+}
+
+// SSEStream is a live text/event-stream connection opened by StreamSSE. Read events with
+// ReadSSE and Close it when done.
+type SSEStream struct {
+	URL        string
+	StatusCode int
+	Header     map[string]string
+
+	events chan SSEEvent
+	ctx    context.Context
+	cancel context.CancelFunc
+	body   io.ReadCloser
+
+	mu  sync.Mutex
+	err error
+}
+
+// StreamSSE opens a Server-Sent Events stream at url and starts reading its events in the
+// background. It accepts the options of SendRESTRequest that apply to the request
+// (WithMethod, WithHeader, WithJSONBody, WithIgnoreServerSSL, WithProxy, WithLocalAddr,
+// WithNoFollowRedirects, ...) and sends "Accept: text/event-stream" unless a header sets
+// it. Non-2xx responses fail.
+func StreamSSE(url string, opts ...RESTRequestOption) *SSEStream {
+	cfg := restRequestConfig{
+		method:  http.MethodGet,
+		headers: make(map[string]string),
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&cfg)
+		}
+	}
+	if !hasHeader(cfg.headers, "Accept") {
+		cfg.headers["Accept"] = "text/event-stream"
+	}
+	if ua := defaultUserAgentValue(); ua != "" && !hasHeader(cfg.headers, "User-Agent") {
+		cfg.headers["User-Agent"] = ua
+	}
+
+	if !cfg.noRecord && requestRecordingEnabled() {
+		RecordAction(fmt.Sprintf("SSE Stream: %s %s", cfg.method, url), func() {
+			StreamSSE(url, opts...)
+		})
+	}
+	if IsDryRun() {
+		return &SSEStream{URL: url}
+	}
+
+	var bodyReader io.Reader
+	if cfg.bodyReader != nil {
+		bodyReader = cfg.bodyReader
+	} else if len(cfg.body) > 0 {
+		bodyReader = bytes.NewReader(cfg.body)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(ctx, cfg.method, url, bodyReader)
+	if err != nil {
+		cancel()
+		Fail("Request build failed: %v", err)
+	}
+	for k, v := range cfg.headers {
+		req.Header.Set(k, v)
+	}
+
+	client := newHTTPClient(&cfg, url)
+
+	Logf(LogTypeRequest, "Opening SSE stream: %s %s", cfg.method, url)
+	resp, err := client.Do(req)
+	if err != nil {
+		cancel()
+		Fail("SSE stream to %s failed (%s): %v", url, classifyRequestError(err), err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		cancel()
+		Fail("SSE stream to %s failed: status %d\nBody: %s", url, resp.StatusCode, body)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/event-stream") {
+		Logf(LogTypeInfo, "SSE stream %s has Content-Type %q, expected text/event-stream", url, ct)
+	}
+
+	header := make(map[string]string)
+	for k, v := range resp.Header {
+		if len(v) > 0 {
+			header[k] = v[0]
+		}
+	}
+	s := &SSEStream{
+		URL:        url,
+		StatusCode: resp.StatusCode,
+		Header:     header,
+		events:     make(chan SSEEvent, 64),
+		ctx:        ctx,
+		cancel:     cancel,
+		body:       resp.Body,
+	}
+	go s.readEvents()
+	Logf(LogTypeRequest, "SSE stream %s opened with status %d", url, resp.StatusCode)
+	return s
+}
+
+// readEvents parses the stream until it ends or is closed, then closes s.events.
+func (s *SSEStream) readEvents() {
+	defer close(s.events)
+	err := parseSSE(s.body, func(ev SSEEvent) {
+		select {
+		case s.events <- ev:
+		case <-s.ctx.Done():
+		}
+	})
+	s.mu.Lock()
+	s.err = err
+	s.mu.Unlock()
+}
+
+// Close ends the stream. It is safe to call more than once and on a dry-run stream.
+func (s *SSEStream) Close() {
+	if s == nil || s.cancel == nil {
+		return
+	}
+	s.cancel()
+	s.body.Close()
+}
+
+func (s *SSEStream) readErr() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// ReadSSE waits for the next count events of the stream and returns them. It fails if the
+// stream ends or timeout passes before count events arrive.
+func ReadSSE(stream *SSEStream, count int, timeout time.Duration) []SSEEvent {
+	if IsDryRun() {
+		return nil
+	}
+	if stream == nil || stream.events == nil {
+		Fail("ReadSSE failed: stream is not open")
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	events := make([]SSEEvent, 0, count)
+	for len(events) < count {
+		select {
+		case ev, ok := <-stream.events:
+			if !ok {
+				reason := "stream ended"
+				if err := stream.readErr(); err != nil {
+					reason = fmt.Sprintf("stream ended: %v", err)
+				}
+				Fail("ReadSSE failed for %s: %s after %d of %d events", stream.URL, reason, len(events), count)
+			}
+			events = append(events, ev)
+		case <-timer.C:
+			Fail("ReadSSE failed for %s: timed out after %v with %d of %d events", stream.URL, timeout, len(events), count)
+		}
+	}
+
+	lines := make([]string, len(events))
+	for i, ev := range events {
+		lines[i] = fmt.Sprintf("[%d] %s", i, formatSSEEvent(ev))
+	}
+	Log(LogTypeRequest, fmt.Sprintf("Received %d SSE events from %s", len(events), stream.URL), strings.Join(lines, "\n"))
+	return events
+}
+
+// ParseSSE parses a complete text/event-stream body, e.g. from a stream the server closes
+// and that was read with SendRESTRequest.
+func ParseSSE(resp Response) []SSEEvent {
+	var events []SSEEvent
+	parseSSE(strings.NewReader(resp.Body), func(ev SSEEvent) { events = append(events, ev) })
+	return events
+}
+
+// ExpectSSEEvent asserts that field ("id", "event", "data" or "retry") of events[index]
+// equals value.
+func ExpectSSEEvent(events []SSEEvent, index int, field, value string) {
+	if IsDryRun() {
+		return
+	}
+	if index < 0 || index >= len(events) {
+		Fail("ExpectSSEEvent failed: index %d out of range (%d events)", index, len(events))
+	}
+	ev := events[index]
+	var actual string
+	switch strings.ToLower(field) {
+	case "id":
+		actual = ev.ID
+	case "event":
+		actual = ev.Event
+	case "data":
+		actual = ev.Data
+	case "retry":
+		actual = ev.Retry
+	default:
+		Fail("ExpectSSEEvent failed: unknown field %q (use id, event, data or retry)", field)
+	}
+	if actual != value {
+		Fail("ExpectSSEEvent failed for event %d field %s: expected %q, got %q", index, field, value, actual)
+	}
+	Logf(LogTypeExpect, "SSE event %d %s is %q - PASSED", index, field, value)
+}
+
+func formatSSEEvent(ev SSEEvent) string {
+	var parts []string
+	if ev.ID != "" {
+		parts = append(parts, "id="+ev.ID)
+	}
+	if ev.Event != "" {
+		parts = append(parts, "event="+ev.Event)
+	}
+	if ev.Retry != "" {
+		parts = append(parts, "retry="+ev.Retry)
+	}
+	parts = append(parts, "data="+ev.Data)
+	return strings.Join(parts, " ")
+}
+
+// parseSSE reads events from r as described by the HTML Server-Sent Events spec and passes
+// each to emit. Comment lines are skipped, events without data are not dispatched and each
+// event carries the last event ID seen so far.
+func parseSSE(r io.Reader, emit func(SSEEvent)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	var ev SSEEvent
+	var data []string
+	hasData := false
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if line == "" {
+			if hasData {
+				ev.Data = strings.Join(data, "\n")
+				emit(ev)
+			}
+			// The last event ID carries over to later events until an "id" field changes it
+			ev = SSEEvent{ID: ev.ID}
+			data = nil
+			hasData = false
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "id":
+			ev.ID = value
+		case "event":
+			ev.Event = value
+		case "data":
+			data = append(data, value)
+			hasData = true
+		case "retry":
+			ev.Retry = value
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,112 @@
+package v1
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSSE(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != "text/event-stream" {
+			http.Error(w, "expected Accept: text/event-stream", http.StatusNotAcceptable)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher := w.(http.Flusher)
+		fmt.Fprint(w, ": connected\n\n")
+		fmt.Fprint(w, "retry: 3000\n\n")
+		for i := 1; i <= 3; i++ {
+			fmt.Fprintf(w, "id: %d\nevent: order\ndata: {\"order\":%d}\n\n", i, i)
+			flusher.Flush()
+		}
+		fmt.Fprint(w, "event: done\r\ndata: line one\r\ndata: line two\r\n\r\n")
+		flusher.Flush()
+		// Keep the connection open like a real stream until the client goes away
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	stream := StreamSSE(server.URL)
+	defer stream.Close()
+	if stream.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", stream.StatusCode)
+	}
+
+	events := ReadSSE(stream, 3, 2*time.Second)
+	if len(events) != 3 {
+		t.Fatalf("Expected 3 events, got %+v", events)
+	}
+	ExpectSSEEvent(events, 0, "id", "1")
+	ExpectSSEEvent(events, 0, "event", "order")
+	ExpectSSEEvent(events, 0, "data", `{"order":1}`)
+	ExpectSSEEvent(events, 2, "data", `{"order":3}`)
+
+	last := ReadSSE(stream, 1, 2*time.Second)
+	ExpectSSEEvent(last, 0, "event", "done")
+	ExpectSSEEvent(last, 0, "data", "line one\nline two")
+
+	ExpectFailure(func() { ExpectSSEEvent(events, 1, "data", `{"order":9}`) })
+	ExpectFailure(func() { ExpectSSEEvent(events, 3, "data", "") })
+	ExpectFailure(func() { ExpectSSEEvent(events, 0, "name", "") })
+	ExpectFailure(func() { ReadSSE(stream, 1, 50*time.Millisecond) })
+	ExpectFailure(func() { StreamSSE(server.URL, WithHeader("Accept", "application/json")) })
+
+	stream.Close()
+	ExpectFailure(func() { ReadSSE(stream, 1, time.Second) })
+}
+
+func TestSSEClientOptions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			http.Redirect(w, r, "/events", http.StatusFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: hello\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	stream := StreamSSE(server.URL + "/old")
+	ExpectSSEEvent(ReadSSE(stream, 1, 2*time.Second), 0, "data", "hello")
+	stream.Close()
+
+	// The redirect is returned as is, so the stream fails on its 302 status
+	ExpectFailure(func() { StreamSSE(server.URL+"/old", WithNoFollowRedirects()) })
+}
+
+func TestParseSSE(t *testing.T) {
+	body := strings.Join([]string{
+		"id: 7",
+		"data: first",
+		"",
+		"event: ping",
+		"",
+		"data:no-space",
+		"data",
+		"",
+		"id: 8",
+		"data: second",
+		"",
+		"id",
+		"data: cleared",
+		"",
+		"data: unterminated",
+	}, "\n")
+	events := ParseSSE(Response{Body: body})
+	if len(events) != 4 {
+		t.Fatalf("Expected 4 events, got %+v", events)
+	}
+	ExpectSSEEvent(events, 0, "id", "7")
+	ExpectSSEEvent(events, 0, "data", "first")
+	ExpectSSEEvent(events, 1, "data", "no-space\n")
+	// The last event ID persists until an id field replaces or clears it
+	ExpectSSEEvent(events, 1, "id", "7")
+	ExpectSSEEvent(events, 2, "id", "8")
+	ExpectSSEEvent(events, 3, "id", "")
+}
@@ -1,8 +1,14 @@
 package v1
 
 import (
+	"bytes"
 	"fmt"
+	"runtime"
+	"runtime/pprof"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 )
 
 // StageFunc represents the function to be executed in a stage.
@@ -14,6 +20,51 @@ type StageDef struct {
 	Func StageFunc
 }
 
+// Stage statuses reported in StageResult.Status.
+const (
+	StageStatusNotRun  = "Not Run"
+	StageStatusRunning = "Running..."
+	StageStatusPassed  = "PASSED"
+	StageStatusFailed  = "FAILED"
+	// StageStatusTimedOut marks a stage stopped waiting for after its stage timeout or the
+	// suite deadline; it counts as failed.
+	StageStatusTimedOut = "FAILED (timeout)"
+	// StageStatusSkippedDeadline marks stages not started because the suite deadline passed.
+	StageStatusSkippedDeadline = "SKIPPED (deadline)"
+	// StageStatusSkipped marks stages not started because an earlier stage failed under FailFast.
+	StageStatusSkipped = "SKIPPED"
+)
+
+// RunOptions configures RunAllWithOptions.
+type RunOptions struct {
+	// FailFast stops at the first failing stage and marks the remaining stages
+	// StageStatusSkipped (handy locally). Without it every stage runs (suited to CI).
+	FailFast bool
+	// LeakCheck compares runtime.NumGoroutine() before and after the run and logs an error
+	// with the goroutine stacks when it grew by more than LeakThreshold, which usually
+	// means a mock server, DB connection or worker was not stopped or closed.
+	LeakCheck     bool
+	LeakThreshold int
+	// StageTimeout bounds each stage; a stage still running after it is marked
+	// StageStatusTimedOut and the run moves on. Zero means no limit.
+	StageTimeout time.Duration
+	// Deadline caps the whole run: no stage starts after it, a running stage is timed out
+	// when it passes, and the remaining stages are marked StageStatusSkippedDeadline.
+	// Zero means no deadline.
+	Deadline time.Duration
+}
+
+// leakSettleTimeout is how long the leak check waits for goroutines that are still exiting.
+const leakSettleTimeout = time.Second
+
+// StageResult is the outcome of the last run of a stage.
+type StageResult struct {
+	Name     string
+	Status   string
+	Err      error
+	Duration time.Duration
+}
+
 // Action represents a runnable operation within a stage.
 type Action struct {
 	Summary string
@@ -33,8 +84,43 @@ var (
 	actionHandlers []func()
 	// isDryRun indicates if the tester is in discovery mode
 	isDryRun bool
+	// currentTester is the tester whose stage is currently running (used for log buffering)
+	currentTester *Tester
+	// abandonedStages maps the goroutine of each timed-out stage that is still running to
+	// that stage, so its late logs stay with it instead of the stage running now
+	abandonedStages = make(map[uint64]stageRef)
 )
 
+// stageRef names a stage of a tester.
+type stageRef struct {
+	tester *Tester
+	stage  string
+}
+
+// runningStageLocked returns the stage that code on the calling goroutine belongs to, and
+// whether that stage has timed out. The caller must hold actionMu.
+func runningStageLocked() (stageRef, bool) {
+	if len(abandonedStages) > 0 {
+		if ref, ok := abandonedStages[goroutineID()]; ok {
+			return ref, true
+		}
+	}
+	return stageRef{tester: currentTester, stage: currentStage}, false
+}
+
+// goroutineID returns the id of the calling goroutine, parsed from its stack header
+// ("goroutine 18 [running]:").
+func goroutineID() uint64 {
+	var buf [64]byte
+	header := buf[:runtime.Stack(buf[:], false)]
+	header = bytes.TrimPrefix(header, []byte("goroutine "))
+	if i := bytes.IndexByte(header, ' '); i >= 0 {
+		header = header[:i]
+	}
+	id, _ := strconv.ParseUint(string(header), 10, 64)
+	return id
+}
+
 // IsDryRun checks if the tester is in dry run mode.
 func IsDryRun() bool {
 	actionMu.Lock()
@@ -42,7 +128,8 @@ func IsDryRun() bool {
 	return isDryRun
 }
 
-// RecordAction registers an operation for the current stage.
+// RecordAction registers an operation for the current stage. Actions recorded by a stage
+// after it timed out are dropped.
 func RecordAction(summary string, fn func()) {
 	actionMu.Lock()
 	defer actionMu.Unlock()
@@ -50,6 +137,9 @@ func RecordAction(summary string, fn func()) {
 	if !isRecording || currentStage == "" {
 		return
 	}
+	if _, abandoned := runningStageLocked(); abandoned {
+		return
+	}
 
 	stageActions[currentStage] = append(stageActions[currentStage], Action{
 		Summary: summary,
@@ -85,26 +175,115 @@ func notifyActionHandlers() {
 
 // Tester is the main struct for the integration test library.
 type Tester struct {
-	Stages []StageDef
-	mu     sync.Mutex
+	Stages    []StageDef
+	stageLogs map[string][]LogEntry
+	// artifacts holds what AttachArtifact stored during the last run of each stage
+	artifacts map[string][]Artifact
+	results   map[string]StageResult
+	// actionStatus maps an action -> status of its last manual run
+	actionStatus map[actionKey]string
+	// subscribers receive TestEvents (see Subscribe)
+	subscribers []chan TestEvent
+	mu          sync.Mutex
+
+	// Logger, when set, receives every entry logged by this tester's runs, stages and
+	// manual actions instead of the console and the handlers of RegisterLogHandler, so
+	// several testers in one process keep separate logs. Set it before running. Entries are
+	// attributed through the package-wide running stage, so testers must run one at a time:
+	// while two run concurrently, an entry may reach the other tester's Logger.
+	Logger LogHandler
 }
 
 // NewTester creates a new Tester instance.
 func NewTester() *Tester {
 	return &Tester{
-		Stages: make([]StageDef, 0),
+		Stages:       make([]StageDef, 0),
+		stageLogs:    make(map[string][]LogEntry),
+		results:      make(map[string]StageResult),
+		actionStatus: make(map[actionKey]string),
 	}
 }
 
+// StageLogs returns the log entries captured during the last run of the named stage.
+func (t *Tester) StageLogs(name string) []LogEntry {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	// Return copy to be safe
+	src := t.stageLogs[name]
+	dst := make([]LogEntry, len(src))
+	copy(dst, src)
+	return dst
+}
+
+// log records an entry for this tester: it goes to t.Logger when set, otherwise to the
+// global destinations of Log. Entries logged while one of its stages runs keep the stage.
+func (t *Tester) log(typ LogType, summary, detail string) {
+	actionMu.Lock()
+	stage := ""
+	if currentTester == t {
+		stage = currentStage
+	}
+	actionMu.Unlock()
+	dispatchLog(t, LogEntry{Type: typ, Summary: summary, Detail: detail, Stage: stage, Time: clockNow()})
+}
+
+func (t *Tester) appendStageLog(entry LogEntry) {
+	t.mu.Lock()
+	if t.stageLogs == nil {
+		t.stageLogs = make(map[string][]LogEntry)
+	}
+	t.stageLogs[entry.Stage] = append(t.stageLogs[entry.Stage], entry)
+	t.mu.Unlock()
+	t.emit(TestEvent{Type: EventLog, Stage: entry.Stage, Log: &entry})
+}
+
 // Stage registers a new stage.
+// Stage names must be unique; registering the same name twice fails.
 func (t *Tester) Stage(name string, fn StageFunc) {
 	t.mu.Lock()
-	defer t.mu.Unlock()
+	for _, s := range t.Stages {
+		if s.Name == name {
+			// Unlock before failing since Fail logs through the tester
+			t.mu.Unlock()
+			Fail("Stage %q is already registered; stage names must be unique", name)
+			return
+		}
+	}
 	t.Stages = append(t.Stages, StageDef{Name: name, Func: fn})
+	t.mu.Unlock()
+}
+
+// StageEach registers one stage per case, named "name[case]" (the case formatted with %v),
+// each running fn with its case. The stages run and are reported independently, so one
+// failing case does not hide the others. Cases must format to distinct names; duplicates
+// fail before any case is registered.
+func (t *Tester) StageEach(name string, cases []interface{}, fn func(c interface{})) {
+	names := make([]string, len(cases))
+	seen := make(map[string]bool, len(cases))
+	for i, c := range cases {
+		names[i] = fmt.Sprintf("%s[%v]", name, c)
+		if seen[names[i]] {
+			Fail("StageEach %q has duplicate case %q; cases must format to distinct names", name, names[i])
+			return
+		}
+		seen[names[i]] = true
+	}
+	for i, c := range cases {
+		t.Stage(names[i], func() { fn(c) })
+	}
 }
 
 // RunStageByName runs a specific stage by name.
-func (t *Tester) RunStageByName(name string) (err error) {
+func (t *Tester) RunStageByName(name string) error {
+	return t.runStage(name, 0)
+}
+
+// runStage runs the named stage. With a positive timeout the stage runs on its own
+// goroutine and is reported StageStatusTimedOut if it has not returned in time; it is left
+// running in the background, since Go cannot stop it. Until it returns, its later logs
+// still go to it and its later actions are dropped, so neither lands in the stage running
+// by then. Goroutines the stage started itself are not tracked.
+func (t *Tester) runStage(name string, timeout time.Duration) (err error) {
 	t.mu.Lock()
 	var fn StageFunc
 	for _, s := range t.Stages {
@@ -119,43 +298,372 @@ func (t *Tester) RunStageByName(name string) (err error) {
 		return fmt.Errorf("stage %s not found", name)
 	}
 
+	// Clear logs captured by a previous run of this stage
+	t.mu.Lock()
+	if t.stageLogs == nil {
+		t.stageLogs = make(map[string][]LogEntry)
+	}
+	t.stageLogs[name] = nil
+	delete(t.artifacts, name)
+	// Actions are re-recorded below, so statuses keyed by their index no longer apply
+	for key := range t.actionStatus {
+		if key.stage == name {
+			delete(t.actionStatus, key)
+		}
+	}
+	t.mu.Unlock()
+	t.setResult(StageResult{Name: name, Status: StageStatusRunning})
+	start := clockNow()
+	t.emit(TestEvent{Type: EventStageStarted, Stage: name, Time: start})
+
 	// Setup context for recording
 	actionMu.Lock()
 	currentStage = name
+	currentTester = t
 	isRecording = true
 	stageActions[name] = []Action{} // Clear previous actions
 	notifyActionHandlers()
 	actionMu.Unlock()
 
-	Log(LogTypeStage, fmt.Sprintf("Running Stage: %s", name), "")
+	t.log(LogTypeStage, fmt.Sprintf("Running Stage: %s", name), "")
 
 	// Ensure recording stops after stage
 	defer func() {
 		actionMu.Lock()
 		isRecording = false
 		currentStage = ""
+		currentTester = nil
 		actionMu.Unlock()
 	}()
 
-	// Error handling in stages should be handled by panic/recover or other means if we want to stop execution
-	// For this lib, we assume stages might panic on failure.
+	// Stages fail by panicking (Fail panics with a TestError)
+	var recovered interface{}
+	timedOut := false
+	if timeout > 0 {
+		done := make(chan interface{}, 1)
+		started := make(chan uint64, 1)
+		go func() {
+			id := goroutineID()
+			started <- id
+			r := callStage(fn)
+			// Under actionMu the runner either sees the result or has registered the
+			// goroutine as abandoned, so the entry is always removed
+			actionMu.Lock()
+			done <- r
+			delete(abandonedStages, id)
+			actionMu.Unlock()
+		}()
+		id := <-started
+		timer := time.NewTimer(timeout)
+		select {
+		case recovered = <-done:
+		case <-timer.C:
+			actionMu.Lock()
+			select {
+			case recovered = <-done:
+			default:
+				timedOut = true
+				abandonedStages[id] = stageRef{tester: t, stage: name}
+			}
+			actionMu.Unlock()
+		}
+		timer.Stop()
+	} else {
+		recovered = callStage(fn)
+	}
+
+	status := StageStatusPassed
+	switch {
+	case timedOut:
+		t.log(LogTypeStage, fmt.Sprintf("Stage %s TIMED OUT", name), fmt.Sprintf("still running after %v", timeout))
+		err = fmt.Errorf("timed out after %v", timeout)
+		status = StageStatusTimedOut
+	case recovered != nil:
+		if te, ok := recovered.(TestError); ok {
+			t.log(LogTypeStage, fmt.Sprintf("Stage %s FAILED", name), te.Message)
+			err = fmt.Errorf("failed: %s", te.Message)
+		} else {
+			t.log(LogTypeStage, fmt.Sprintf("Stage %s FAILED (Crash)", name), fmt.Sprintf("%v", recovered))
+			err = fmt.Errorf("panic: %v", recovered)
+		}
+		status = StageStatusFailed
+	default:
+		t.log(LogTypeStage, fmt.Sprintf("Stage %s PASSED", name), "")
+	}
+
+	t.setResult(StageResult{Name: name, Status: status, Err: err, Duration: clockNow().Sub(start)})
+	if err != nil {
+		t.emit(TestEvent{Type: EventStageFailed, Stage: name, Error: err.Error()})
+	} else {
+		t.emit(TestEvent{Type: EventStagePassed, Stage: name})
+	}
+	return err
+}
+
+// callStage runs fn and returns the value it panicked with, or nil.
+func callStage(fn StageFunc) (recovered interface{}) {
+	defer func() { recovered = recover() }()
+	fn()
+	return nil
+}
+
+// RunStageIsolated runs only the named stage and returns the log entries it produced,
+// e.g. to re-run a failing stage while debugging. Only that stage's status and logs are
+// updated; other stages are neither run nor reset. It returns nil for an unknown stage.
+func (t *Tester) RunStageIsolated(name string) []LogEntry {
+	if err := t.RunStageByName(name); err != nil && t.StageStatus(name) == StageStatusNotRun {
+		return nil
+	}
+	return t.StageLogs(name)
+}
+
+// RunAll runs every stage in registration order, continuing past failures,
+// and returns the results.
+func (t *Tester) RunAll() []StageResult {
+	return t.runAll(RunOptions{})
+}
+
+// RunAllWithOptions runs every stage in registration order according to opts and returns
+// the results. A summary of passed, failed and skipped stages is logged at the end.
+func (t *Tester) RunAllWithOptions(opts RunOptions) []StageResult {
+	return t.runAll(opts)
+}
+
+// RunAllWithDeadline is like RunAll but caps the whole run at d: no stage starts after
+// it, a stage still running when it passes is marked StageStatusTimedOut, and the
+// remaining stages are marked StageStatusSkippedDeadline. Use RunAllWithOptions to
+// combine it with a per-stage timeout.
+func (t *Tester) RunAllWithDeadline(d time.Duration) []StageResult {
+	return t.runAll(RunOptions{Deadline: d})
+}
+
+// runAll runs the stages in order according to opts.
+func (t *Tester) runAll(opts RunOptions) []StageResult {
+	// The deadline caps real run time like the stage timers, whatever the package clock does
+	var deadline time.Time
+	if opts.Deadline > 0 {
+		deadline = time.Now().Add(opts.Deadline)
+	}
+	t.mu.Lock()
+	names := make([]string, len(t.Stages))
+	for i, s := range t.Stages {
+		names[i] = s.Name
+	}
+	t.mu.Unlock()
+
+	goroutinesBefore := runtime.NumGoroutine()
+	failedStage := ""
+	deadlinePassed := false
+	for _, name := range names {
+		if failedStage != "" {
+			t.log(LogTypeStage, fmt.Sprintf("Stage %s SKIPPED", name), fmt.Sprintf("fail-fast after stage %s failed", failedStage))
+			t.setResult(StageResult{Name: name, Status: StageStatusSkipped})
+			continue
+		}
+		timeout, boundByDeadline := opts.StageTimeout, false
+		if !deadline.IsZero() {
+			remaining := time.Until(deadline)
+			if deadlinePassed || remaining <= 0 {
+				t.log(LogTypeStage, fmt.Sprintf("Stage %s SKIPPED", name), "suite deadline passed")
+				t.setResult(StageResult{Name: name, Status: StageStatusSkippedDeadline})
+				continue
+			}
+			if timeout <= 0 || remaining < timeout {
+				timeout, boundByDeadline = remaining, true
+			}
+		}
+		err := t.runStage(name, timeout)
+		if err != nil && opts.FailFast {
+			failedStage = name
+		}
+		if boundByDeadline && t.StageStatus(name) == StageStatusTimedOut {
+			deadlinePassed = true
+		}
+	}
+
+	results := t.LastResults()
+	var passed, skipped int
+	var failed []string
+	for _, r := range results {
+		switch r.Status {
+		case StageStatusPassed:
+			passed++
+		case StageStatusFailed, StageStatusTimedOut:
+			failed = append(failed, r.Name)
+		case StageStatusSkipped, StageStatusSkippedDeadline:
+			skipped++
+		}
+	}
+	t.log(LogTypeStage, fmt.Sprintf("Run finished: %d passed, %d failed, %d skipped", passed, len(failed), skipped), strings.Join(failed, "\n"))
+	if opts.LeakCheck {
+		t.checkGoroutineLeak(goroutinesBefore, opts.LeakThreshold)
+	}
+	return results
+}
+
+// checkGoroutineLeak logs an error with the goroutine stacks when more than threshold
+// goroutines were added since before. Goroutines still exiting get leakSettleTimeout
+// (real time, not the package clock) to finish first.
+func (t *Tester) checkGoroutineLeak(before, threshold int) {
+	after := runtime.NumGoroutine()
+	for waited := time.Duration(0); after-before > threshold && waited < leakSettleTimeout; waited += 10 * time.Millisecond {
+		time.Sleep(10 * time.Millisecond)
+		after = runtime.NumGoroutine()
+	}
+	if after-before <= threshold {
+		t.log(LogTypeInfo, fmt.Sprintf("Goroutine check: %d before, %d after - PASSED", before, after), "")
+		return
+	}
+	var stacks bytes.Buffer
+	pprof.Lookup("goroutine").WriteTo(&stacks, 1)
+	t.log(LogTypeError, fmt.Sprintf("Goroutine leak: %d before the run, %d after (+%d, threshold %d)", before, after, after-before, threshold), stacks.String())
+}
+
+// LastResults returns the result of the last run of each stage, in registration order.
+// Stages that have not run are reported as StageStatusNotRun.
+func (t *Tester) LastResults() []StageResult {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	results := make([]StageResult, len(t.Stages))
+	for i, s := range t.Stages {
+		if r, ok := t.results[s.Name]; ok {
+			results[i] = r
+		} else {
+			results[i] = StageResult{Name: s.Name, Status: StageStatusNotRun}
+		}
+	}
+	return results
+}
+
+// AllPassed reports whether every registered stage passed on its last run.
+func (t *Tester) AllPassed() bool {
+	for _, r := range t.LastResults() {
+		if r.Status != StageStatusPassed {
+			return false
+		}
+	}
+	return true
+}
+
+// StageStatus returns the status of the last run of the named stage.
+func (t *Tester) StageStatus(name string) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if r, ok := t.results[name]; ok {
+		return r.Status
+	}
+	return StageStatusNotRun
+}
+
+// actionKey identifies the action at index idx of a stage.
+type actionKey struct {
+	stage string
+	idx   int
+}
+
+// ActionUID returns the identifier of the action at index idx of a stage ("StageName:Index").
+func ActionUID(stageName string, idx int) string {
+	return fmt.Sprintf("%s:%d", stageName, idx)
+}
+
+// ParseActionUID splits an action UID built by ActionUID into its stage name and index.
+// The index follows the last ":", so stage names may contain ":" themselves.
+func ParseActionUID(uid string) (stageName string, idx int, ok bool) {
+	i := strings.LastIndex(uid, ":")
+	if i < 0 {
+		return "", 0, false
+	}
+	idx, err := strconv.Atoi(uid[i+1:])
+	if err != nil || idx < 0 {
+		return "", 0, false
+	}
+	return uid[:i], idx, true
+}
+
+// ActionStatus returns the status of the last manual run of the action with the given UID.
+// Actions that have not been run (or whose stage has since been re-run) report StageStatusNotRun.
+func (t *Tester) ActionStatus(uid string) string {
+	stageName, idx, ok := ParseActionUID(uid)
+	if !ok {
+		return StageStatusNotRun
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if st, ok := t.actionStatus[actionKey{stageName, idx}]; ok {
+		return st
+	}
+	return StageStatusNotRun
+}
+
+func (t *Tester) setActionStatus(key actionKey, status string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.actionStatus == nil {
+		t.actionStatus = make(map[actionKey]string)
+	}
+	t.actionStatus[key] = status
+}
+
+// RunAction runs a single recorded action by UID and records its status.
+// A failure (TestError or any other panic) is returned as an error instead of propagating.
+func (t *Tester) RunAction(uid string) (err error) {
+	stageName, idx, ok := ParseActionUID(uid)
+	if !ok {
+		return fmt.Errorf("invalid action uid %q", uid)
+	}
+	actions := GetStageActions(stageName)
+	if idx >= len(actions) {
+		return fmt.Errorf("action %s not found", uid)
+	}
+	action := actions[idx]
+	key := actionKey{stageName, idx}
+
+	t.setActionStatus(key, StageStatusRunning)
 	defer func() {
 		if r := recover(); r != nil {
 			if te, ok := r.(TestError); ok {
-				Log(LogTypeStage, fmt.Sprintf("Stage %s FAILED", name), te.Message)
-				err = fmt.Errorf("failed: %s", te.Message)
+				err = fmt.Errorf("%s", te.Message)
 			} else {
-				Log(LogTypeStage, fmt.Sprintf("Stage %s FAILED (Crash)", name), fmt.Sprintf("%v", r))
-				err = fmt.Errorf("panic: %v", r)
+				err = fmt.Errorf("%v", r)
 			}
-		} else {
-			Log(LogTypeStage, fmt.Sprintf("Stage %s PASSED", name), "")
+			t.log(LogTypeInfo, "Manual Run FAILED: "+action.Summary, err.Error())
+			t.setActionStatus(key, StageStatusFailed)
+			return
 		}
+		t.log(LogTypeInfo, "Manual Run PASSED: "+action.Summary, "")
+		t.setActionStatus(key, StageStatusPassed)
 	}()
-	fn()
+
+	// Individual runs don't affect the stage status
+	t.log(LogTypeInfo, "Manual Run: "+action.Summary, "")
+
+	// Route the action's own logs to this tester unless a stage run owns them
+	actionMu.Lock()
+	owned := currentTester == nil
+	if owned {
+		currentTester = t
+	}
+	actionMu.Unlock()
+	defer func() {
+		if owned {
+			actionMu.Lock()
+			currentTester = nil
+			actionMu.Unlock()
+		}
+	}()
+	action.Func()
 	return nil
 }
 
+func (t *Tester) setResult(r StageResult) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.results == nil {
+		t.results = make(map[string]StageResult)
+	}
+	t.results[r.Name] = r
+}
+
 // DryRunAll executes all stages in dry run mode to discover actions.
 func (t *Tester) DryRunAll() {
 	for _, s := range t.Stages {
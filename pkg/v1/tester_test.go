@@ -1,8 +1,12 @@
 package v1
 
 import (
+	"reflect"
+	"runtime"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestTester(t *testing.T) {
@@ -100,3 +104,603 @@ func TestDryRunWithMockClient(t *testing.T) {
 		t.Fatalf("expected actions recorded during dry-run")
 	}
 }
+
+func TestStageLogs(t *testing.T) {
+	tester := NewTester()
+	tester.Stage("LogStage", func() {
+		Log(LogTypeInfo, "first entry", "detail-1")
+		Logf(LogTypeInfo, "second entry %d", 2)
+	})
+	tester.Stage("OtherStage", func() {
+		Log(LogTypeInfo, "other entry", "")
+	})
+
+	if err := tester.RunStageByName("LogStage"); err != nil {
+		t.Fatalf("LogStage failed: %v", err)
+	}
+	if err := tester.RunStageByName("OtherStage"); err != nil {
+		t.Fatalf("OtherStage failed: %v", err)
+	}
+
+	logs := tester.StageLogs("LogStage")
+	var summaries []string
+	for _, l := range logs {
+		if l.Stage != "LogStage" {
+			t.Errorf("Expected entry stage 'LogStage', got '%s'", l.Stage)
+		}
+		summaries = append(summaries, l.Summary)
+	}
+	joined := strings.Join(summaries, "|")
+	if !strings.Contains(joined, "first entry|second entry 2") {
+		t.Errorf("Expected stage logs to contain both entries in order, got %v", summaries)
+	}
+	if strings.Contains(joined, "other entry") {
+		t.Errorf("LogStage logs should not contain entries from OtherStage, got %v", summaries)
+	}
+
+	// Re-running the stage replaces the previous buffer
+	if err := tester.RunStageByName("LogStage"); err != nil {
+		t.Fatalf("LogStage rerun failed: %v", err)
+	}
+	if got := len(tester.StageLogs("LogStage")); got != len(logs) {
+		t.Errorf("Expected %d entries after rerun, got %d", len(logs), got)
+	}
+
+	if got := tester.StageLogs("Missing"); len(got) != 0 {
+		t.Errorf("Expected no logs for unknown stage, got %d", len(got))
+	}
+}
+
+func TestStageDuplicateName(t *testing.T) {
+	tester := NewTester()
+	tester.Stage("Setup", func() {})
+
+	func() {
+		defer func() {
+			r := recover()
+			if r == nil {
+				t.Fatal("Expected duplicate stage registration to panic")
+			}
+			te, ok := r.(TestError)
+			if !ok {
+				t.Fatalf("Unexpected panic type: %T", r)
+			}
+			if !strings.Contains(te.Message, `"Setup"`) {
+				t.Errorf("Expected message to name the stage, got: %s", te.Message)
+			}
+		}()
+		tester.Stage("Setup", func() {})
+	}()
+
+	if len(tester.Stages) != 1 {
+		t.Errorf("Expected 1 stage after duplicate registration, got %d", len(tester.Stages))
+	}
+}
+
+func TestRunAllResults(t *testing.T) {
+	tester := NewTester()
+	tester.Stage("First", func() {})
+	tester.Stage("Second", func() { Fail("boom") })
+	tester.Stage("Third", func() {})
+
+	// Nothing has run yet
+	for _, r := range tester.LastResults() {
+		if r.Status != StageStatusNotRun {
+			t.Errorf("Expected %s to be %q before running, got %q", r.Name, StageStatusNotRun, r.Status)
+		}
+	}
+	if tester.AllPassed() {
+		t.Error("Expected AllPassed to be false before running")
+	}
+
+	results := tester.RunAll()
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 results, got %d", len(results))
+	}
+
+	want := []string{StageStatusPassed, StageStatusFailed, StageStatusPassed}
+	for i, r := range results {
+		if r.Status != want[i] {
+			t.Errorf("Stage %s: expected %q, got %q", r.Name, want[i], r.Status)
+		}
+	}
+	if results[1].Err == nil || !strings.Contains(results[1].Err.Error(), "boom") {
+		t.Errorf("Expected Second to carry the failure error, got %v", results[1].Err)
+	}
+	if tester.AllPassed() {
+		t.Error("Expected AllPassed to be false after a mixed run")
+	}
+	if tester.StageStatus("Second") != StageStatusFailed {
+		t.Errorf("Expected StageStatus(Second) FAILED, got %q", tester.StageStatus("Second"))
+	}
+
+	// Re-running the failed stage after a fix updates the result
+	tester.Stages[1].Func = func() {}
+	if err := tester.RunStageByName("Second"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !tester.AllPassed() {
+		t.Errorf("Expected AllPassed after re-running, got %+v", tester.LastResults())
+	}
+}
+
+func TestRunAllWithDeadline(t *testing.T) {
+	// Slow is abandoned when it times out; release it and wait for it to exit
+	release, slowDone := make(chan struct{}), make(chan struct{})
+	defer func() {
+		close(release)
+		<-slowDone
+	}()
+
+	tester := NewTester()
+	var mu sync.Mutex
+	ran := map[string]bool{}
+	mark := func(name string) {
+		mu.Lock()
+		ran[name] = true
+		mu.Unlock()
+	}
+	tester.Stage("Fast", func() { mark("Fast") })
+	tester.Stage("Slow", func() {
+		defer close(slowDone)
+		mark("Slow")
+		<-release
+	})
+	tester.Stage("Later1", func() { mark("Later1") })
+	tester.Stage("Later2", func() { mark("Later2") })
+
+	results := tester.RunAllWithDeadline(50 * time.Millisecond)
+
+	// Slow is still running when the deadline passes, so it is timed out
+	want := []string{StageStatusPassed, StageStatusTimedOut, StageStatusSkippedDeadline, StageStatusSkippedDeadline}
+	for i, r := range results {
+		if r.Status != want[i] {
+			t.Errorf("Stage %s: expected %q, got %q", r.Name, want[i], r.Status)
+		}
+	}
+	mu.Lock()
+	if ran["Later1"] || ran["Later2"] {
+		t.Error("Expected stages after the deadline not to run")
+	}
+	mu.Unlock()
+	if tester.AllPassed() {
+		t.Error("Expected AllPassed to be false when stages were skipped")
+	}
+}
+
+func TestRunAllDeadlineIgnoresFakeClock(t *testing.T) {
+	// The deadline caps real run time: a fake clock jumping ahead must not skip stages
+	SetClock(NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)))
+	defer SetClock(nil)
+
+	tester := NewTester()
+	tester.Stage("Jumps", func() { Sleep(time.Hour) })
+	tester.Stage("Next", func() {})
+	results := tester.RunAllWithDeadline(time.Minute)
+	if results[0].Status != StageStatusPassed || results[1].Status != StageStatusPassed {
+		t.Errorf("Expected both stages to pass, got %+v", results)
+	}
+}
+
+func TestTimedOutStageLateLogs(t *testing.T) {
+	release, lateDone := make(chan struct{}), make(chan struct{})
+	tester := NewTester()
+	tester.Stage("A", func() {
+		<-release
+		Log(LogTypeInfo, "late from A", "")
+		RecordAction("late action from A", func() {})
+		close(lateDone)
+	})
+	tester.Stage("B", func() {
+		// A logs while B is the running stage
+		close(release)
+		<-lateDone
+		Log(LogTypeInfo, "from B", "")
+	})
+
+	results := tester.RunAllWithOptions(RunOptions{StageTimeout: 50 * time.Millisecond})
+	if results[0].Status != StageStatusTimedOut || results[1].Status != StageStatusPassed {
+		t.Fatalf("Expected A timed out and B passed, got %+v", results)
+	}
+
+	summaries := func(stage string) string {
+		var out []string
+		for _, e := range tester.StageLogs(stage) {
+			out = append(out, e.Summary)
+		}
+		return strings.Join(out, "|")
+	}
+	if logs := summaries("B"); strings.Contains(logs, "late from A") || !strings.Contains(logs, "from B") {
+		t.Errorf("Expected B's logs without A's late entry, got %s", logs)
+	}
+	if logs := summaries("A"); !strings.Contains(logs, "late from A") {
+		t.Errorf("Expected A's late entry in A's logs, got %s", logs)
+	}
+	for _, stage := range []string{"A", "B"} {
+		for _, a := range GetStageActions(stage) {
+			if a.Summary == "late action from A" {
+				t.Errorf("Expected the late action of A to be dropped, found it under %s", stage)
+			}
+		}
+	}
+
+	// Once A returns, its goroutine is no longer tracked
+	for i := 0; i < 100; i++ {
+		actionMu.Lock()
+		n := len(abandonedStages)
+		actionMu.Unlock()
+		if n == 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("Expected the finished stage to be removed from the abandoned stages")
+}
+
+func TestRunAllStageTimeoutAndDeadline(t *testing.T) {
+	// Timed-out stages keep running; release them and wait for their goroutines to exit
+	// so later tests (the leak check) see a settled goroutine count
+	before := runtime.NumGoroutine()
+	release := make(chan struct{})
+	defer func() {
+		close(release)
+		for i := 0; i < 100 && runtime.NumGoroutine() > before; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	tester := NewTester()
+	tester.Stage("Hangs", func() { <-release })
+	tester.Stage("Quick", func() {})
+	tester.Stage("HangsToo", func() { <-release })
+	tester.Stage("Never", func() {})
+
+	start := time.Now()
+	results := tester.RunAllWithOptions(RunOptions{StageTimeout: 50 * time.Millisecond, Deadline: 300 * time.Millisecond})
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("Expected blocked stages to be timed out, run took %v", elapsed)
+	}
+	want := []string{StageStatusTimedOut, StageStatusPassed, StageStatusTimedOut, StageStatusPassed}
+	for i, r := range results {
+		if r.Status != want[i] {
+			t.Errorf("Stage %s: expected %q, got %q", r.Name, want[i], r.Status)
+		}
+	}
+	if results[0].Err == nil || !strings.Contains(results[0].Err.Error(), "timed out after 50ms") {
+		t.Errorf("Expected a timeout error, got %v", results[0].Err)
+	}
+
+	// The suite deadline also bounds a stage that has no timeout of its own
+	tester = NewTester()
+	tester.Stage("Blocks", func() { <-release })
+	tester.Stage("After", func() {})
+	start = time.Now()
+	results = tester.RunAllWithDeadline(100 * time.Millisecond)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("Expected the deadline to stop a blocked stage, run took %v", elapsed)
+	}
+	if results[0].Status != StageStatusTimedOut || results[1].Status != StageStatusSkippedDeadline {
+		t.Errorf("Expected timed out then skipped, got %+v", results)
+	}
+}
+
+func TestActionStatus(t *testing.T) {
+	tester := NewTester()
+	shouldFail := true
+	tester.Stage("ActionStatusStage", func() {
+		RecordAction("ok action", func() {})
+		RecordAction("flaky action", func() {
+			if shouldFail {
+				Fail("flaky failed")
+			}
+		})
+	})
+	if err := tester.RunStageByName("ActionStatusStage"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	okUID, flakyUID := ActionUID("ActionStatusStage", 0), ActionUID("ActionStatusStage", 1)
+	if st := tester.ActionStatus(okUID); st != StageStatusNotRun {
+		t.Errorf("Expected %q before a manual run, got %q", StageStatusNotRun, st)
+	}
+
+	if err := tester.RunAction(okUID); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if st := tester.ActionStatus(okUID); st != StageStatusPassed {
+		t.Errorf("Expected %q, got %q", StageStatusPassed, st)
+	}
+
+	if err := tester.RunAction(flakyUID); err == nil || err.Error() != "flaky failed" {
+		t.Errorf("Expected flaky failed error, got %v", err)
+	}
+	if st := tester.ActionStatus(flakyUID); st != StageStatusFailed {
+		t.Errorf("Expected %q, got %q", StageStatusFailed, st)
+	}
+
+	shouldFail = false
+	tester.RunAction(flakyUID)
+	if st := tester.ActionStatus(flakyUID); st != StageStatusPassed {
+		t.Errorf("Expected %q after a passing re-run, got %q", StageStatusPassed, st)
+	}
+	if tester.StageStatus("ActionStatusStage") != StageStatusPassed {
+		t.Errorf("Manual action runs must not change the stage status")
+	}
+
+	// Re-running the stage re-records its actions and resets their statuses
+	tester.RunStageByName("ActionStatusStage")
+	if st := tester.ActionStatus(okUID); st != StageStatusNotRun {
+		t.Errorf("Expected %q after stage re-run, got %q", StageStatusNotRun, st)
+	}
+
+	if err := tester.RunAction(ActionUID("ActionStatusStage", 5)); err == nil {
+		t.Error("Expected an error for an unknown action")
+	}
+}
+
+func TestActionStatusStageNamesWithColon(t *testing.T) {
+	tester := NewTester()
+	tester.Stage("A", func() { RecordAction("a action", func() {}) })
+	tester.Stage("A:B", func() { RecordAction("a:b action", func() {}) })
+	tester.RunStageByName("A")
+	tester.RunStageByName("A:B")
+
+	nested := ActionUID("A:B", 0)
+	if stage, idx, ok := ParseActionUID(nested); !ok || stage != "A:B" || idx != 0 {
+		t.Fatalf("ParseActionUID(%q) = %q, %d, %v", nested, stage, idx, ok)
+	}
+	if err := tester.RunAction(nested); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Re-running "A" only resets the statuses of its own actions
+	tester.RunStageByName("A")
+	if st := tester.ActionStatus(nested); st != StageStatusPassed {
+		t.Errorf("Expected %q for the action of stage A:B, got %q", StageStatusPassed, st)
+	}
+
+	for _, uid := range []string{"A", "A:x", "A:-1"} {
+		if _, _, ok := ParseActionUID(uid); ok {
+			t.Errorf("Expected %q to be an invalid action uid", uid)
+		}
+	}
+}
+
+func TestRunStageIsolated(t *testing.T) {
+	tester := NewTester()
+	tester.Stage("IsolatedSetup", func() { Log(LogTypeInfo, "setup entry", "") })
+	tester.Stage("IsolatedTarget", func() {
+		Log(LogTypeInfo, "target entry", "")
+		Fail("target failed")
+	})
+	tester.Stage("IsolatedAfter", func() { Log(LogTypeInfo, "after entry", "") })
+
+	tester.RunStageByName("IsolatedSetup")
+
+	logs := tester.RunStageIsolated("IsolatedTarget")
+	var summaries []string
+	for _, l := range logs {
+		if l.Stage != "IsolatedTarget" {
+			t.Errorf("Expected only IsolatedTarget entries, got one from %q: %s", l.Stage, l.Summary)
+		}
+		summaries = append(summaries, l.Summary)
+	}
+	joined := strings.Join(summaries, "|")
+	if !strings.Contains(joined, "target entry") || !strings.Contains(joined, "IsolatedTarget FAILED") {
+		t.Errorf("Expected the target's entries including its failure, got %v", summaries)
+	}
+
+	if st := tester.StageStatus("IsolatedTarget"); st != StageStatusFailed {
+		t.Errorf("Expected target status %q, got %q", StageStatusFailed, st)
+	}
+	if st := tester.StageStatus("IsolatedSetup"); st != StageStatusPassed {
+		t.Errorf("Expected other stage status untouched (%q), got %q", StageStatusPassed, st)
+	}
+	if st := tester.StageStatus("IsolatedAfter"); st != StageStatusNotRun {
+		t.Errorf("Expected later stage not to run, got %q", st)
+	}
+
+	if logs := tester.RunStageIsolated("Missing"); logs != nil {
+		t.Errorf("Expected nil for an unknown stage, got %v", logs)
+	}
+}
+
+func TestRunAllWithOptionsFailFast(t *testing.T) {
+	build := func() (*Tester, *[]string) {
+		var ran []string
+		tester := NewTester()
+		tester.Stage("FF1", func() { ran = append(ran, "FF1") })
+		tester.Stage("FF2", func() { ran = append(ran, "FF2"); Fail("early failure") })
+		tester.Stage("FF3", func() { ran = append(ran, "FF3") })
+		tester.Stage("FF4", func() { ran = append(ran, "FF4"); Fail("late failure") })
+		return tester, &ran
+	}
+	statuses := func(results []StageResult) []string {
+		var out []string
+		for _, r := range results {
+			out = append(out, r.Status)
+		}
+		return out
+	}
+
+	tester, ran := build()
+	results := tester.RunAllWithOptions(RunOptions{FailFast: true})
+	if got, want := strings.Join(*ran, ","), "FF1,FF2"; got != want {
+		t.Errorf("FailFast: expected stages %s to run, got %s", want, got)
+	}
+	want := []string{StageStatusPassed, StageStatusFailed, StageStatusSkipped, StageStatusSkipped}
+	if got := statuses(results); !reflect.DeepEqual(got, want) {
+		t.Errorf("FailFast: expected statuses %v, got %v", want, got)
+	}
+
+	tester, ran = build()
+	results = tester.RunAllWithOptions(RunOptions{})
+	if got, want := strings.Join(*ran, ","), "FF1,FF2,FF3,FF4"; got != want {
+		t.Errorf("Continue: expected stages %s to run, got %s", want, got)
+	}
+	want = []string{StageStatusPassed, StageStatusFailed, StageStatusPassed, StageStatusFailed}
+	if got := statuses(results); !reflect.DeepEqual(got, want) {
+		t.Errorf("Continue: expected statuses %v, got %v", want, got)
+	}
+}
+
+func TestRunAllLeakCheck(t *testing.T) {
+	var mu sync.Mutex
+	var leakLogs []LogEntry
+	RegisterLogHandler(func(e LogEntry) {
+		if strings.HasPrefix(e.Summary, "Goroutine leak") {
+			mu.Lock()
+			leakLogs = append(leakLogs, e)
+			mu.Unlock()
+		}
+	})
+	leaks := func() []LogEntry {
+		mu.Lock()
+		defer mu.Unlock()
+		out := leakLogs
+		leakLogs = nil
+		return out
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	tester := NewTester()
+	tester.Stage("Leaky", func() {
+		go func() { <-stop }()
+	})
+	tester.RunAllWithOptions(RunOptions{LeakCheck: true})
+	got := leaks()
+	if len(got) != 1 {
+		t.Fatalf("Expected one leak warning, got %d", len(got))
+	}
+	if got[0].Type != LogTypeError || !strings.Contains(got[0].Summary, "threshold 0)") {
+		t.Errorf("Unexpected leak warning: %s", got[0].Summary)
+	}
+	if !strings.Contains(got[0].Detail, "TestRunAllLeakCheck") {
+		t.Errorf("Expected the stacks to show the leaking goroutine, got:\n%s", got[0].Detail)
+	}
+
+	tester = NewTester()
+	tester.Stage("LeakyWithinThreshold", func() {
+		go func() { <-stop }()
+	})
+	tester.RunAllWithOptions(RunOptions{LeakCheck: true, LeakThreshold: 1})
+	tester = NewTester()
+	tester.Stage("Clean", func() {
+		done := make(chan struct{})
+		go func() { close(done) }()
+		<-done
+	})
+	tester.RunAllWithOptions(RunOptions{LeakCheck: true})
+	if got := leaks(); len(got) != 0 {
+		t.Errorf("Expected no leak warnings, got %v", got)
+	}
+}
+
+func TestStageEach(t *testing.T) {
+	tester := NewTester()
+	var seen []string
+	tester.StageEach("Login", []interface{}{"admin", "member", "guest"}, func(c interface{}) {
+		seen = append(seen, c.(string))
+		if c == "guest" {
+			Fail("guests cannot log in")
+		}
+	})
+
+	results := tester.RunAll()
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 stage results, got %d", len(results))
+	}
+	want := map[string]string{
+		"Login[admin]":  StageStatusPassed,
+		"Login[member]": StageStatusPassed,
+		"Login[guest]":  StageStatusFailed,
+	}
+	for _, r := range results {
+		if want[r.Name] != r.Status {
+			t.Errorf("Stage %s: expected %q, got %q", r.Name, want[r.Name], r.Status)
+		}
+	}
+	if strings.Join(seen, ",") != "admin,member,guest" {
+		t.Errorf("Expected each case to run once in order, got %v", seen)
+	}
+
+	// Each case can be re-run on its own
+	seen = nil
+	if err := tester.RunStageByName("Login[member]"); err != nil {
+		t.Errorf("Expected Login[member] to pass, got %v", err)
+	}
+	if strings.Join(seen, ",") != "member" {
+		t.Errorf("Expected only the member case to run, got %v", seen)
+	}
+
+	// Cases that format to the same name fail without registering any stage
+	dup := NewTester()
+	ExpectFailure(func() {
+		dup.StageEach("Retry", []interface{}{1, "1"}, func(c interface{}) {})
+	})
+	if len(dup.Stages) != 0 {
+		t.Errorf("Expected no stages after a duplicate case, got %d", len(dup.Stages))
+	}
+}
+
+func TestTesterLogger(t *testing.T) {
+	var global []LogEntry
+	logMu.Lock()
+	saved := logHandlers
+	logMu.Unlock()
+	RegisterLogHandler(func(e LogEntry) { global = append(global, e) })
+	defer func() {
+		logMu.Lock()
+		logHandlers = saved
+		logMu.Unlock()
+	}()
+
+	var logsA, logsB []LogEntry
+	a, b := NewTester(), NewTester()
+	a.Logger = func(e LogEntry) { logsA = append(logsA, e) }
+	b.Logger = func(e LogEntry) { logsB = append(logsB, e) }
+	a.Stage("LoggerA", func() {
+		Logf(LogTypeInfo, "from A")
+		RecordAction("say A", func() { Logf(LogTypeInfo, "action A") })
+	})
+	b.Stage("LoggerB", func() { Logf(LogTypeInfo, "from B") })
+
+	a.RunAll()
+	b.RunAll()
+	if err := a.RunAction(ActionUID("LoggerA", 0)); err != nil {
+		t.Fatalf("RunAction failed: %v", err)
+	}
+
+	summaries := func(entries []LogEntry) string {
+		var s []string
+		for _, e := range entries {
+			s = append(s, e.Summary)
+		}
+		return strings.Join(s, "|")
+	}
+	gotA, gotB := summaries(logsA), summaries(logsB)
+	if !strings.Contains(gotA, "Running Stage: LoggerA|from A|Stage LoggerA PASSED") || !strings.Contains(gotA, "Run finished: 1 passed") ||
+		!strings.Contains(gotA, "Manual Run: say A|action A|Manual Run PASSED: say A") {
+		t.Errorf("Unexpected logs for A: %s", gotA)
+	}
+	if !strings.Contains(gotB, "from B") || strings.Contains(gotB, "from A") || strings.Contains(gotA, "from B") {
+		t.Errorf("Logs crossed between testers: A=%s B=%s", gotA, gotB)
+	}
+	if len(global) != 0 {
+		t.Errorf("Expected no entries for the global handlers, got %s", summaries(global))
+	}
+
+	// Stage logs are still buffered per tester
+	if got := summaries(a.StageLogs("LoggerA")); !strings.Contains(got, "from A") || strings.Contains(got, "from B") {
+		t.Errorf("Unexpected stage logs for A: %s", got)
+	}
+
+	// Without a Logger, entries go to the global handlers
+	c := NewTester()
+	c.Stage("Plain", func() { Logf(LogTypeInfo, "from C") })
+	c.RunAll()
+	if !strings.Contains(summaries(global), "from C") {
+		t.Errorf("Expected the global handlers to receive C's logs, got %s", summaries(global))
+	}
+}